@@ -63,6 +63,13 @@ func main() {
 	}
 	// Process each entry in the @graph array
 
+	// Index the labels and comments for full-text search, the index follows every Set
+	searchable := true
+	if _, err := DataStore.CreateSearchIndex("schema.org", `$."rdfs:label"`, `$."rdfs:comment"`); err != nil {
+		log.Printf("Full-text search is disabled: %v", err)
+		searchable = false
+	}
+
 	// Initialize a timer to track processing time
 	startTime := time.Now()
 	counter := 0
@@ -83,8 +90,9 @@ func main() {
 
 		// Create RegisterArgs and register the schema entry
 		setArgs := types.SetArgs{
-			Key:    id,
-			Object: graphItem,
+			Key:       id,
+			SchemaKey: "schema.org",
+			Object:    graphItem,
 		}
 
 		_, err := DataStore.Set(setArgs)
@@ -99,4 +107,14 @@ func main() {
 		}
 	}
 	log.Println("Successfully loaded schema.org schema")
+
+	if searchable {
+		hits, err := DataStore.Search(types.SearchQuery{SchemaKey: "schema.org", Text: "restaurant", Limit: 5})
+		if err != nil {
+			log.Fatalf("Error searching schema.org: %v", err)
+		}
+		for _, hit := range hits {
+			log.Printf("%s %v", hit.Key, hit.Snippets)
+		}
+	}
 }
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// connector opens sqlite3 connections for a fixed DSN and applies the
+// configured PRAGMA statements to each of them, since most pragmas are
+// per-connection and database/sql pools connections freely.
+type connector struct {
+	dsn    string
+	driver *sqlite3.SQLiteDriver
+}
+
+func newConnector(dsn string, pragmas []string) *connector {
+	return &connector{
+		dsn: dsn,
+		driver: &sqlite3.SQLiteDriver{
+			ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+				for _, pragma := range pragmas {
+					if _, err := conn.Exec(pragma, nil); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+	}
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// parseFlags splits a flag string such as ";PRAGMA journal_mode=WAL;" into
+// individual statements.
+func parseFlags(flags string) []string {
+	var stmts []string
+	for _, stmt := range strings.Split(flags, ";") {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	return stmts
+}
+
+// isMemoryUrl reports whether a SQLite url refers to an in-memory database.
+func isMemoryUrl(url string) bool {
+	return url == ":memory:" || strings.HasPrefix(url, "file::memory:") || strings.Contains(url, "mode=memory")
+}
+
+// dbFilePath strips the "file:" scheme and any query parameters from a SQLite url.
+func dbFilePath(url string) string {
+	path := strings.TrimPrefix(url, "file:")
+	if i := strings.Index(path, "?"); i >= 0 {
+		path = path[:i]
+	}
+	return path
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	// _ "github.com/mattn/go-sqlite3"
 )
@@ -11,17 +12,16 @@ func initDB(db *sql.DB) error {
 	if err != nil {
 		return err
 	}
-	err = createTables(db)
-	if err != nil {
-		return err
-	}
-	return nil
+	_, err = migrate(context.Background(), db, false)
+	return err
 }
 
-func createTables(db *sql.DB) error {
+// createTables is the first migration, it creates the tables if they do not exist,
+// which also adopts databases created before schema versions were tracked
+func createTables(ctx context.Context, tx migrationTx) error {
 
 	// Create meta table
-	_, err := db.Exec(`
+	_, err := tx.ExecContext(ctx, `
 		CREATE TABLE IF NOT EXISTS meta (
 			meta_key TEXT,
 			schema_key TEXT NOT NULL,
@@ -34,7 +34,7 @@ func createTables(db *sql.DB) error {
 	}
 
 	// Create data table
-	_, err = db.Exec(`
+	_, err = tx.ExecContext(ctx, `
 		CREATE TABLE IF NOT EXISTS data (
 			data_id TEXT,
 			job_id TEXT,
@@ -46,13 +46,13 @@ func createTables(db *sql.DB) error {
 	if err != nil {
 		return err
 	}
-	_, err = db.Exec(`
+	_, err = tx.ExecContext(ctx, `
 		CREATE INDEX IF NOT EXISTS idx_data_job_id ON data(job_id)
 	`)
 	if err != nil {
 		return err
 	}
-	_, err = db.Exec(`
+	_, err = tx.ExecContext(ctx, `
 		CREATE INDEX IF NOT EXISTS idx_data_meta_key ON data(meta_key)
 	`)
 	if err != nil {
@@ -60,7 +60,7 @@ func createTables(db *sql.DB) error {
 	}
 
 	// Create job_graph table
-	_, err = db.Exec(`
+	_, err = tx.ExecContext(ctx, `
 		CREATE TABLE IF NOT EXISTS job_graph (
 			graph_id TEXT,
 			top_node TEXT NOT NULL,
@@ -73,7 +73,7 @@ func createTables(db *sql.DB) error {
 	}
 
 	// Create job table
-	_, err = db.Exec(`
+	_, err = tx.ExecContext(ctx, `
 		CREATE TABLE IF NOT EXISTS job (
 			job_id TEXT NOT NULL,
 			data_id TEXT REFERENCES data(data_id),
@@ -84,12 +84,45 @@ func createTables(db *sql.DB) error {
 	if err != nil {
 		return err
 	}
+	// The status of a job is kept in the row without a data_id
+	_, err = tx.ExecContext(ctx, `
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_job_status ON job(job_id) WHERE data_id IS NULL
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create event table, the durable log of changes read by watchers
+	_, err = tx.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS event (
+			event_id TEXT,
+			meta_key TEXT NOT NULL,
+			oper TEXT NOT NULL,
+			event_data JSON NOT NULL,
+			PRIMARY KEY(event_id)
+		)
+	`)
+	if err != nil {
+		return err
+	}
 
 	return nil
 }
 
 func dropTables(db *sql.DB) error {
-	tables := []string{"data", "job", "job_graph", "meta"}
+	tables := []string{"data", "job", "job_graph", "meta", "event", "json_index", "schema_version"}
+
+	// The tables of the search indexes go first, while search_index still lists them
+	if rows, err := db.Query("SELECT table_name FROM search_index"); err == nil {
+		for rows.Next() {
+			var table string
+			if rows.Scan(&table) == nil {
+				tables = append([]string{quoteIdent(table), quoteIdent(table + "_keys")}, tables...)
+			}
+		}
+		rows.Close()
+	}
+	tables = append(tables, "search_index")
 
 	for _, table := range tables {
 		_, err := db.Exec("DROP TABLE IF EXISTS " + table)
@@ -100,3 +133,93 @@ func dropTables(db *sql.DB) error {
 
 	return nil
 }
+
+// addRevisionColumns adds the schema_key and prev_id columns to the data table
+// and fills them in: schema_key from the metadata of the key and prev_id from the
+// storeID order of the revisions of each key.
+func addRevisionColumns(ctx context.Context, tx migrationTx) error {
+	rows, err := tx.QueryContext(ctx, "SELECT name FROM pragma_table_info('data')")
+	if err != nil {
+		return err
+	}
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		columns[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	var stmts []string
+	for _, column := range []string{"schema_key", "prev_id"} {
+		if !columns[column] {
+			stmts = append(stmts, "ALTER TABLE data ADD COLUMN "+column+" TEXT")
+		}
+	}
+	stmts = append(stmts,
+		"UPDATE data SET schema_key = COALESCE((SELECT schema_key FROM meta WHERE meta.meta_key = data.meta_key), meta_key)",
+		"UPDATE data SET prev_id = (SELECT MAX(prev.data_id) FROM data AS prev "+
+			"WHERE prev.meta_key = data.meta_key AND prev.data_id < data.data_id)",
+	)
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createIndexTable creates the table of declared JSON path indexes,
+// the expression indexes themselves live on the data table
+func createIndexTable(ctx context.Context, tx migrationTx) error {
+	_, err := tx.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS json_index (
+			index_name TEXT,
+			schema_key TEXT NOT NULL,
+			path TEXT NOT NULL,
+			PRIMARY KEY(index_name)
+		)
+	`)
+	return err
+}
+
+// createSearchIndexTable creates the table of full-text search indexes,
+// the FTS5 tables are created when an index is declared, see search.go
+func createSearchIndexTable(ctx context.Context, tx migrationTx) error {
+	_, err := tx.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS search_index (
+			schema_key TEXT,
+			table_name TEXT NOT NULL,
+			paths JSON NOT NULL,
+			PRIMARY KEY(schema_key)
+		)
+	`)
+	return err
+}
+
+// createMetaSchemaIndex indexes the metadata by schema key, which the metadata lookup
+// falls back to for a key without metadata of its own
+func createMetaSchemaIndex(ctx context.Context, tx migrationTx) error {
+	_, err := tx.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS idx_meta_schema_key ON meta(schema_key)")
+	return err
+}
+
+// addJobStartedColumn keeps the start time of a job in a column of its own, in Unix
+// seconds like unixSeconds, so the jobs started in a time range are found with an index
+func addJobStartedColumn(ctx context.Context, tx migrationTx) error {
+	for _, stmt := range []string{
+		"ALTER TABLE job ADD COLUMN started REAL",
+		"UPDATE job SET started = unixepoch(json_extract(job_data, '$.started'), 'subsec') WHERE data_id IS NULL",
+		"CREATE INDEX IF NOT EXISTS idx_job_started ON job(started) WHERE data_id IS NULL",
+	} {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
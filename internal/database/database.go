@@ -4,102 +4,198 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/cfjello/go-store/pkg/config"
+	"github.com/cfjello/go-store/pkg/metrics"
+	"github.com/cfjello/go-store/pkg/store"
 	"github.com/cfjello/go-store/pkg/types"
 	"github.com/cfjello/go-store/pkg/util"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
-// StoreService represents a service that interacts with a database.
-
-/*
-type DBFunctions interface {
-	SetMeta(key string, meta types.MetaData) bool
-	GetMeta(key string, storeId string) (types.MetaData, error)
-	SetData(key string, data types.SetArgs) bool
-	GetData(key string) (any, error)
-	Close() error
+// DBService is the SQLite implementation of store.Backend.
+type DBService struct {
+	DB        *sql.DB
+	SQL       *SqlStmt
+	DbUrl     string
+	Flags     string
+	InMemory  bool
+	Snapshots bool
+	// MinFreeBytes is the free disk space below which a persistent database is not ready
+	MinFreeBytes uint64
+
+	opened       time.Time
+	fts5         bool // SQLite was built with FTS5, see search.go
+	snapInterval time.Duration
+	snapMu       sync.Mutex
+	lastSnapshot time.Time
+	stopSnap     chan struct{}
+	snapDone     chan struct{}
+}
 
-	// initDB() error
-	// createTables() error
-	// dropTables() error
+// Options controls how a DBService opens its SQLite database.
+type Options struct {
+	DbUrl    string // SQLite file URL, e.g. "file:/var/lib/go-store/go-store.db"
+	Flags    string // PRAGMA statements applied to every new connection, separated by ';'
+	InMemory bool   // open a private in-memory database instead of DbUrl (tests)
+	// Snapshot runs the database in memory, loaded from DbUrl at startup and
+	// written back every SnapshotInterval and on Close.
+	Snapshot         bool
+	SnapshotInterval time.Duration
+	// DryRun lists the pending schema migrations without applying them, Open fails
+	// with ErrMigrationsPending if there are any
+	DryRun bool
+}
 
-	// Health returns a map of health status information.
-	// The keys and values in the map are service-specific.
-	Health() map[string]string
+var _ store.Backend = (*DBService)(nil)
 
-	// Close terminates the database connection.
-	// It returns an error if the connection cannot be closed.
+var dbInstance *DBService
 
-}
-*/
+// OptionsFromEnv builds the database options from SQLITE_DB_URL, SQLITE_DB_FLAGS,
+// SQLITE_DB_MODE, SQLITE_SNAPSHOT_MS and SQLITE_MIGRATE_DRY_RUN, falling back to the
+// config.Sqlite3 defaults.
+func OptionsFromEnv() Options {
+	util.SetEnv() // Load default environment variables
+	defaults := config.DefaultConfig().Sqlite3
 
-type DBService struct {
-	DB    *sql.DB
-	SQL   *SqlStmt
-	DbUrl string
+	opts := Options{
+		DbUrl: os.Getenv("SQLITE_DB_URL"),
+		Flags: os.Getenv("SQLITE_DB_FLAGS"),
+	}
+	if opts.DbUrl == "" {
+		opts.DbUrl = "file:" + defaults.File
+	}
+	if opts.Flags == "" {
+		opts.Flags = defaults.Flags
+	}
+	switch strings.ToLower(os.Getenv("SQLITE_DB_MODE")) {
+	case "memory":
+		opts.InMemory = true
+	case "snapshot":
+		opts.Snapshot = true
+	}
+	if isMemoryUrl(opts.DbUrl) {
+		opts.InMemory = true
+		opts.Snapshot = false
+	}
+	if dryRun, err := strconv.ParseBool(os.Getenv("SQLITE_MIGRATE_DRY_RUN")); err == nil {
+		opts.DryRun = dryRun
+	}
+	if ms, err := strconv.Atoi(os.Getenv("SQLITE_SNAPSHOT_MS")); err == nil {
+		opts.SnapshotInterval = time.Duration(ms) * time.Millisecond
+	}
+	return opts
 }
 
-var dbInstance *DBService
-
+// New returns the shared DBService configured from the environment.
+// Unless SQLITE_DB_MODE=memory is set, the data is kept on disk and
+// existing rows survive a restart.
 func New() *DBService {
 	// Reuse Connection
 	if dbInstance != nil {
 		return dbInstance
 	}
-	util.SetEnv() // Load default environment variables
+	svc, err := Open(OptionsFromEnv())
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	metrics.RegisterDB(svc.DB, "go-store")
+	dbInstance = svc
+	return dbInstance
+}
 
-	dbUrl := os.Getenv("SQLITE_DB_URL")
-	if dbUrl == "" {
-		// Default to a file-based SQLite database if not set
-		dbUrl = "file:F:/Sqlite3/go-store.db"
+// Open opens a new DBService with the given options and applies the pending schema
+// migrations, see migrate. Existing rows are kept. A database migrated by a newer
+// binary is refused with ErrSchemaTooNew.
+func Open(opts Options) (*DBService, error) {
+	dsn := opts.DbUrl
+	if opts.InMemory || opts.Snapshot {
+		dsn = "file::memory:"
 	}
-	/*
-		dbFlags := os.Getenv("SQLITE_DB_FLAGS")
-		if dbFlags == "" {
-			// Default flags for SQLite database
-			dbFlags = ";PRAGMA journal_mode=OFF;"
+	if !opts.InMemory {
+		if opts.DbUrl == "" {
+			return nil, errors.New("no database url provided")
+		}
+		if dir := filepath.Dir(dbFilePath(opts.DbUrl)); dir != "." {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return nil, fmt.Errorf("failed to create database directory %s: %w", dir, err)
+			}
 		}
-	*/
-	db, err := sql.Open("sqlite3", ":memory:")
-	if err != nil {
-		log.Fatalf("Failed to open in-memory database: %v", err)
 	}
 
-	dropErr := dropTables(db)
-	if dropErr != nil {
-		log.Fatal(dropErr)
+	db := sql.OpenDB(newConnector(dsn, parseFlags(opts.Flags)))
+	if dsn == "file::memory:" {
+		// Every connection to :memory: is a separate database, so keep exactly one
+		db.SetMaxOpenConns(1)
 	}
-	// Create tables after dropping existing ones
-	dbErr := createTables(db)
-	if dbErr != nil {
-		log.Fatal(dbErr)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open database %s: %w", dsn, err)
 	}
 
-	// Prepare SQL statements
+	svc := &DBService{
+		DbUrl:     opts.DbUrl,
+		Flags:     opts.Flags,
+		InMemory:  opts.InMemory,
+		Snapshots: opts.Snapshot && !opts.InMemory,
+		DB:        db,
+
+		MinFreeBytes: defaultMinFreeBytes,
+		opened:       time.Now(),
+	}
+
+	if svc.Snapshots {
+		// Load the last snapshot, if any, before the schema is checked
+		err := svc.Restore(context.Background())
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	applied, err := migrate(context.Background(), db, opts.DryRun)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if opts.DryRun && len(applied) > 0 {
+		db.Close()
+		log.Printf("Pending database migrations of %s: %s", dsn, migrationNames(applied))
+		return nil, fmt.Errorf("%w: %s", ErrMigrationsPending, migrationNames(applied))
+	}
 
+	// Prepare SQL statements
 	sqlStmt, err := NewSqlStmt(db)
 	if err != nil {
-		log.Fatalf("Failed to prepare SQL statements: %v", err)
+		db.Close()
+		return nil, fmt.Errorf("failed to prepare SQL statements: %w", err)
 	}
+	svc.SQL = sqlStmt
 
-	dbInstance = &DBService{
-		DbUrl: dbUrl,
-		DB:    db,
-		SQL:   sqlStmt,
+	svc.fts5 = hasFTS5(context.Background(), db)
+	if err := svc.syncSearchIndexes(context.Background()); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to check the search indexes: %w", err)
 	}
 
-	return dbInstance
+	if svc.Snapshots && opts.SnapshotInterval > 0 {
+		svc.snapInterval = opts.SnapshotInterval
+		svc.startSnapshots(opts.SnapshotInterval)
+	}
+	return svc, nil
 }
 
-func (s *DBService) SetData(key string, value types.SetArgs) bool {
-	// Implementation for setting data in the database
+// SetData stores a new revision of an object and, unless event is nil, logs the
+// event in the same transaction
+func (s *DBService) SetData(key string, value types.SetArgs, event *types.Event) bool {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -109,7 +205,22 @@ func (s *DBService) SetData(key string, value types.SetArgs) bool {
 		return false
 	}
 
-	sqlRes, err := s.SQL.dataInsStmt.ExecContext(ctx, key, value.JobID, value.SchemaKey, ObjJSON)
+	storeID := value.StoreID
+	if storeID == "" {
+		storeID = util.Ulid()
+	}
+	schemaKey := value.SchemaKey
+	if schemaKey == "" {
+		schemaKey = key
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Failed to begin setting data for key: %s, error: %v", key, err)
+		return false
+	}
+	defer tx.Rollback()
+
+	sqlRes, err := s.SQL.dataInsStmt.Tx(ctx, tx).ExecContext(ctx, storeID, value.JobID, key, schemaKey, key, ObjJSON)
 	if err != nil {
 		log.Printf("Failed to execute statement for key: %s, error: %v", key, err)
 		return false
@@ -120,25 +231,35 @@ func (s *DBService) SetData(key string, value types.SetArgs) bool {
 		log.Printf("Failed to set data for key: %s, error: %v", key, err)
 		return false
 	}
-	// log.Printf("Data set for key: %s", key)
+	if event != nil {
+		if err := s.insertEvent(ctx, s.SQL.evtInsStmt.Tx(ctx, tx), *event); err != nil {
+			log.Printf("Failed to log event %s for key: %s, error: %v", event.ID, key, err)
+			return false
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit data for key: %s, error: %v", key, err)
+		return false
+	}
 	return true
 }
 
-func (s *DBService) GetData(key string) (any, error) {
+func (s *DBService) GetData(key string, storeID string) (any, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	var data types.SetArgs
 	var dataJson []byte
-	err := s.SQL.dataSelStmt.QueryRowContext(ctx, key).Scan(&data.Key, &data.JobID, &data.SchemaKey, &dataJson)
+	var jobID sql.NullString
+	err := s.SQL.dataSelStmt.QueryRowContext(ctx, storeID, key).Scan(&data.StoreID, &jobID, &data.Key, &dataJson)
 	if err != nil {
-		log.Printf("Failed to get data for key: %s, error: %v", key, err)
-		return nil, err
+		log.Printf("Failed to get data for storeID: %s, error: %v", storeID, err)
+		return nil, notFound(err)
 	}
 
 	err = json.Unmarshal(dataJson, &data.Object)
 	if err != nil {
-		log.Printf("Failed to unmarshal object data for key: %s, error: %v", key, err)
+		log.Printf("Failed to unmarshal object data for storeID: %s, error: %v", storeID, err)
 		return nil, err
 	}
 
@@ -155,8 +276,15 @@ func (s *DBService) SetMeta(key string, value types.MetaData) bool {
 		log.Printf("Failed to marshal meta data for key: %s, error: %v", key, err)
 		return false
 	}
-	meta := string(metaJSON)
-	s.SQL.metaInsStmt.ExecContext(ctx, key, meta)
+	schemaKey := value.SchemaKey
+	if schemaKey == "" {
+		schemaKey = key
+	}
+	_, err = s.SQL.metaInsStmt.ExecContext(ctx, key, schemaKey, string(metaJSON))
+	if err != nil {
+		log.Printf("Failed to execute statement for key: %s, error: %v", key, err)
+		return false
+	}
 	// log.Printf("Meta data set for key: %s", key)
 	return true
 }
@@ -170,10 +298,10 @@ func (s *DBService) GetMeta(key string, schemaKey string) (types.MetaData, error
 	if schemaKey == "" {
 		schemaKey = key
 	}
-	err := s.SQL.metaSelStmt.QueryRowContext(ctx, key, schemaKey).Scan(&metaJson)
+	err := s.SQL.metaSelStmt.QueryRowContext(ctx, key, schemaKey, key).Scan(&metaJson)
 	if err != nil {
 		// log.Printf("Failed to get meta data for key: %s, error: %v", key, err)
-		return types.MetaData{}, err
+		return types.MetaData{}, notFound(err)
 	}
 
 	err = json.Unmarshal(metaJson, &meta)
@@ -208,11 +336,104 @@ func (s *DBService) GetCurrStoreID(key string) (string, error) {
 	err := s.SQL.dataSelLastStmt.QueryRowContext(ctx, key).Scan(&storeID)
 	if err != nil {
 		log.Printf("failed to get current store ID for key: %s, error: %v", key, err)
-		return "", err
+		return "", notFound(err)
 	}
 	return storeID, nil
 }
 
+// GetRevisions returns the stored revisions of a key in storeID order.
+// Only revisions after opts.Cursor are returned, at most opts.Limit of them.
+func (s *DBService) GetRevisions(key string, opts types.HistoryOpts) ([]types.Revision, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = -1 // no limit in SQLite
+	}
+	stmt, cursor := s.SQL.dataRevAscStmt, opts.Cursor
+	if opts.Desc {
+		stmt = s.SQL.dataRevDescStmt
+		if cursor == "" {
+			cursor = "~" // sorts after every ULID
+		}
+	}
+	rows, err := stmt.QueryContext(ctx, key, cursor, limit)
+	if err != nil {
+		log.Printf("failed to get revisions for key: %s, error: %v", key, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []types.Revision{}
+	for rows.Next() {
+		var rev types.Revision
+		var prevID, jobID, schemaKey sql.NullString
+		var dataJson []byte
+		if err := rows.Scan(&rev.StoreID, &prevID, &jobID, &schemaKey, &dataJson); err != nil {
+			return nil, err
+		}
+		rev.PrevStoreID, rev.JobID, rev.SchemaKey = prevID.String, jobID.String, schemaKey.String
+		if err := json.Unmarshal(dataJson, &rev.Object); err != nil {
+			log.Printf("Failed to unmarshal object data for key: %s, error: %v", key, err)
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
+
+// Stats counts the keys, revisions and bytes stored
+func (s *DBService) Stats() (types.StoreStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var stats types.StoreStats
+	err := s.SQL.statsStmt.QueryRowContext(ctx).Scan(&stats.Keys, &stats.DeletedKeys, &stats.Revisions, &stats.Bytes)
+	if err != nil {
+		log.Printf("Failed to get store statistics, error: %v", err)
+		return types.StoreStats{}, err
+	}
+	return stats, nil
+}
+
+// notFound wraps the sql.ErrNoRows of a miss in store.ErrNotFound, other errors are returned as is
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %w", store.ErrNotFound, err)
+	}
+	return err
+}
+
+// Delete permanently removes the metadata and every stored revision of a key.
+func (s *DBService) Delete(key string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Failed to begin delete for key: %s, error: %v", key, err)
+		return false
+	}
+	defer tx.Rollback()
+
+	sqlRes, err := s.SQL.metaDelStmt.Tx(ctx, tx).ExecContext(ctx, key)
+	if err != nil {
+		log.Printf("Failed to delete meta data for key: %s, error: %v", key, err)
+		return false
+	}
+	if _, err := s.SQL.dataDelStmt.Tx(ctx, tx).ExecContext(ctx, key); err != nil {
+		log.Printf("Failed to delete data for key: %s, error: %v", key, err)
+		return false
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit delete for key: %s, error: %v", key, err)
+		return false
+	}
+	rowsAffected, err := sqlRes.RowsAffected()
+	return err == nil && rowsAffected == 1
+}
+
 // Health checks the health of the database connection by pinging the database.
 // It returns a map with keys indicating various health statistics.
 func (s *DBService) Health() map[string]string {
@@ -226,7 +447,7 @@ func (s *DBService) Health() map[string]string {
 	if err != nil {
 		stats["status"] = "down"
 		stats["error"] = fmt.Sprintf("db down: %v", err)
-		log.Fatalf("db down: %v", err) // Log the error and terminate the program
+		log.Printf("db down: %v", err)
 		return stats
 	}
 
@@ -270,5 +491,15 @@ func (s *DBService) Health() map[string]string {
 // If an error occurs while closing the connection, it returns the error.
 func (s *DBService) Close() error {
 	log.Printf("Disconnecting from database: %s", s.DbUrl)
+	if dbInstance == s {
+		dbInstance = nil
+	}
+	if s.Snapshots {
+		s.stopSnapshots()
+		// Write a final snapshot so a graceful shutdown loses nothing
+		if err := s.Snapshot(context.Background()); err != nil {
+			log.Printf("Failed to write snapshot of database: %s, error: %v", s.DbUrl, err)
+		}
+	}
 	return s.DB.Close()
 }
@@ -0,0 +1,999 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cfjello/go-store/pkg/jobGraph"
+	"github.com/cfjello/go-store/pkg/metrics"
+	"github.com/cfjello/go-store/pkg/store"
+	"github.com/cfjello/go-store/pkg/types"
+)
+
+func TestOpenPersistsAcrossRestarts(t *testing.T) {
+	dbUrl := "file:" + filepath.Join(t.TempDir(), "store", "go-store.db")
+	opts := Options{DbUrl: dbUrl, Flags: ";PRAGMA journal_mode=WAL;PRAGMA busy_timeout=5000;"}
+
+	db, err := Open(opts)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	if !db.SetMeta("person", types.MetaData{Key: "person", SchemaKey: "person", Oper: "set"}) {
+		t.Fatal("SetMeta() failed")
+	}
+	if !db.SetData("person", types.SetArgs{Key: "person", SchemaKey: "person", Object: map[string]interface{}{"name": "Ada"}}, nil) {
+		t.Fatal("SetData() failed")
+	}
+	var journalMode string
+	if err := db.DB.QueryRow("PRAGMA journal_mode").Scan(&journalMode); err != nil || journalMode != "wal" {
+		t.Errorf("expected journal_mode wal, got %q (%v)", journalMode, err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	db, err = Open(opts)
+	if err != nil {
+		t.Fatalf("reopening failed: %v", err)
+	}
+	defer db.Close()
+
+	meta, err := db.GetMeta("person", "person")
+	if err != nil || meta.Oper != "set" {
+		t.Errorf("expected meta data to survive a restart, got %+v (%v)", meta, err)
+	}
+	storeID, err := db.GetCurrStoreID("person")
+	if err != nil {
+		t.Fatalf("expected a store ID to survive a restart: %v", err)
+	}
+	obj, err := db.GetData("person", storeID)
+	if err != nil {
+		t.Fatalf("expected data to survive a restart: %v", err)
+	}
+	if obj.(map[string]interface{})["name"] != "Ada" {
+		t.Errorf("unexpected object after restart: %v", obj)
+	}
+}
+
+func TestOpenInMemoryIsPrivate(t *testing.T) {
+	first, err := Open(Options{InMemory: true})
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer first.Close()
+	second, err := Open(Options{InMemory: true})
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer second.Close()
+
+	if !first.SetMeta("person", types.MetaData{Key: "person"}) {
+		t.Fatal("SetMeta() failed")
+	}
+	if _, err := first.GetMeta("person", ""); err != nil {
+		t.Errorf("expected meta data in the first database: %v", err)
+	}
+	if _, err := second.GetMeta("person", ""); err == nil {
+		t.Error("expected in-memory databases to be isolated")
+	}
+}
+
+func TestOptionsFromEnv(t *testing.T) {
+	t.Setenv("SQLITE_DB_URL", "file:/tmp/go-store.db")
+	t.Setenv("SQLITE_DB_FLAGS", ";PRAGMA foreign_keys=ON;")
+	t.Setenv("SQLITE_DB_MODE", "disk")
+
+	opts := OptionsFromEnv()
+	if opts.InMemory || opts.DbUrl != "file:/tmp/go-store.db" || opts.Flags != ";PRAGMA foreign_keys=ON;" {
+		t.Errorf("unexpected options: %+v", opts)
+	}
+
+	t.Setenv("SQLITE_DB_MODE", "memory")
+	if !OptionsFromEnv().InMemory {
+		t.Error("expected SQLITE_DB_MODE=memory to select the in-memory database")
+	}
+}
+
+func TestSnapshotAndRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.db")
+	opts := Options{DbUrl: "file:" + path, Snapshot: true}
+
+	db, err := Open(opts)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	if !db.SetData("person", types.SetArgs{Key: "person", Object: map[string]interface{}{"name": "Ada"}}, nil) {
+		t.Fatal("SetData() failed")
+	}
+	if err := db.Snapshot(context.Background()); err != nil {
+		t.Fatalf("Snapshot() failed: %v", err)
+	}
+	if db.LastSnapshot().IsZero() {
+		t.Error("expected LastSnapshot() to be set")
+	}
+	if !db.SetData("animal", types.SetArgs{Key: "animal", Object: map[string]interface{}{"name": "Cat"}}, nil) {
+		t.Fatal("SetData() failed")
+	}
+	if err := db.Restore(context.Background()); err != nil {
+		t.Fatalf("Restore() failed: %v", err)
+	}
+	if _, err := db.GetCurrStoreID("animal"); err == nil {
+		t.Error("expected Restore() to discard rows written after the snapshot")
+	}
+	if !db.SetData("animal", types.SetArgs{Key: "animal", Object: map[string]interface{}{"name": "Dog"}}, nil) {
+		t.Fatal("SetData() failed")
+	}
+	// Close writes a final snapshot
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	db, err = Open(opts)
+	if err != nil {
+		t.Fatalf("reopening failed: %v", err)
+	}
+	defer db.Close()
+	for _, key := range []string{"person", "animal"} {
+		if _, err := db.GetCurrStoreID(key); err != nil {
+			t.Errorf("expected %s to be loaded from the snapshot: %v", key, err)
+		}
+	}
+}
+
+func TestPeriodicSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.db")
+	db, err := Open(Options{DbUrl: "file:" + path, Snapshot: true, SnapshotInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer db.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for db.LastSnapshot().IsZero() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("expected a periodic snapshot at %s: %v", path, err)
+	}
+}
+
+func TestGetRevisionsAndDelete(t *testing.T) {
+	db, err := Open(Options{InMemory: true})
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer db.Close()
+
+	db.SetMeta("person", types.MetaData{Key: "person", SchemaKey: "person"})
+	for _, name := range []string{"Ada", "Grace", "Barbara"} {
+		db.SetData("person", types.SetArgs{Key: "person", SchemaKey: "person", Object: map[string]interface{}{"name": name}}, nil)
+	}
+
+	revs, err := db.GetRevisions("person", types.HistoryOpts{})
+	if err != nil || len(revs) != 3 {
+		t.Fatalf("expected three revisions, got %v (%v)", revs, err)
+	}
+	page, err := db.GetRevisions("person", types.HistoryOpts{Desc: true, Limit: 1, Cursor: revs[2].StoreID})
+	if err != nil || len(page) != 1 || page[0].StoreID != revs[1].StoreID {
+		t.Errorf("expected the revision before the cursor, got %v (%v)", page, err)
+	}
+	if !db.Delete("person") {
+		t.Fatal("Delete() failed")
+	}
+	if revs, _ := db.GetRevisions("person", types.HistoryOpts{}); len(revs) != 0 {
+		t.Errorf("expected no revisions after Delete(), got %v", revs)
+	}
+	if _, err := db.GetMeta("person", ""); err == nil {
+		t.Error("expected meta data to be deleted")
+	}
+}
+
+func TestEventLog(t *testing.T) {
+	db, err := Open(Options{InMemory: true})
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer db.Close()
+
+	if last, err := db.LastEventID(); err != nil || last != "" {
+		t.Errorf("expected an empty change log, got %q (%v)", last, err)
+	}
+	for _, id := range []string{"01A", "01B", "01C"} {
+		if !db.AppendEvent(types.Event{ID: id, Oper: "set", Key: "person", SchemaKey: "person", StoreID: id}) {
+			t.Fatalf("AppendEvent(%s) failed", id)
+		}
+	}
+	if db.AppendEvent(types.Event{ID: "01B", Oper: "set", Key: "person"}) {
+		t.Error("expected a duplicate event ID to be refused")
+	}
+
+	events, err := db.GetEvents("01A", 0)
+	if err != nil || len(events) != 2 || events[0].ID != "01B" || events[1].SchemaKey != "person" {
+		t.Errorf("unexpected events after 01A: %+v (%v)", events, err)
+	}
+	if events, _ := db.GetEvents("", 1); len(events) != 1 || events[0].ID != "01A" {
+		t.Errorf("expected the limit to apply, got %+v", events)
+	}
+	if last, err := db.LastEventID(); err != nil || last != "01C" {
+		t.Errorf("expected the last event ID 01C, got %q (%v)", last, err)
+	}
+
+	// A revision is stored together with its event, or not at all
+	dup := types.Event{ID: "01C", Oper: "set", Key: "person", StoreID: "01C"}
+	if db.SetData("person", types.SetArgs{Key: "person", StoreID: "01C", Object: map[string]interface{}{"name": "Ada"}}, &dup) {
+		t.Error("expected SetData() to fail with a duplicate event ID")
+	}
+	if _, err := db.GetData("person", "01C"); err == nil {
+		t.Error("expected no revision stored without its event")
+	}
+	event := types.Event{ID: "01D", Oper: "set", Key: "person", StoreID: "01D"}
+	if !db.SetData("person", types.SetArgs{Key: "person", StoreID: "01D", Object: map[string]interface{}{"name": "Ada"}}, &event) {
+		t.Fatal("SetData() failed")
+	}
+	if last, _ := db.LastEventID(); last != "01D" {
+		t.Errorf("expected the event of the revision to be logged, got %q", last)
+	}
+
+	if n, err := db.PruneEvents("01C"); err != nil || n != 2 {
+		t.Errorf("expected 2 events pruned, got %d (%v)", n, err)
+	}
+	if events, _ := db.GetEvents("", 0); len(events) != 2 || events[0].ID != "01C" {
+		t.Errorf("expected the events from 01C on, got %+v", events)
+	}
+}
+
+func TestJobs(t *testing.T) {
+	db, err := Open(Options{InMemory: true})
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer db.Close()
+
+	start := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i, jobID := range []string{"job-1", "job-2", "job-3"} {
+		started := start.Add(time.Duration(i) * time.Hour).Add(123456 * time.Nanosecond)
+		if !db.SetJob(types.Job{JobID: jobID, Status: "running", Started: started, Updated: started}) {
+			t.Fatalf("SetJob(%s) failed", jobID)
+		}
+	}
+	if !db.SetJob(types.Job{JobID: "job-2", Status: "done", Started: start.Add(time.Hour)}) {
+		t.Fatal("updating a job failed")
+	}
+	if job, err := db.GetJob("job-2"); err != nil || job.Status != "done" {
+		t.Errorf("expected the job status to be replaced, got %+v (%v)", job, err)
+	}
+
+	jobs, err := db.GetJobs(start, start.Add(2*time.Hour))
+	if err != nil || len(jobs) != 2 || jobs[0].JobID != "job-1" || jobs[1].JobID != "job-2" {
+		t.Errorf("unexpected jobs in range: %+v (%v)", jobs, err)
+	}
+	var id, parent, notUsed int
+	var detail string
+	err = db.DB.QueryRow("EXPLAIN QUERY PLAN "+db.SQL.JobSelRange, 0, 1).Scan(&id, &parent, &notUsed, &detail)
+	if err != nil || !strings.Contains(detail, "idx_job_started") {
+		t.Errorf("expected the jobs in a range to be found with the index, got %q (%v)", detail, err)
+	}
+
+	for _, key := range []string{"person", "city"} {
+		if !db.SetData(key, types.SetArgs{Key: key, JobID: "job-1", Object: map[string]interface{}{"key": key}}, nil) {
+			t.Fatalf("SetData(%s) failed", key)
+		}
+	}
+	storeIDs, err := db.GetJobStoreIDs("job-1")
+	if err != nil || len(storeIDs) != 2 {
+		t.Fatalf("expected 2 storeIDs, got %v (%v)", storeIDs, err)
+	}
+	revisions, err := db.GetJobRevisions("job-1")
+	if err != nil || len(revisions) != 2 || revisions[1].Key != "city" || revisions[1].StoreID != storeIDs[1] {
+		t.Errorf("unexpected job revisions: %+v (%v)", revisions, err)
+	}
+}
+
+func TestGraphs(t *testing.T) {
+	db, err := Open(Options{InMemory: true})
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer db.Close()
+
+	graph := jobGraph.Graph{GraphID: "report", TopNode: "load", Nodes: []jobGraph.Node{{Name: "load", Consumes: []string{"orders"}}}}
+	if !db.SetGraph(graph) {
+		t.Fatal("SetGraph() failed")
+	}
+	graph.Nodes[0].Produces = []string{"sales"}
+	if !db.SetGraph(graph) {
+		t.Fatal("replacing a graph failed")
+	}
+	loaded, err := db.GetGraph("report")
+	if err != nil || len(loaded.Nodes) != 1 || len(loaded.Nodes[0].Produces) != 1 {
+		t.Errorf("expected the replaced graph, got %+v (%v)", loaded, err)
+	}
+	var topNode string
+	if err := db.DB.QueryRow("SELECT top_node FROM job_graph WHERE graph_id = 'report'").Scan(&topNode); err != nil || topNode != "load" {
+		t.Errorf("expected top_node load, got %q (%v)", topNode, err)
+	}
+	if graphs, err := db.GetGraphs(); err != nil || len(graphs) != 1 {
+		t.Errorf("expected one graph, got %+v (%v)", graphs, err)
+	}
+	if !db.DeleteGraph("report") || db.DeleteGraph("report") {
+		t.Error("expected DeleteGraph() to remove the graph once")
+	}
+}
+
+func TestStats(t *testing.T) {
+	db, err := Open(Options{InMemory: true})
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer db.Close()
+
+	db.SetMeta("person", types.MetaData{Key: "person", Oper: "set"})
+	db.SetMeta("city", types.MetaData{Key: "city", Oper: "del"})
+	db.SetData("person", types.SetArgs{Key: "person", Object: map[string]interface{}{"name": "Ada"}}, nil)
+	db.SetData("person", types.SetArgs{Key: "person", Object: map[string]interface{}{"name": "Grace"}}, nil)
+
+	stats, err := db.Stats()
+	want := types.StoreStats{Keys: 1, DeletedKeys: 1, Revisions: 2, Bytes: int64(len(`{"name":"Ada"}`) + len(`{"name":"Grace"}`))}
+	if err != nil || stats != want {
+		t.Errorf("expected %+v, got %+v (%v)", want, stats, err)
+	}
+}
+
+func TestStatementMetrics(t *testing.T) {
+	db, err := Open(Options{InMemory: true})
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer db.Close()
+	db.SetData("person", types.SetArgs{Key: "person", Object: map[string]interface{}{"name": "Ada"}}, nil)
+
+	families, err := metrics.Registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather() failed: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != "gostore_sql_statement_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetValue() == "DataInsert" && metric.GetHistogram().GetSampleCount() > 0 {
+					return
+				}
+			}
+		}
+	}
+	t.Error("expected the latency of the DataInsert statement to be recorded")
+}
+
+func TestChecks(t *testing.T) {
+	dbUrl := "file:" + filepath.Join(t.TempDir(), "go-store.db")
+	db, err := Open(Options{DbUrl: dbUrl})
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer db.Close()
+
+	if !db.SQL.CheckTables() {
+		t.Error("expected CheckTables() to find all the tables")
+	}
+	checks := db.Checks(context.Background())
+	if names := checkNames(checks); names != "database schema disk" {
+		t.Fatalf("unexpected checks: %s", names)
+	}
+	for _, check := range checks {
+		if check.Status != types.HealthUp {
+			t.Errorf("expected %s to be up, got %+v", check.Name, check)
+		}
+	}
+
+	db.MinFreeBytes = math.MaxUint64
+	if _, err := db.DB.Exec("DROP TABLE event"); err != nil {
+		t.Fatalf("failed to drop the event table: %v", err)
+	}
+	checks = db.Checks(context.Background())
+	if checks[1].Status != types.HealthDown || checks[1].Message != "missing tables: event" {
+		t.Errorf("expected the schema check to fail, got %+v", checks[1])
+	}
+	if checks[2].Status != types.HealthDown {
+		t.Errorf("expected the disk check to fail, got %+v", checks[2])
+	}
+
+	db.DB.Close()
+	checks = db.Checks(context.Background())
+	if names := checkNames(checks); names != "database disk" || checks[0].Status != types.HealthDown {
+		t.Errorf("expected the database check to fail, got %+v", checks)
+	}
+	if health := db.Health(); health["status"] != "down" {
+		t.Errorf("expected Health() to report the database down, got %v", health)
+	}
+}
+
+func TestSnapshotCheck(t *testing.T) {
+	dbUrl := "file:" + filepath.Join(t.TempDir(), "go-store.db")
+	db, err := Open(Options{DbUrl: dbUrl, Snapshot: true, SnapshotInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer db.Close()
+
+	checks := db.Checks(context.Background())
+	if names := checkNames(checks); names != "database schema disk snapshot" || checks[3].Status != types.HealthUp {
+		t.Fatalf("expected a passing snapshot check, got %+v", checks)
+	}
+
+	db.opened = time.Now().Add(-4 * time.Hour)
+	if check := db.Checks(context.Background())[3]; check.Status != types.HealthDown {
+		t.Errorf("expected the snapshots to be stale, got %+v", check)
+	}
+	if err := db.Snapshot(context.Background()); err != nil {
+		t.Fatalf("Snapshot() failed: %v", err)
+	}
+	if check := db.Checks(context.Background())[3]; check.Status != types.HealthUp || check.Details["last"] == "" {
+		t.Errorf("expected a fresh snapshot, got %+v", check)
+	}
+}
+
+func checkNames(checks []types.HealthCheck) string {
+	names := make([]string, len(checks))
+	for i, check := range checks {
+		names[i] = check.Name
+	}
+	return strings.Join(names, " ")
+}
+
+func TestMetaOfKeyNamedLikeSchemaKey(t *testing.T) {
+	db, err := Open(Options{InMemory: true})
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	s := store.New(db)
+	defer s.Close()
+
+	// b is registered under the schema key a before a itself exists
+	metaB, err := s.Set(types.SetArgs{Key: "b", SchemaKey: "a", Object: map[string]interface{}{"name": "B"}})
+	if err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if s.IsRegistered("a") {
+		t.Error("expected a not to be registered by b")
+	}
+	if meta, err := s.GetMetaData("b", "a"); err != nil || meta.Key != "b" {
+		t.Errorf("expected the metadata of b, got %+v (%v)", meta, err)
+	}
+
+	metaA, err := s.Set(types.SetArgs{Key: "a", Object: map[string]interface{}{"name": "A"}})
+	if err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		// Whichever row SQLite finds first, the key itself wins
+		meta, err := s.GetMetaData("a", "")
+		if err != nil || meta.Key != "a" || meta.StoreID != metaA.StoreID {
+			t.Errorf("expected the metadata of a, got %+v (%v)", meta, err)
+		}
+		s.Set(types.SetArgs{Key: "b", Object: map[string]interface{}{"name": "B"}})
+	}
+	if meta, _ := s.GetMetaData("b", ""); meta.Key != "b" || meta.SchemaKey != "a" || meta.StoreID == metaB.StoreID {
+		t.Errorf("expected the latest metadata of b, got %+v", meta)
+	}
+	if obj, err := s.Get("", "a"); err != nil || obj.(map[string]interface{})["name"] != "A" {
+		t.Errorf("expected the object of a, got %v (%v)", obj, err)
+	}
+
+	// The lookup by schema key is answered from its index
+	rows, err := db.DB.Query("EXPLAIN QUERY PLAN "+db.SQL.MetaSelect, "c", "a", "c")
+	if err != nil {
+		t.Fatalf("EXPLAIN QUERY PLAN failed: %v", err)
+	}
+	var plan []string
+	for rows.Next() {
+		var id, parent, notUsed int
+		var detail string
+		rows.Scan(&id, &parent, &notUsed, &detail)
+		plan = append(plan, detail)
+	}
+	rows.Close()
+	if !strings.Contains(strings.Join(plan, "\n"), "idx_meta_schema_key") {
+		t.Errorf("expected the lookup to use idx_meta_schema_key, got %v", plan)
+	}
+}
+
+func TestGetWithStoreIDOfOtherKey(t *testing.T) {
+	db, err := Open(Options{InMemory: true})
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	s := store.New(db)
+	defer s.Close()
+
+	metaA, _ := s.Set(types.SetArgs{Key: "a", Object: map[string]interface{}{"name": "A"}})
+	metaB, _ := s.Set(types.SetArgs{Key: "b", Object: map[string]interface{}{"name": "B"}})
+	if obj, err := s.Get(metaB.StoreID, "a"); err == nil {
+		t.Errorf("expected a storeID of b not to get an object of a, got %v", obj)
+	}
+	if obj, err := s.Get(metaA.StoreID, "a"); err != nil || obj.(map[string]interface{})["name"] != "A" {
+		t.Errorf("expected the object of a, got %v (%v)", obj, err)
+	}
+}
+
+func TestMissesAreErrNotFound(t *testing.T) {
+	db, err := Open(Options{InMemory: true})
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer db.Close()
+
+	for name, backend := range map[string]store.Backend{"sqlite": db, "memory": store.NewMemBackend()} {
+		backend.SetData("a", types.SetArgs{Key: "a", StoreID: "01A", Object: map[string]interface{}{"name": "A"}}, nil)
+
+		_, errMeta := backend.GetMeta("nobody", "")
+		_, errData := backend.GetData("b", "01A")
+		_, errStoreID := backend.GetCurrStoreID("nobody")
+		_, errJob := backend.GetJob("no-job")
+		_, errGraph := backend.GetGraph("no-graph")
+		for op, err := range map[string]error{"GetMeta": errMeta, "GetData": errData, "GetCurrStoreID": errStoreID, "GetJob": errJob, "GetGraph": errGraph} {
+			if !errors.Is(err, store.ErrNotFound) {
+				t.Errorf("%s: expected %s to fail with ErrNotFound, got %v", name, op, err)
+			}
+		}
+		s := store.New(backend)
+		if _, err := s.Get("", "nobody"); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("%s: expected Get() of an unknown key to fail with ErrNotFound, got %v", name, err)
+		}
+	}
+}
+
+func TestUnRegisterKeyNamedLikeSchemaKey(t *testing.T) {
+	db, err := Open(Options{InMemory: true})
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	s := store.New(db)
+	defer s.Close()
+
+	s.Set(types.SetArgs{Key: "b", SchemaKey: "a", Object: map[string]interface{}{"name": "B"}})
+	if s.UnRegister("a") {
+		t.Error("expected UnRegister() of a key that does not exist to fail")
+	}
+	if _, err := s.GetMetaData("a", ""); err == nil {
+		t.Error("expected UnRegister() not to write metadata for a")
+	}
+
+	s.Set(types.SetArgs{Key: "a", Object: map[string]interface{}{"name": "A"}})
+	if !s.UnRegister("a") {
+		t.Fatal("UnRegister() failed")
+	}
+	if meta, err := s.GetMetaData("a", ""); err != nil || meta.Key != "a" || !store.IsDeleted(meta) {
+		t.Errorf("expected a to be soft deleted, got %+v (%v)", meta, err)
+	}
+	if !s.IsRegistered("b") {
+		t.Error("expected b to stay registered")
+	}
+}
+
+func TestRevisionColumnsOfOlderDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "go-store.db")
+	legacy, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", path, err)
+	}
+	for _, stmt := range []string{
+		"CREATE TABLE meta (meta_key TEXT, schema_key TEXT NOT NULL, meta_data TEXT NOT NULL, PRIMARY KEY(meta_key))",
+		"CREATE TABLE data (data_id TEXT, job_id TEXT, obj_data JSON NOT NULL, meta_key TEXT NOT NULL, PRIMARY KEY(data_id))",
+		`INSERT INTO meta VALUES ('ada', 'person', '{"key":"ada","schemaKey":"person"}')`,
+		`INSERT INTO data VALUES ('01A', '01A', '{"name":"Ada"}', 'ada'), ('01B', '01B', '{"name":"Ada L"}', 'ada'), ('01C', '01C', '{"name":"Grace"}', 'grace')`,
+	} {
+		if _, err := legacy.Exec(stmt); err != nil {
+			t.Fatalf("failed to create the legacy schema: %v", err)
+		}
+	}
+	legacy.Close()
+
+	db, err := Open(Options{DbUrl: "file:" + path})
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer db.Close()
+	if version, _ := currentVersion(context.Background(), db.DB); version != SchemaVersion() {
+		t.Errorf("expected the older database to be migrated to version %d, got %d", SchemaVersion(), version)
+	}
+
+	revisions, err := db.GetRevisions("ada", types.HistoryOpts{})
+	if err != nil || len(revisions) != 2 {
+		t.Fatalf("expected 2 revisions, got %v (%v)", revisions, err)
+	}
+	if revisions[0].PrevStoreID != "" || revisions[1].PrevStoreID != "01A" || revisions[1].SchemaKey != "person" {
+		t.Errorf("expected the revisions to be chained, got %+v", revisions)
+	}
+	if !db.SetData("grace", types.SetArgs{Key: "grace", StoreID: "01D", JobID: "01D", Object: map[string]interface{}{"name": "Grace H"}}, nil) {
+		t.Fatal("SetData() failed")
+	}
+	revisions, _ = db.GetRevisions("grace", types.HistoryOpts{})
+	if len(revisions) != 2 || revisions[0].SchemaKey != "grace" || revisions[1].PrevStoreID != "01C" {
+		t.Errorf("expected new revisions to extend the chain, got %+v", revisions)
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	dbUrl := "file:" + filepath.Join(t.TempDir(), "go-store.db")
+	db, err := Open(Options{DbUrl: dbUrl})
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	if version, err := currentVersion(context.Background(), db.DB); err != nil || version != SchemaVersion() {
+		t.Errorf("expected schema version %d, got %d (%v)", SchemaVersion(), version, err)
+	}
+	db.Close()
+
+	// A dry run of an up to date database opens it
+	db, err = Open(Options{DbUrl: dbUrl, DryRun: true})
+	if err != nil {
+		t.Fatalf("expected a dry run without pending migrations to open the database: %v", err)
+	}
+	db.Close()
+
+	// A failing migration is rolled back and stops Open
+	saved := migrations
+	t.Cleanup(func() { migrations = saved })
+	migrations = append(migrations[:len(migrations):len(migrations)],
+		Migration{Version: SchemaVersion() + 1, Name: "add tag", up: func(ctx context.Context, tx migrationTx) error {
+			if _, err := tx.ExecContext(ctx, "CREATE TABLE tag (name TEXT)"); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, "INSERT INTO no_such_table VALUES (1)")
+			return err
+		}},
+	)
+	if _, err := Open(Options{DbUrl: dbUrl, DryRun: true}); !errors.Is(err, ErrMigrationsPending) {
+		t.Errorf("expected the dry run to report the pending migration, got %v", err)
+	}
+	if _, err := Open(Options{DbUrl: dbUrl}); err == nil {
+		t.Fatal("expected the failing migration to stop Open()")
+	}
+	migrations = saved
+
+	db, err = Open(Options{DbUrl: dbUrl})
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	var tables int
+	db.DB.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'tag'").Scan(&tables)
+	if tables != 0 {
+		t.Error("expected the failed migration to be rolled back")
+	}
+
+	// A database migrated by a newer binary is refused
+	if _, err := db.DB.Exec("INSERT INTO schema_version (version, name, applied_at) VALUES (99, 'future', '')"); err != nil {
+		t.Fatalf("failed to record a future version: %v", err)
+	}
+	db.Close()
+	if _, err := Open(Options{DbUrl: dbUrl}); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("expected ErrSchemaTooNew, got %v", err)
+	}
+}
+
+func TestConcurrentMigrations(t *testing.T) {
+	dbUrl := "file:" + filepath.Join(t.TempDir(), "go-store.db")
+
+	// Processes that open a new database together all read version 0 before migrating
+	const processes = 4
+	errs := make(chan error, processes)
+	for i := 0; i < processes; i++ {
+		go func() {
+			db := sql.OpenDB(newConnector(dbUrl, nil))
+			defer db.Close()
+			_, err := migrate(context.Background(), db, false)
+			errs <- err
+		}()
+	}
+	for i := 0; i < processes; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("expected concurrent migrations to succeed, got %v", err)
+		}
+	}
+
+	db := sql.OpenDB(newConnector(dbUrl, nil))
+	defer db.Close()
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM schema_version").Scan(&count); err != nil || count != SchemaVersion() {
+		t.Errorf("expected each migration to be applied once, got %d (%v)", count, err)
+	}
+
+	// A migration applied by another process since the version was read is skipped
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if applied, err := applyMigration(context.Background(), conn, migrations[0]); err != nil || applied {
+		t.Errorf("expected an applied migration to be skipped, got %v (%v)", applied, err)
+	}
+}
+
+func TestIndexLookup(t *testing.T) {
+	db, err := Open(Options{InMemory: true})
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	s := store.New(db)
+	defer s.Close()
+
+	for i, name := range []string{"Ada", "Grace", "Edsger", "Alan"} {
+		obj := map[string]interface{}{"name": name, "age": 30 + 10*i}
+		if _, err := s.Set(types.SetArgs{Key: strings.ToLower(name), SchemaKey: "person", Object: obj}); err != nil {
+			t.Fatalf("Set() failed: %v", err)
+		}
+	}
+	s.Set(types.SetArgs{Key: "robot", Object: map[string]interface{}{"name": "Ada", "age": 1}})
+	index, err := s.CreateIndex("person", "$.age")
+	if err != nil {
+		t.Fatalf("CreateIndex() failed: %v", err)
+	}
+	if _, err := s.CreateIndex("person", "$.name"); err != nil {
+		t.Fatalf("CreateIndex() failed: %v", err)
+	}
+
+	revisions, err := s.Lookup(types.IndexLookup{SchemaKey: "person", Path: "$.age", From: 40, To: 60.0})
+	if err != nil || len(revisions) != 2 || revisions[0].Key != "grace" || revisions[1].Key != "edsger" {
+		t.Errorf("expected grace and edsger by age, got %+v (%v)", revisions, err)
+	}
+	revisions, _ = s.Lookup(types.IndexLookup{SchemaKey: "person", Path: "$.name", Equal: "Ada"})
+	if len(revisions) != 1 || revisions[0].Key != "ada" || revisions[0].SchemaKey != "person" {
+		t.Errorf("expected only the person named Ada, got %+v", revisions)
+	}
+
+	// The lookups are answered from the partial expression index
+	query, args, _ := lookupQuery(types.IndexLookup{SchemaKey: "person", Path: "$.age", Equal: 50, Latest: true})
+	rows, err := db.DB.Query("EXPLAIN QUERY PLAN "+query, args...)
+	if err != nil {
+		t.Fatalf("EXPLAIN QUERY PLAN failed: %v", err)
+	}
+	var plan []string
+	for rows.Next() {
+		var id, parent, notUsed int
+		var detail string
+		rows.Scan(&id, &parent, &notUsed, &detail)
+		plan = append(plan, detail)
+	}
+	rows.Close()
+	if !strings.Contains(plan[0], "USING INDEX "+index.Name) {
+		t.Errorf("expected the lookup to use %s, got %v", index.Name, plan)
+	}
+
+	if !s.DropIndex("person", "$.age") {
+		t.Fatal("DropIndex() failed")
+	}
+	var count int
+	db.DB.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = ?", index.Name).Scan(&count)
+	if count != 0 {
+		t.Error("expected DropIndex() to drop the SQLite index")
+	}
+}
+
+func TestQuery(t *testing.T) {
+	db, err := Open(Options{InMemory: true})
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	s := store.New(db)
+	defer s.Close()
+	mem := store.New(store.NewMemBackend())
+
+	// The same data in SQLite and in memory, with missing values, nulls, mixed types and deletes
+	objects := []struct {
+		key string
+		obj map[string]interface{}
+	}{
+		{"p/ada", map[string]interface{}{"name": "Ada", "age": 36, "tags": []interface{}{"math", "poetry"}}},
+		{"p/grace", map[string]interface{}{"name": "Grace", "age": 85, "tags": []interface{}{"navy"}}},
+		{"p/edsger", map[string]interface{}{"name": "Edsger", "age": nil}},
+		{"p/alan", map[string]interface{}{"name": "Alan", "age": 41, "admin": true}},
+		{"p/kurt", map[string]interface{}{"name": "Kurt", "age": "unknown"}},
+		{"p/john", map[string]interface{}{"name": "John", "age": 53}},
+		{"q/ada", map[string]interface{}{"name": "Ada", "age": 36}},
+		{"p/ada", map[string]interface{}{"name": "Ada", "age": 37, "tags": []interface{}{"math", "poetry"}}},
+	}
+	for _, o := range objects {
+		for _, st := range []*store.Store{s, mem} {
+			if _, err := st.Set(types.SetArgs{Key: o.key, SchemaKey: "person", Object: o.obj}); err != nil {
+				t.Fatalf("Set() failed: %v", err)
+			}
+		}
+	}
+	s.UnRegister("p/john")
+	mem.UnRegister("p/john")
+	if _, err := s.CreateIndex("person", "$.age"); err != nil {
+		t.Fatalf("CreateIndex() failed: %v", err)
+	}
+
+	queries := []types.Query{
+		{SchemaKey: "person"},
+		{KeyPrefix: "p/", Sort: []types.SortField{{Path: "$.age"}}},
+		{KeyGlob: "[pq]/a*", Sort: []types.SortField{{Path: "$.name", Desc: true}, {Path: "$.age"}}},
+		{Where: []types.Predicate{{Path: "$.age", Op: ">=", Value: 37}}, Sort: []types.SortField{{Path: "$.age", Desc: true}}},
+		{Where: []types.Predicate{{Path: "$.age", Op: "!=", Value: 37}}},
+		{Where: []types.Predicate{{Path: "$.age", Op: "exists"}}, Sort: []types.SortField{{Path: "$.age", Desc: true}}},
+		{Where: []types.Predicate{{Path: "$.tags", Op: "exists", Value: false}}},
+		{Where: []types.Predicate{{Path: "$.admin", Op: "=", Value: true}}},
+		{Where: []types.Predicate{{Path: "$.name", Op: "in", Value: []interface{}{"Ada", "Kurt"}}}, Sort: []types.SortField{{Path: "$.name"}}},
+		{Where: []types.Predicate{{Path: "$.tags", Op: "contains", Value: "navy"}}},
+		{Where: []types.Predicate{{Path: "$.name", Op: "contains", Value: "ra"}}},
+		{Sort: []types.SortField{{Path: "$.tags", Desc: true}, {Path: "storeId", Desc: true}}},
+	}
+	pages := func(st *store.Store, query types.Query) []string {
+		var keys []string
+		query.Limit = 2
+		for {
+			page, err := st.Query(query)
+			if err != nil {
+				t.Fatalf("Query(%+v) failed: %v", query, err)
+			}
+			for _, rev := range page.Results {
+				keys = append(keys, rev.Key)
+			}
+			if page.NextCursor == "" {
+				return keys
+			}
+			query.Cursor = page.NextCursor
+		}
+	}
+	for _, query := range queries {
+		got, want := pages(s, query), pages(mem, query)
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("query %+v: SQLite gave %v, memory gave %v", query, got, want)
+		}
+	}
+	if got := pages(s, types.Query{SchemaKey: "person"}); len(got) != 6 {
+		t.Errorf("expected the six live keys, got %v", got)
+	}
+
+	// A predicate on an indexed path of the schema is answered from the index
+	stmt, args, _ := queryStatement(types.Query{SchemaKey: "person",
+		Where: []types.Predicate{{Path: "$.age", Op: "=", Value: 41}},
+		Sort:  []types.SortField{{Path: "storeId"}}}, nil)
+	var plan []string
+	rows, err := db.DB.Query("EXPLAIN QUERY PLAN "+stmt, args...)
+	if err != nil {
+		t.Fatalf("EXPLAIN QUERY PLAN failed: %v", err)
+	}
+	for rows.Next() {
+		var id, parent, notUsed int
+		var detail string
+		rows.Scan(&id, &parent, &notUsed, &detail)
+		plan = append(plan, detail)
+	}
+	rows.Close()
+	if !strings.Contains(strings.Join(plan, "\n"), "USING INDEX jx_") {
+		t.Errorf("expected the query to use the index, got %v", plan)
+	}
+
+	// The database checks the predicates, the store passes its ValidationError on
+	var extErr *types.ExtError
+	for _, query := range []types.Query{
+		{Where: []types.Predicate{{Path: "$.name'; --", Op: "="}}},
+		{Where: []types.Predicate{{Path: "$.name", Op: "like", Value: "A%"}}},
+		{Where: []types.Predicate{{Path: "$.name", Op: "=", Value: map[string]any{"first": "Ada"}}}},
+		{Where: []types.Predicate{{Path: "$.name", Op: "in", Value: "Ada"}}},
+		{Where: []types.Predicate{{Path: "$.name", Op: "exists", Value: "yes"}}},
+		{Sort: []types.SortField{{Path: "name"}}},
+	} {
+		if _, err := s.Query(query); !errors.As(err, &extErr) || extErr.Name != "ValidationError" {
+			t.Errorf("expected a ValidationError for %+v, got %v", query, err)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	db, err := Open(Options{InMemory: true})
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	s := store.New(db)
+	defer s.Close()
+
+	label, comment := `$."rdfs:label"`, `$."rdfs:comment"`
+	if !db.fts5 {
+		// Built without -tags sqlite_fts5
+		if _, err := s.CreateSearchIndex("schema.org", label); !errors.Is(err, store.ErrSearchUnavailable) {
+			t.Errorf("expected ErrSearchUnavailable, got %v", err)
+		}
+		if _, err := s.Search(types.SearchQuery{SchemaKey: "schema.org", Text: "food"}); !errors.Is(err, store.ErrSearchUnavailable) {
+			t.Errorf("expected ErrSearchUnavailable, got %v", err)
+		}
+		t.Skip("SQLite was built without FTS5, run the tests with -tags sqlite_fts5")
+	}
+
+	set := func(key string, obj map[string]interface{}) {
+		t.Helper()
+		if _, err := s.Set(types.SetArgs{Key: key, SchemaKey: "schema.org", Object: obj}); err != nil {
+			t.Fatalf("Set() failed: %v", err)
+		}
+	}
+	keys := func(text string) []string {
+		t.Helper()
+		hits, err := s.Search(types.SearchQuery{SchemaKey: "schema.org", Text: text})
+		if err != nil {
+			t.Fatalf("Search(%q) failed: %v", text, err)
+		}
+		list := make([]string, len(hits))
+		for i, hit := range hits {
+			list[i] = hit.Key
+		}
+		return list
+	}
+	// Existing revisions are indexed when the index is created
+	set("schema:Restaurant", map[string]interface{}{"rdfs:label": "Restaurant", "rdfs:comment": "A restaurant."})
+	s.Set(types.SetArgs{Key: "other:Restaurant", Object: map[string]interface{}{"rdfs:label": "Restaurant"}})
+	if _, err := s.CreateSearchIndex("schema.org", label, comment); err != nil {
+		t.Fatalf("CreateSearchIndex() failed: %v", err)
+	}
+	set("schema:FoodEstablishment", map[string]interface{}{
+		"rdfs:label":   map[string]interface{}{"@language": "en", "@value": "FoodEstablishment"},
+		"rdfs:comment": "A food-related business.",
+	})
+	set("schema:Bakery", map[string]interface{}{"rdfs:label": "Bakery", "rdfs:comment": "A bakery <b>bakes</b> bread."})
+
+	hits, err := s.Search(types.SearchQuery{SchemaKey: "schema.org", Text: "restaurant"})
+	if err != nil || len(hits) != 1 || hits[0].Key != "schema:Restaurant" || hits[0].StoreID == "" ||
+		hits[0].Snippets[label] != "<mark>Restaurant</mark>" || hits[0].Snippets[comment] != "A <mark>restaurant</mark>." {
+		t.Fatalf("expected the schema.org restaurant with its snippets, got %+v (%v)", hits, err)
+	}
+	if got := keys("foodestab*"); len(got) != 1 || got[0] != "schema:FoodEstablishment" {
+		t.Errorf("expected a prefix to match the label inside an object, got %v", got)
+	}
+	hits, _ = s.Search(types.SearchQuery{SchemaKey: "schema.org", Text: "bakes"})
+	if len(hits) != 1 || hits[0].Snippets[comment] != "A bakery &lt;b&gt;<mark>bakes</mark>&lt;/b&gt; bread." || len(hits[0].Snippets) != 1 {
+		t.Errorf("expected an escaped snippet of the comment only, got %+v", hits)
+	}
+	if got := keys(`"bread" OR NEAR(`); len(got) != 0 {
+		t.Errorf("expected FTS5 syntax to be searched for as words, got %v", got)
+	}
+
+	// The index follows new revisions, soft deletes, hard deletes and schema changes
+	set("schema:Bakery", map[string]interface{}{"rdfs:label": "Bakery", "rdfs:comment": "A restaurant that bakes."})
+	if got := keys("restaurant"); len(got) != 2 || got[0] != "schema:Restaurant" {
+		t.Errorf("expected the restaurant to rank above the bakery, got %v", got)
+	}
+	if got := keys("bread"); len(got) != 0 {
+		t.Errorf("expected the old revision to be gone from the index, got %v", got)
+	}
+	s.UnRegister("schema:Restaurant")
+	if got := keys("restaurant"); len(got) != 1 || got[0] != "schema:Bakery" {
+		t.Errorf("expected a soft deleted key to be left out, got %v", got)
+	}
+	set("schema:Restaurant", map[string]interface{}{"rdfs:label": "Restaurant"})
+	s.Delete("schema:Bakery")
+	if got := keys("restaurant"); len(got) != 1 || got[0] != "schema:Restaurant" {
+		t.Errorf("expected the key set again and not the deleted one, got %v", got)
+	}
+
+	// Opened without FTS5 the triggers are dropped, and with FTS5 the index is rebuilt
+	db.fts5 = false
+	if err := db.syncSearchIndexes(context.Background()); err != nil {
+		t.Fatalf("syncSearchIndexes() failed: %v", err)
+	}
+	set("schema:Cafe", map[string]interface{}{"rdfs:label": "Cafe", "rdfs:comment": "A restaurant serving coffee."})
+	db.fts5 = true
+	if err := db.syncSearchIndexes(context.Background()); err != nil {
+		t.Fatalf("syncSearchIndexes() failed: %v", err)
+	}
+	if got := keys("restaurant"); len(got) != 2 {
+		t.Errorf("expected the rebuilt index to hold the cafe, got %v", got)
+	}
+
+	if !s.DropSearchIndex("schema.org") {
+		t.Fatal("DropSearchIndex() failed")
+	}
+	if _, err := s.Search(types.SearchQuery{SchemaKey: "schema.org", Text: "restaurant"}); !errors.Is(err, store.ErrNotIndexed) {
+		t.Errorf("expected ErrNotIndexed after DropSearchIndex(), got %v", err)
+	}
+	set("schema:Bar", map[string]interface{}{"rdfs:label": "Bar"})
+}
@@ -0,0 +1,8 @@
+//go:build !unix && !windows
+
+package database
+
+// diskFree is not implemented on this platform, the disk check is reported as unsupported
+func diskFree(path string) (uint64, error) {
+	return 0, errNoDiskFree
+}
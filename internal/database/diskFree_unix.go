@@ -0,0 +1,14 @@
+//go:build unix
+
+package database
+
+import "syscall"
+
+// diskFree returns the number of bytes available to unprivileged users in the file system of path
+func diskFree(path string) (uint64, error) {
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(path, &fs); err != nil {
+		return 0, err
+	}
+	return uint64(fs.Bavail) * uint64(fs.Bsize), nil
+}
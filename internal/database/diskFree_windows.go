@@ -0,0 +1,18 @@
+//go:build windows
+
+package database
+
+import "golang.org/x/sys/windows"
+
+// diskFree returns the number of bytes available to the user in the file system of path
+func diskFree(path string) (uint64, error) {
+	dir, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	var free uint64
+	if err := windows.GetDiskFreeSpaceEx(dir, &free, nil, nil); err != nil {
+		return 0, err
+	}
+	return free, nil
+}
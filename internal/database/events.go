@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/cfjello/go-store/pkg/types"
+)
+
+// AppendEvent adds an event to the durable change log
+func (s *DBService) AppendEvent(event types.Event) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := s.insertEvent(ctx, s.SQL.evtInsStmt, event); err != nil {
+		log.Printf("Failed to log event %s for key: %s, error: %v", event.ID, event.Key, err)
+		return false
+	}
+	return true
+}
+
+// insertEvent writes an event with stmt, the EventInsert statement on its own or bound to a transaction
+func (s *DBService) insertEvent(ctx context.Context, stmt *timedStmt, event types.Event) error {
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = stmt.ExecContext(ctx, event.ID, event.Key, event.Oper, string(eventJSON))
+	return err
+}
+
+// GetEvents gets at most limit events logged after the event with ID after, in ID order
+func (s *DBService) GetEvents(after string, limit int) ([]types.Event, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if limit <= 0 {
+		limit = -1 // no limit in SQLite
+	}
+	rows, err := s.SQL.evtSelStmt.QueryContext(ctx, after, limit)
+	if err != nil {
+		log.Printf("Failed to get events after: %s, error: %v", after, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []types.Event{}
+	for rows.Next() {
+		var eventJSON []byte
+		if err := rows.Scan(&eventJSON); err != nil {
+			return nil, err
+		}
+		var event types.Event
+		if err := json.Unmarshal(eventJSON, &event); err != nil {
+			log.Printf("Failed to unmarshal event after: %s, error: %v", after, err)
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// PruneEvents removes the events logged before the event with ID before and counts them
+func (s *DBService) PruneEvents(before string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sqlRes, err := s.SQL.evtDelStmt.ExecContext(ctx, before)
+	if err != nil {
+		log.Printf("Failed to prune the events before: %s, error: %v", before, err)
+		return 0, err
+	}
+	return sqlRes.RowsAffected()
+}
+
+// LastEventID gets the ID of the latest logged event, or an empty string
+func (s *DBService) LastEventID() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var eventID string
+	err := s.SQL.evtSelLastStmt.QueryRowContext(ctx).Scan(&eventID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return eventID, err
+}
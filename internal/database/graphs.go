@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/cfjello/go-store/pkg/jobGraph"
+)
+
+// SetGraph creates or replaces a job graph
+func (s *DBService) SetGraph(graph jobGraph.Graph) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	graphJSON, err := json.Marshal(graph)
+	if err != nil {
+		log.Printf("Failed to marshal job graph: %s, error: %v", graph.GraphID, err)
+		return false
+	}
+	if _, err := s.SQL.graphInsStmt.ExecContext(ctx, graph.GraphID, graph.TopNode, string(graphJSON)); err != nil {
+		log.Printf("Failed to set job graph: %s, error: %v", graph.GraphID, err)
+		return false
+	}
+	return true
+}
+
+// GetGraph gets a job graph
+func (s *DBService) GetGraph(graphID string) (jobGraph.Graph, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var graphJSON []byte
+	if err := s.SQL.graphSelStmt.QueryRowContext(ctx, graphID).Scan(&graphJSON); err != nil {
+		return jobGraph.Graph{}, notFound(err)
+	}
+	var graph jobGraph.Graph
+	if err := json.Unmarshal(graphJSON, &graph); err != nil {
+		log.Printf("Failed to unmarshal job graph: %s, error: %v", graphID, err)
+		return jobGraph.Graph{}, err
+	}
+	return graph, nil
+}
+
+// GetGraphs gets every job graph, ordered by graphID
+func (s *DBService) GetGraphs() ([]jobGraph.Graph, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := s.SQL.graphSelAllStmt.QueryContext(ctx)
+	if err != nil {
+		log.Printf("Failed to list job graphs, error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	graphs := []jobGraph.Graph{}
+	for rows.Next() {
+		var graphJSON []byte
+		if err := rows.Scan(&graphJSON); err != nil {
+			return nil, err
+		}
+		var graph jobGraph.Graph
+		if err := json.Unmarshal(graphJSON, &graph); err != nil {
+			return nil, err
+		}
+		graphs = append(graphs, graph)
+	}
+	return graphs, rows.Err()
+}
+
+// DeleteGraph removes a job graph
+func (s *DBService) DeleteGraph(graphID string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := s.SQL.graphDelStmt.ExecContext(ctx, graphID)
+	if err != nil {
+		log.Printf("Failed to delete job graph: %s, error: %v", graphID, err)
+		return false
+	}
+	n, err := res.RowsAffected()
+	return err == nil && n > 0
+}
@@ -0,0 +1,132 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cfjello/go-store/pkg/types"
+)
+
+// defaultMinFreeBytes is the default of DBService.MinFreeBytes
+const defaultMinFreeBytes = 64 << 20
+
+// errNoDiskFree is returned by diskFree on platforms where the free space is unknown
+var errNoDiskFree = errors.New("free disk space is not available on this platform")
+
+// staleSnapshots is the number of missed snapshot intervals after which the snapshots are stale
+const staleSnapshots = 3
+
+// Checks runs the readiness checks of the database: it must answer a ping, have all
+// the tables of the schema and, when it is kept on disk, have MinFreeBytes of free
+// disk space. In snapshot mode the last snapshot must be younger than a few intervals.
+// A failing check is reported as down, it never terminates the process.
+func (s *DBService) Checks(ctx context.Context) []types.HealthCheck {
+	checks := []types.HealthCheck{s.checkPing(ctx)}
+	if checks[0].Status == types.HealthUp {
+		checks = append(checks, s.checkSchema(ctx))
+	}
+	if !s.InMemory {
+		checks = append(checks, s.checkDisk())
+	}
+	if s.Snapshots && s.snapInterval > 0 {
+		checks = append(checks, s.checkSnapshot())
+	}
+	return checks
+}
+
+func (s *DBService) checkPing(ctx context.Context) types.HealthCheck {
+	check := types.HealthCheck{Name: "database", Status: types.HealthUp}
+	start := time.Now()
+	if err := s.DB.PingContext(ctx); err != nil {
+		check.Status = types.HealthDown
+		check.Message = fmt.Sprintf("db down: %v", err)
+		return check
+	}
+	dbStats := s.DB.Stats()
+	check.Details = map[string]string{
+		"latency":          time.Since(start).String(),
+		"open_connections": strconv.Itoa(dbStats.OpenConnections),
+		"in_use":           strconv.Itoa(dbStats.InUse),
+	}
+	return check
+}
+
+func (s *DBService) checkSchema(ctx context.Context) types.HealthCheck {
+	check := types.HealthCheck{Name: "schema", Status: types.HealthUp}
+	if s.SQL == nil {
+		check.Status = types.HealthDown
+		check.Message = "the SQL statements are not prepared"
+		return check
+	}
+	missing, err := s.SQL.MissingTables(ctx)
+	switch {
+	case err != nil:
+		check.Status = types.HealthDown
+		check.Message = fmt.Sprintf("failed to list the tables: %v", err)
+	case len(missing) > 0:
+		check.Status = types.HealthDown
+		check.Message = "missing tables: " + strings.Join(missing, ", ")
+	}
+	if version, err := currentVersion(ctx, s.DB); err == nil {
+		check.Details = map[string]string{"version": strconv.Itoa(version)}
+		if version != SchemaVersion() {
+			check.Status = types.HealthDown
+			check.Message = fmt.Sprintf("schema version %d, expected %d", version, SchemaVersion())
+		}
+	}
+	return check
+}
+
+func (s *DBService) checkDisk() types.HealthCheck {
+	check := types.HealthCheck{Name: "disk", Status: types.HealthUp}
+	dir := filepath.Dir(dbFilePath(s.DbUrl))
+	free, err := diskFree(dir)
+	if errors.Is(err, errNoDiskFree) {
+		check.Status = types.HealthUnsupported
+		check.Message = err.Error()
+		return check
+	}
+	if err != nil {
+		check.Status = types.HealthDown
+		check.Message = fmt.Sprintf("failed to read the free space of %s: %v", dir, err)
+		return check
+	}
+	check.Details = map[string]string{
+		"path":       dir,
+		"free_bytes": strconv.FormatUint(free, 10),
+		"min_bytes":  strconv.FormatUint(s.MinFreeBytes, 10),
+	}
+	if free < s.MinFreeBytes {
+		check.Status = types.HealthDown
+		check.Message = fmt.Sprintf("only %d bytes free in %s", free, dir)
+	}
+	return check
+}
+
+func (s *DBService) checkSnapshot() types.HealthCheck {
+	check := types.HealthCheck{Name: "snapshot", Status: types.HealthUp}
+	last := s.LastSnapshot()
+	since := last
+	if since.IsZero() {
+		// No snapshot has been written yet, count from when the database was opened
+		since = s.opened
+	}
+	age := time.Since(since)
+	check.Details = map[string]string{
+		"interval": s.snapInterval.String(),
+		"age":      age.Round(time.Millisecond).String(),
+	}
+	if !last.IsZero() {
+		check.Details["last"] = last.Format(time.RFC3339)
+	}
+	if age > staleSnapshots*s.snapInterval {
+		check.Status = types.HealthDown
+		check.Message = fmt.Sprintf("no snapshot written for %s", age.Round(time.Second))
+	}
+	return check
+}
@@ -0,0 +1,171 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/cfjello/go-store/pkg/metrics"
+	"github.com/cfjello/go-store/pkg/types"
+	"github.com/cfjello/go-store/pkg/util"
+)
+
+// SetIndex declares a JSON path index and creates the SQLite expression index behind it.
+// The index is partial on the schema key, so it only holds the revisions of that schema.
+func (s *DBService) SetIndex(index types.Index) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if !util.IsJSONPath(index.Path) || index.Name == "" {
+		log.Printf("Invalid index: %s on %s %s", index.Name, index.SchemaKey, index.Path)
+		return false
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Failed to begin index: %s, error: %v", index.Name, err)
+		return false
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO json_index (index_name, schema_key, path) VALUES (?, ?, ?) "+
+		"ON CONFLICT(index_name) DO NOTHING", index.Name, index.SchemaKey, index.Path)
+	if err != nil {
+		log.Printf("Failed to declare index: %s, error: %v", index.Name, err)
+		return false
+	}
+	// Building the index reads every revision, which is why the timeout is longer
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON data (%s, data_id) WHERE schema_key = %s",
+		quoteIdent(index.Name), pathExpr(index.Path), quoteLiteral(index.SchemaKey)))
+	if err != nil {
+		log.Printf("Failed to create index: %s, error: %v", index.Name, err)
+		return false
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit index: %s, error: %v", index.Name, err)
+		return false
+	}
+	return true
+}
+
+// GetIndexes gets the declared indexes, ordered by schemaKey and path
+func (s *DBService) GetIndexes() ([]types.Index, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := s.DB.QueryContext(ctx, "SELECT index_name, schema_key, path FROM json_index ORDER BY schema_key, path")
+	if err != nil {
+		log.Printf("Failed to get indexes, error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	indexes := []types.Index{}
+	for rows.Next() {
+		var index types.Index
+		if err := rows.Scan(&index.Name, &index.SchemaKey, &index.Path); err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, rows.Err()
+}
+
+// DeleteIndex removes a declared index and drops its SQLite index
+func (s *DBService) DeleteIndex(name string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Failed to begin dropping index: %s, error: %v", name, err)
+		return false
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM json_index WHERE index_name = ?", name)
+	if err != nil {
+		log.Printf("Failed to delete index: %s, error: %v", name, err)
+		return false
+	}
+	if rowsAffected, err := res.RowsAffected(); err != nil || rowsAffected != 1 {
+		return false
+	}
+	if _, err := tx.ExecContext(ctx, "DROP INDEX IF EXISTS "+quoteIdent(name)); err != nil {
+		log.Printf("Failed to drop index: %s, error: %v", name, err)
+		return false
+	}
+	return tx.Commit() == nil
+}
+
+// LookupIndex gets the revisions whose value at lookup.Path matches, in value and storeID order.
+// The schema key and path are written into the query, not bound, so that SQLite can match
+// it with the partial expression index created by SetIndex.
+func (s *DBService) LookupIndex(lookup types.IndexLookup) ([]types.Revision, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query, args, err := lookupQuery(lookup)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	metrics.ObserveSQL("IndexLookup", start, err)
+	if err != nil {
+		log.Printf("Failed to look up %s %s, error: %v", lookup.SchemaKey, lookup.Path, err)
+		return nil, err
+	}
+	defer rows.Close()
+	return scanRevisions(rows)
+}
+
+// lookupQuery builds the SQL of an index lookup
+func lookupQuery(lookup types.IndexLookup) (string, []any, error) {
+	if !util.IsJSONPath(lookup.Path) {
+		return "", nil, fmt.Errorf("invalid JSON path %q", lookup.Path)
+	}
+	expr := pathExpr(lookup.Path)
+	where := []string{"schema_key = " + quoteLiteral(lookup.SchemaKey)}
+	var args []any
+	for _, bound := range []struct {
+		op    string
+		value any
+	}{{"=", lookup.Equal}, {">=", lookup.From}, {"<", lookup.To}} {
+		if bound.value == nil {
+			continue
+		}
+		switch bound.value.(type) {
+		case map[string]any, []any:
+			return "", nil, fmt.Errorf("cannot look up %s by an object or array", lookup.Path)
+		}
+		where = append(where, expr+" "+bound.op+" ?")
+		args = append(args, bound.value)
+	}
+	if lookup.Latest {
+		where = append(where,
+			"data_id = (SELECT MAX(latest.data_id) FROM data AS latest WHERE latest.meta_key = data.meta_key)",
+			"meta_key IN (SELECT meta_key FROM meta WHERE json_extract(meta_data, '$.oper') IS NOT 'del')")
+	}
+	query := "SELECT data_id, prev_id, job_id, meta_key, schema_key, obj_data FROM data WHERE " +
+		strings.Join(where, " AND ") + " ORDER BY " + expr + ", data_id"
+	if lookup.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, lookup.Limit)
+	}
+	return query, args, nil
+}
+
+// pathExpr is the expression an index is built on, lookups must use the very same text
+func pathExpr(path string) string {
+	return "json_extract(obj_data, " + quoteLiteral(path) + ")"
+}
+
+func quoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,134 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/cfjello/go-store/pkg/types"
+)
+
+// SetJob creates or replaces the status record of a job
+func (s *DBService) SetJob(job types.Job) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	jobJSON, err := json.Marshal(job)
+	if err != nil {
+		log.Printf("Failed to marshal job: %s, error: %v", job.JobID, err)
+		return false
+	}
+	if _, err := s.SQL.jobInsStmt.ExecContext(ctx, job.JobID, string(jobJSON), unixSeconds(job.Started)); err != nil {
+		log.Printf("Failed to set job: %s, error: %v", job.JobID, err)
+		return false
+	}
+	return true
+}
+
+// GetJob gets the status record of a job
+func (s *DBService) GetJob(jobID string) (types.Job, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var jobJSON []byte
+	if err := s.SQL.jobSelAllStmt.QueryRowContext(ctx, jobID).Scan(&jobJSON); err != nil {
+		return types.Job{}, notFound(err)
+	}
+	var job types.Job
+	if err := json.Unmarshal(jobJSON, &job); err != nil {
+		log.Printf("Failed to unmarshal job: %s, error: %v", jobID, err)
+		return types.Job{}, err
+	}
+	return job, nil
+}
+
+// GetJobs gets the jobs started in [from, to), ordered by start time
+func (s *DBService) GetJobs(from time.Time, to time.Time) ([]types.Job, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := s.SQL.jobSelRangeStmt.QueryContext(ctx, unixSeconds(from), unixSeconds(to))
+	if err != nil {
+		log.Printf("Failed to list jobs from %v to %v, error: %v", from, to, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []types.Job{}
+	for rows.Next() {
+		var jobJSON []byte
+		if err := rows.Scan(&jobJSON); err != nil {
+			return nil, err
+		}
+		var job types.Job
+		if err := json.Unmarshal(jobJSON, &job); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// GetJobStoreIDs gets the storeIDs of the revisions stored under a jobID, in storeID order
+func (s *DBService) GetJobStoreIDs(jobID string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := s.SQL.dataIdByJobStmt.QueryContext(ctx, jobID)
+	if err != nil {
+		log.Printf("Failed to get store IDs for job: %s, error: %v", jobID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	storeIDs := []string{}
+	for rows.Next() {
+		var storeID string
+		if err := rows.Scan(&storeID); err != nil {
+			return nil, err
+		}
+		storeIDs = append(storeIDs, storeID)
+	}
+	return storeIDs, rows.Err()
+}
+
+// GetJobRevisions gets the revisions stored under a jobID, in storeID order
+func (s *DBService) GetJobRevisions(jobID string) ([]types.Revision, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := s.SQL.dataByJobStmt.QueryContext(ctx, jobID)
+	if err != nil {
+		log.Printf("Failed to get revisions for job: %s, error: %v", jobID, err)
+		return nil, err
+	}
+	defer rows.Close()
+	return scanRevisions(rows)
+}
+
+// scanRevisions reads rows of data_id, prev_id, job_id, meta_key, schema_key and obj_data
+func scanRevisions(rows *sql.Rows) ([]types.Revision, error) {
+	revisions := []types.Revision{}
+	for rows.Next() {
+		var rev types.Revision
+		var prevID, jobID, schemaKey sql.NullString
+		var dataJson []byte
+		if err := rows.Scan(&rev.StoreID, &prevID, &jobID, &rev.Key, &schemaKey, &dataJson); err != nil {
+			return nil, err
+		}
+		rev.PrevStoreID, rev.JobID, rev.SchemaKey = prevID.String, jobID.String, schemaKey.String
+		if err := json.Unmarshal(dataJson, &rev.Object); err != nil {
+			log.Printf("Failed to unmarshal object data for storeID: %s, error: %v", rev.StoreID, err)
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
+
+// unixSeconds converts a time to the fractional seconds used by SQLite unixepoch()
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixMilli()) / 1000
+}
@@ -0,0 +1,161 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// ErrSchemaTooNew is returned when the database was migrated by a newer binary
+var ErrSchemaTooNew = errors.New("the database schema is newer than this binary")
+
+// ErrMigrationsPending is returned by a dry run that found migrations to apply
+var ErrMigrationsPending = errors.New("database migrations are pending")
+
+// Migration is a forward-only change of the database schema.
+// Each migration runs in its own transaction together with the update of schema_version.
+type Migration struct {
+	Version int
+	Name    string
+	up      func(ctx context.Context, tx migrationTx) error
+}
+
+// migrationTx runs the statements of a migration, inside the transaction opened by migrate
+type migrationTx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// migrations lists every schema change in version order, append new ones at the end
+// and never change a migration that has been released
+var migrations = []Migration{
+	{Version: 1, Name: "create tables", up: createTables},
+	{Version: 2, Name: "chain revisions by storeID", up: addRevisionColumns},
+	{Version: 3, Name: "declare JSON path indexes", up: createIndexTable},
+	{Version: 4, Name: "declare full-text search indexes", up: createSearchIndexTable},
+	{Version: 5, Name: "index metadata by schema key", up: createMetaSchemaIndex},
+	{Version: 6, Name: "index jobs by start time", up: addJobStartedColumn},
+}
+
+// SchemaVersion is the schema version this binary migrates databases to
+func SchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// migrate brings the schema up to SchemaVersion and returns the migrations it applied,
+// leaving out those another process applied meanwhile.
+// With dryRun nothing is written, the pending migrations are returned instead.
+// It refuses databases whose schema version is newer than SchemaVersion.
+func migrate(ctx context.Context, db *sql.DB, dryRun bool) ([]Migration, error) {
+	current, err := currentVersion(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the schema version: %w", err)
+	}
+	if current > SchemaVersion() {
+		return nil, fmt.Errorf("%w: version %d, this binary supports up to %d", ErrSchemaTooNew, current, SchemaVersion())
+	}
+
+	var pending []Migration
+	for _, m := range migrations {
+		if m.Version > current {
+			pending = append(pending, m)
+		}
+	}
+	if dryRun || len(pending) == 0 {
+		return pending, nil
+	}
+
+	// A connection of its own keeps each migration and its transaction together
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect for the migrations: %w", err)
+	}
+	defer conn.Close()
+
+	var applied []Migration
+	for _, m := range pending {
+		ok, err := applyMigration(ctx, conn, m)
+		if err != nil {
+			return applied, fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+		}
+		if ok {
+			applied = append(applied, m)
+			log.Printf("Applied database migration %d: %s", m.Version, m.Name)
+		}
+	}
+	return applied, nil
+}
+
+// applyMigration applies m unless it has been applied by now, it reports whether it did.
+// BEGIN IMMEDIATE takes the write lock before the version is read again, so another
+// process that migrates the same database waits for it rather than applying m twice.
+func applyMigration(ctx context.Context, conn *sql.Conn, m Migration) (bool, error) {
+	if _, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+		return false, err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			conn.ExecContext(context.Background(), "ROLLBACK")
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_version (
+			version INTEGER,
+			name TEXT NOT NULL,
+			applied_at TEXT NOT NULL,
+			PRIMARY KEY(version)
+		)
+	`); err != nil {
+		return false, fmt.Errorf("failed to create the schema_version table: %w", err)
+	}
+	var current sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT MAX(version) FROM schema_version").Scan(&current); err != nil {
+		return false, fmt.Errorf("failed to read the schema version: %w", err)
+	}
+	if int(current.Int64) >= m.Version {
+		return false, nil
+	}
+
+	if err := m.up(ctx, conn); err != nil {
+		return false, err
+	}
+	_, err := conn.ExecContext(ctx, "INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)",
+		m.Version, m.Name, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return false, err
+	}
+	if _, err := conn.ExecContext(ctx, "COMMIT"); err != nil {
+		return false, err
+	}
+	committed = true
+	return true, nil
+}
+
+// currentVersion reads the highest applied migration, 0 if none has been applied
+func currentVersion(ctx context.Context, db *sql.DB) (int, error) {
+	var exists int
+	err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_version'").Scan(&exists)
+	if err != nil || exists == 0 {
+		return 0, err
+	}
+	var version sql.NullInt64
+	if err := db.QueryRowContext(ctx, "SELECT MAX(version) FROM schema_version").Scan(&version); err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+// migrationNames formats migrations for log and error messages
+func migrationNames(list []Migration) string {
+	names := make([]string, len(list))
+	for i, m := range list {
+		names[i] = fmt.Sprintf("%d (%s)", m.Version, m.Name)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,201 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cfjello/go-store/pkg/metrics"
+	"github.com/cfjello/go-store/pkg/types"
+	"github.com/cfjello/go-store/pkg/util"
+)
+
+// Query gets the latest revisions of the live keys that match query, in query.Sort order
+// and starting after the sort values in after. Like LookupIndex the schema key and paths
+// are written into the SQL, so predicates and sorts on an indexed path can use the index.
+func (s *DBService) Query(query types.Query, after []any) ([]types.Revision, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stmt, args, err := queryStatement(query, after)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	rows, err := s.DB.QueryContext(ctx, stmt, args...)
+	metrics.ObserveSQL("Query", start, err)
+	if err != nil {
+		log.Printf("Failed to query, error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+	return scanRevisions(rows)
+}
+
+// queryStatement builds the SQL of a query, the sort of which must end with "storeId"
+func queryStatement(query types.Query, after []any) (string, []any, error) {
+	where := []string{
+		"data_id = (SELECT MAX(latest.data_id) FROM data AS latest WHERE latest.meta_key = data.meta_key)",
+		"meta_key IN (SELECT meta_key FROM meta WHERE json_extract(meta_data, '$.oper') IS NOT 'del')",
+	}
+	var args []any
+	if query.SchemaKey != "" {
+		where = append(where, "schema_key = "+quoteLiteral(query.SchemaKey))
+	}
+	if query.KeyPrefix != "" {
+		where = append(where, "substr(meta_key, 1, ?) = ?")
+		args = append(args, utf8.RuneCountInString(query.KeyPrefix), query.KeyPrefix)
+	}
+	if query.KeyGlob != "" {
+		where = append(where, "meta_key GLOB ?")
+		args = append(args, query.KeyGlob)
+	}
+	for _, pred := range query.Where {
+		cond, condArgs, err := predicateSQL(pred)
+		if err != nil {
+			return "", nil, err
+		}
+		where = append(where, cond)
+		args = append(args, condArgs...)
+	}
+
+	sort := query.Sort
+	if len(sort) == 0 || sort[len(sort)-1].Path != "storeId" {
+		return "", nil, fmt.Errorf("the sort of a query must end with storeId")
+	}
+	order := make([]string, len(sort))
+	exprs := make([]string, len(sort))
+	for i, field := range sort {
+		switch {
+		case field.Path == "storeId":
+			exprs[i] = "data_id"
+		case util.IsJSONPath(field.Path):
+			exprs[i] = pathExpr(field.Path)
+		default:
+			return "", nil, invalidQuery(fmt.Sprintf("invalid sort path %q, expected storeId or a JSON path", field.Path),
+				map[string]string{"path": field.Path})
+		}
+		order[i] = exprs[i]
+		if field.Desc {
+			order[i] += " DESC"
+		}
+	}
+	if after != nil {
+		if len(after) != len(sort) {
+			return "", nil, fmt.Errorf("expected %d sort values to start after, got %d", len(sort), len(after))
+		}
+		cond, condArgs := afterSQL(sort, exprs, after)
+		where = append(where, cond)
+		args = append(args, condArgs...)
+	}
+
+	stmt := "SELECT data_id, prev_id, job_id, meta_key, schema_key, obj_data FROM data WHERE " +
+		strings.Join(where, " AND ") + " ORDER BY " + strings.Join(order, ", ")
+	if query.Limit > 0 {
+		stmt += " LIMIT ?"
+		args = append(args, query.Limit)
+	}
+	return stmt, args, nil
+}
+
+// invalidQuery is the ValidationError of a query that cannot be run
+func invalidQuery(message string, info map[string]string) error {
+	return &types.ExtError{Name: "ValidationError", Message: message, Info: info}
+}
+
+// predicateSQL translates a predicate into a condition on obj_data, a predicate with an
+// invalid path, operator or value is a ValidationError
+func predicateSQL(pred types.Predicate) (string, []any, error) {
+	if !util.IsJSONPath(pred.Path) {
+		return "", nil, invalidQuery(fmt.Sprintf("invalid JSON path %q, expected member names and array indexes like $.address.city", pred.Path),
+			map[string]string{"path": pred.Path})
+	}
+	info := map[string]string{"path": pred.Path, "op": pred.Op}
+	expr := pathExpr(pred.Path)
+	switch pred.Op {
+	case types.OpEqual, types.OpNotEqual, types.OpLess, types.OpLessEqual, types.OpGreater, types.OpGreaterEqual:
+		if !types.IsScalar(pred.Value) {
+			return "", nil, invalidQuery(fmt.Sprintf("%s %s needs a string, number or boolean value", pred.Path, pred.Op), info)
+		}
+		return expr + " " + pred.Op + " ?", []any{pred.Value}, nil
+	case types.OpIn:
+		list, ok := pred.Value.([]any)
+		for _, value := range list {
+			ok = ok && types.IsScalar(value)
+		}
+		if !ok {
+			return "", nil, invalidQuery(fmt.Sprintf("%s in needs a list of strings, numbers or booleans", pred.Path), info)
+		}
+		if len(list) == 0 {
+			return "0", nil, nil
+		}
+		return expr + " IN (" + strings.TrimSuffix(strings.Repeat("?, ", len(list)), ", ") + ")", list, nil
+	case types.OpExists:
+		if _, ok := pred.Value.(bool); pred.Value != nil && !ok {
+			return "", nil, invalidQuery(fmt.Sprintf("%s exists needs a boolean value or none", pred.Path), info)
+		}
+		// json_type tells a missing path from a JSON null, json_extract does not
+		if pred.Value == false {
+			return "json_type(obj_data, " + quoteLiteral(pred.Path) + ") IS NULL", nil, nil
+		}
+		return "json_type(obj_data, " + quoteLiteral(pred.Path) + ") IS NOT NULL", nil, nil
+	case types.OpContains:
+		if !types.IsScalar(pred.Value) {
+			return "", nil, invalidQuery(fmt.Sprintf("%s contains needs a string, number or boolean value", pred.Path), info)
+		}
+		path := quoteLiteral(pred.Path)
+		return "(CASE json_type(obj_data, " + path + ")" +
+			" WHEN 'array' THEN EXISTS (SELECT 1 FROM json_each(obj_data, " + path + ") AS element WHERE element.value = ?)" +
+			" WHEN 'text' THEN typeof(?) = 'text' AND instr(" + expr + ", ?) > 0" +
+			" ELSE 0 END)", []any{pred.Value, pred.Value, pred.Value}, nil
+	}
+	return "", nil, invalidQuery(fmt.Sprintf("unknown operator %q, expected one of =, !=, <, <=, >, >=, in, exists, contains", pred.Op), info)
+}
+
+// afterSQL is the keyset condition for the rows that sort after the values in after.
+// SQLite sorts NULL, which is what a missing path extracts to, before any other value.
+func afterSQL(sort []types.SortField, exprs []string, after []any) (string, []any) {
+	var alternatives []string
+	var args []any
+	for i := range sort {
+		var terms []string
+		var termArgs []any
+		for j := 0; j < i; j++ {
+			terms = append(terms, exprs[j]+" IS ?")
+			termArgs = append(termArgs, sqlValue(after[j]))
+		}
+		switch {
+		case !sort[i].Desc && after[i] == nil:
+			terms = append(terms, exprs[i]+" IS NOT NULL")
+		case !sort[i].Desc:
+			terms = append(terms, exprs[i]+" > ?")
+			termArgs = append(termArgs, sqlValue(after[i]))
+		case after[i] == nil:
+			// nothing sorts after NULL in descending order
+			continue
+		default:
+			terms = append(terms, "("+exprs[i]+" < ? OR "+exprs[i]+" IS NULL)")
+			termArgs = append(termArgs, sqlValue(after[i]))
+		}
+		alternatives = append(alternatives, strings.Join(terms, " AND "))
+		args = append(args, termArgs...)
+	}
+	if len(alternatives) == 0 {
+		return "0", nil
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")", args
+}
+
+// sqlValue binds objects and arrays as the JSON text that json_extract returns for them
+func sqlValue(value any) any {
+	switch value.(type) {
+	case map[string]any, []any:
+		text, _ := json.Marshal(value)
+		return string(text)
+	}
+	return value
+}
@@ -0,0 +1,344 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/cfjello/go-store/pkg/metrics"
+	"github.com/cfjello/go-store/pkg/store"
+	"github.com/cfjello/go-store/pkg/types"
+	"github.com/cfjello/go-store/pkg/util"
+)
+
+// Full-text search keeps an FTS5 table per indexed schema, with a column per indexed path,
+// and a table that numbers its keys so the FTS5 rows can be replaced by rowid. Triggers
+// on the data and meta tables keep both in sync with the latest revisions of the live keys.
+//
+// FTS5 is only compiled into go-sqlite3 with the sqlite_fts5 build tag, without it the
+// search methods fail with store.ErrSearchUnavailable.
+
+// hasFTS5 reports whether the SQLite library was compiled with FTS5
+func hasFTS5(ctx context.Context, db *sql.DB) bool {
+	var used bool
+	err := db.QueryRowContext(ctx, "SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&used)
+	return err == nil && used
+}
+
+// searchTable names the FTS5 table of a schema, its key table and triggers add a suffix
+func searchTable(schemaKey string) string {
+	sum := sha256.Sum256([]byte(schemaKey))
+	return "fts_" + hex.EncodeToString(sum[:8])
+}
+
+// searchText is the text of a path in obj: a string as is, and the strings found
+// anywhere inside an array or object joined by spaces
+func searchText(obj string, path string) string {
+	return "(SELECT group_concat(value, ' ') FROM json_tree(" + obj + ", " + quoteLiteral(path) + ") WHERE type = 'text')"
+}
+
+// SetSearchIndex creates or replaces the full-text index of a schema and fills it with the
+// latest revisions of its live keys
+func (s *DBService) SetSearchIndex(index types.SearchIndex) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if !s.fts5 {
+		return store.ErrSearchUnavailable
+	}
+	if len(index.Paths) == 0 {
+		return fmt.Errorf("no paths to index for %s", index.SchemaKey)
+	}
+	for _, path := range index.Paths {
+		if !util.IsJSONPath(path) {
+			return fmt.Errorf("invalid JSON path %q", path)
+		}
+	}
+	paths, err := json.Marshal(index.Paths)
+	if err != nil {
+		return err
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	table := searchTable(index.SchemaKey)
+	if err := dropSearchObjects(ctx, tx, table); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO search_index (schema_key, table_name, paths) VALUES (?, ?, ?) "+
+		"ON CONFLICT(schema_key) DO UPDATE SET table_name = excluded.table_name, paths = excluded.paths",
+		index.SchemaKey, table, string(paths))
+	if err != nil {
+		return err
+	}
+	if err := createSearchObjects(ctx, tx, table, index); err != nil {
+		log.Printf("Failed to create search index for %s, error: %v", index.SchemaKey, err)
+		return err
+	}
+	return tx.Commit()
+}
+
+// createSearchObjects creates the tables and triggers of a search index and fills them
+func createSearchObjects(ctx context.Context, tx *sql.Tx, table string, index types.SearchIndex) error {
+	ident, keys := quoteIdent(table), quoteIdent(table+"_keys")
+	columns := make([]string, len(index.Paths))
+	newTexts := make([]string, len(index.Paths))
+	dataTexts := make([]string, len(index.Paths))
+	for i, path := range index.Paths {
+		columns[i] = fmt.Sprintf("c%d", i)
+		newTexts[i] = searchText("NEW.obj_data", path)
+		dataTexts[i] = searchText("data.obj_data", path)
+	}
+	schemaKey := quoteLiteral(index.SchemaKey)
+	rowOf := func(key string) string {
+		return "(SELECT id FROM " + keys + " WHERE meta_key = " + key + ")"
+	}
+	stmts := []string{
+		"CREATE VIRTUAL TABLE " + ident + " USING fts5(" + strings.Join(columns, ", ") + ", tokenize = 'unicode61 remove_diacritics 2')",
+		"CREATE TABLE " + keys + " (id INTEGER PRIMARY KEY, meta_key TEXT NOT NULL UNIQUE)",
+		// A new revision replaces the text of its key, or removes it if the key moved to another schema
+		"CREATE TRIGGER " + quoteIdent(table+"_insert") + " AFTER INSERT ON data BEGIN" +
+			" DELETE FROM " + ident + " WHERE rowid = " + rowOf("NEW.meta_key") + ";" +
+			" INSERT OR IGNORE INTO " + keys + " (meta_key) SELECT NEW.meta_key WHERE NEW.schema_key = " + schemaKey + ";" +
+			" INSERT INTO " + ident + " (rowid, " + strings.Join(columns, ", ") + ")" +
+			" SELECT " + rowOf("NEW.meta_key") + ", " + strings.Join(newTexts, ", ") + " WHERE NEW.schema_key = " + schemaKey + ";" +
+			" END",
+		"CREATE TRIGGER " + quoteIdent(table+"_delete") + " AFTER DELETE ON data BEGIN" +
+			" DELETE FROM " + ident + " WHERE rowid = " + rowOf("OLD.meta_key") + ";" +
+			" DELETE FROM " + keys + " WHERE meta_key = OLD.meta_key;" +
+			" END",
+		// A soft delete takes the key out of the index until it is set again
+		"CREATE TRIGGER " + quoteIdent(table+"_softdel") + " AFTER UPDATE OF meta_data ON meta" +
+			" WHEN json_extract(NEW.meta_data, '$.oper') IS 'del' BEGIN" +
+			" DELETE FROM " + ident + " WHERE rowid = " + rowOf("NEW.meta_key") + ";" +
+			" END",
+		"INSERT INTO " + keys + " (meta_key) SELECT meta_key FROM meta WHERE schema_key = " + schemaKey +
+			" AND json_extract(meta_data, '$.oper') IS NOT 'del' ORDER BY meta_key",
+		"INSERT INTO " + ident + " (rowid, " + strings.Join(columns, ", ") + ")" +
+			" SELECT " + keys + ".id, " + strings.Join(dataTexts, ", ") + " FROM data JOIN " + keys + " ON " + keys + ".meta_key = data.meta_key" +
+			" WHERE data.schema_key = " + schemaKey +
+			" AND data.data_id = (SELECT MAX(latest.data_id) FROM data AS latest WHERE latest.meta_key = data.meta_key)",
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropSearchObjects drops the triggers and tables of a search index, if they exist
+func dropSearchObjects(ctx context.Context, tx *sql.Tx, table string) error {
+	for _, stmt := range []string{
+		"DROP TRIGGER IF EXISTS " + quoteIdent(table+"_insert"),
+		"DROP TRIGGER IF EXISTS " + quoteIdent(table+"_delete"),
+		"DROP TRIGGER IF EXISTS " + quoteIdent(table+"_softdel"),
+		"DROP TABLE IF EXISTS " + quoteIdent(table),
+		"DROP TABLE IF EXISTS " + quoteIdent(table+"_keys"),
+	} {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetSearchIndexes gets the full-text indexes, ordered by schemaKey
+func (s *DBService) GetSearchIndexes() ([]types.SearchIndex, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := s.DB.QueryContext(ctx, "SELECT schema_key, paths FROM search_index ORDER BY schema_key")
+	if err != nil {
+		log.Printf("Failed to get search indexes, error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	indexes := []types.SearchIndex{}
+	for rows.Next() {
+		var index types.SearchIndex
+		var paths string
+		if err := rows.Scan(&index.SchemaKey, &paths); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(paths), &index.Paths); err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, rows.Err()
+}
+
+// DeleteSearchIndex removes the full-text index of a schema
+func (s *DBService) DeleteSearchIndex(schemaKey string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if !s.fts5 {
+		log.Printf("Failed to drop search index: %s, error: %v", schemaKey, store.ErrSearchUnavailable)
+		return false
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Failed to begin dropping search index: %s, error: %v", schemaKey, err)
+		return false
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM search_index WHERE schema_key = ?", schemaKey)
+	if err != nil {
+		log.Printf("Failed to delete search index: %s, error: %v", schemaKey, err)
+		return false
+	}
+	if rowsAffected, err := res.RowsAffected(); err != nil || rowsAffected != 1 {
+		return false
+	}
+	if err := dropSearchObjects(ctx, tx, searchTable(schemaKey)); err != nil {
+		log.Printf("Failed to drop search index: %s, error: %v", schemaKey, err)
+		return false
+	}
+	return tx.Commit() == nil
+}
+
+// Search finds the live keys of query.SchemaKey whose indexed text matches every word of
+// query.Text, best bm25 rank first, with a highlighted snippet of each matching path
+func (s *DBService) Search(query types.SearchQuery) ([]types.SearchHit, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if !s.fts5 {
+		return nil, store.ErrSearchUnavailable
+	}
+	match := searchMatch(query.Text)
+	if match == "" {
+		return nil, fmt.Errorf("nothing to search for in %q", query.Text)
+	}
+	var paths string
+	err := s.DB.QueryRowContext(ctx, "SELECT paths FROM search_index WHERE schema_key = ?", query.SchemaKey).Scan(&paths)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w for full-text search on %s", store.ErrNotIndexed, query.SchemaKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var index types.SearchIndex
+	if err := json.Unmarshal([]byte(paths), &index.Paths); err != nil {
+		return nil, err
+	}
+
+	// The snippets are marked with control characters, which are replaced once the text is escaped
+	table := searchTable(query.SchemaKey)
+	ident, keys := quoteIdent(table), quoteIdent(table+"_keys")
+	snippets := make([]string, len(index.Paths))
+	for i := range index.Paths {
+		snippets[i] = fmt.Sprintf("snippet(%s, %d, char(1), char(2), '…', 16)", ident, i)
+	}
+	stmt := "SELECT k.meta_key, (SELECT MAX(data_id) FROM data WHERE data.meta_key = k.meta_key), bm25(" + ident + "), " +
+		strings.Join(snippets, ", ") + " FROM " + ident + " JOIN " + keys + " AS k ON k.id = " + ident + ".rowid" +
+		" WHERE " + ident + " MATCH ? ORDER BY bm25(" + ident + "), k.meta_key"
+	args := []any{match}
+	if query.Limit > 0 {
+		stmt += " LIMIT ?"
+		args = append(args, query.Limit)
+	}
+	start := time.Now()
+	rows, err := s.DB.QueryContext(ctx, stmt, args...)
+	metrics.ObserveSQL("Search", start, err)
+	if err != nil {
+		log.Printf("Failed to search %s, error: %v", query.SchemaKey, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	hits := []types.SearchHit{}
+	for rows.Next() {
+		var hit types.SearchHit
+		texts := make([]sql.NullString, len(index.Paths))
+		dest := []any{&hit.Key, &hit.StoreID, &hit.Rank}
+		for i := range texts {
+			dest = append(dest, &texts[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		hit.Snippets = make(map[string]string)
+		for i, text := range texts {
+			if strings.ContainsRune(text.String, '\x01') {
+				hit.Snippets[index.Paths[i]] = markSnippet(text.String)
+			}
+		}
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
+
+// searchMatch turns the words of a search into an FTS5 query that matches all of them,
+// each word is quoted so FTS5 operators in the text are searched for as words
+func searchMatch(text string) string {
+	var terms []string
+	for _, word := range strings.Fields(text) {
+		prefix := strings.HasSuffix(word, "*")
+		word = strings.TrimRight(word, "*")
+		if word == "" {
+			continue
+		}
+		term := `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+		if prefix {
+			term += "*"
+		}
+		terms = append(terms, term)
+	}
+	return strings.Join(terms, " ")
+}
+
+// markSnippet escapes a snippet for HTML and turns its match markers into <mark> elements
+func markSnippet(snippet string) string {
+	return strings.NewReplacer("\x01", "<mark>", "\x02", "</mark>").Replace(html.EscapeString(snippet))
+}
+
+// syncSearchIndexes runs when a database is opened. Without FTS5 the triggers of the
+// search indexes are dropped, as every write would fail on them, and with FTS5 the
+// indexes that lost their triggers that way are rebuilt.
+func (s *DBService) syncSearchIndexes(ctx context.Context) error {
+	indexes, err := s.GetSearchIndexes()
+	if err != nil || len(indexes) == 0 {
+		return err
+	}
+	for _, index := range indexes {
+		table := searchTable(index.SchemaKey)
+		if !s.fts5 {
+			log.Printf("Search index of %s is disabled, SQLite was built without FTS5 (build tag sqlite_fts5)", index.SchemaKey)
+			for _, trigger := range []string{"_insert", "_delete", "_softdel"} {
+				if _, err := s.DB.ExecContext(ctx, "DROP TRIGGER IF EXISTS "+quoteIdent(table+trigger)); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		var triggers int
+		err := s.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name IN (?, ?, ?)",
+			table+"_insert", table+"_delete", table+"_softdel").Scan(&triggers)
+		if err != nil {
+			return err
+		}
+		if triggers != 3 {
+			log.Printf("Rebuilding the search index of %s", index.SchemaKey)
+			if err := s.SetSearchIndex(index); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
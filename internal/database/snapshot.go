@@ -0,0 +1,162 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Snapshot writes a consistent copy of the database to the file named by DbUrl.
+// It is used by the snapshot mode, where the working database lives in memory.
+func (s *DBService) Snapshot(ctx context.Context) error {
+	if !s.Snapshots {
+		return errors.New("snapshots are only available in snapshot mode")
+	}
+	if err := s.SnapshotTo(ctx, dbFilePath(s.DbUrl)); err != nil {
+		return err
+	}
+	s.snapMu.Lock()
+	s.lastSnapshot = time.Now()
+	s.snapMu.Unlock()
+	return nil
+}
+
+// Restore replaces the contents of the database with the snapshot file named by DbUrl.
+func (s *DBService) Restore(ctx context.Context) error {
+	if !s.Snapshots {
+		return errors.New("snapshots are only available in snapshot mode")
+	}
+	return s.RestoreFrom(ctx, dbFilePath(s.DbUrl))
+}
+
+// LastSnapshot returns the time of the last successful snapshot, or the zero time.
+func (s *DBService) LastSnapshot() time.Time {
+	s.snapMu.Lock()
+	defer s.snapMu.Unlock()
+	return s.lastSnapshot
+}
+
+// SnapshotTo copies the database to path using the SQLite online backup API.
+// The copy is written to a temporary file first and then renamed into place,
+// so readers of path never see a partial snapshot.
+func (s *DBService) SnapshotTo(ctx context.Context, path string) error {
+	tmpPath := path + ".tmp"
+	os.Remove(tmpPath)
+
+	dst, err := openRawConn(tmpPath)
+	if err != nil {
+		return err
+	}
+	err = s.withRawConn(ctx, func(src *sqlite3.SQLiteConn) error {
+		return backup(dst, src)
+	})
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write snapshot %s: %w", path, err)
+	}
+
+	// Stale journal files would be applied on top of the new snapshot
+	os.Remove(path + "-wal")
+	os.Remove(path + "-shm")
+	return os.Rename(tmpPath, path)
+}
+
+// RestoreFrom replaces the contents of the database with the SQLite file at path.
+// It returns an error wrapping os.ErrNotExist if there is no such file.
+func (s *DBService) RestoreFrom(ctx context.Context, path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	src, err := openRawConn(path + "?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	err = s.withRawConn(ctx, func(dst *sqlite3.SQLiteConn) error {
+		return backup(dst, src)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to restore snapshot %s: %w", path, err)
+	}
+	log.Printf("Restored database from snapshot: %s", path)
+	return nil
+}
+
+func (s *DBService) startSnapshots(interval time.Duration) {
+	s.stopSnap = make(chan struct{})
+	s.snapDone = make(chan struct{})
+
+	go func() {
+		defer close(s.snapDone)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.Snapshot(context.Background()); err != nil {
+					log.Printf("Failed to write snapshot of database: %s, error: %v", s.DbUrl, err)
+				}
+			case <-s.stopSnap:
+				return
+			}
+		}
+	}()
+}
+
+func (s *DBService) stopSnapshots() {
+	if s.stopSnap == nil {
+		return
+	}
+	close(s.stopSnap)
+	<-s.snapDone
+	s.stopSnap = nil
+}
+
+// withRawConn runs fn on a dedicated driver connection of the pool.
+func (s *DBService) withRawConn(ctx context.Context, fn func(conn *sqlite3.SQLiteConn) error) error {
+	conn, err := s.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		sqliteConn, ok := driverConn.(*sqlite3.SQLiteConn)
+		if !ok {
+			return driver.ErrBadConn
+		}
+		return fn(sqliteConn)
+	})
+}
+
+// openRawConn opens a plain sqlite3 connection outside of any pool.
+func openRawConn(dsn string) (*sqlite3.SQLiteConn, error) {
+	conn, err := (&sqlite3.SQLiteDriver{}).Open("file:" + dsn)
+	if err != nil {
+		return nil, err
+	}
+	return conn.(*sqlite3.SQLiteConn), nil
+}
+
+// backup copies the main database of src into dst in a single step.
+func backup(dst *sqlite3.SQLiteConn, src *sqlite3.SQLiteConn) error {
+	b, err := dst.Backup("main", src, "main")
+	if err != nil {
+		return err
+	}
+	if _, err := b.Step(-1); err != nil {
+		b.Finish()
+		return err
+	}
+	return b.Finish()
+}
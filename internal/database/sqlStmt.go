@@ -1,7 +1,11 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"time"
+
+	"github.com/cfjello/go-store/pkg/metrics"
 )
 
 type SqlStmt struct {
@@ -14,77 +18,200 @@ type SqlStmt struct {
 	DataSelect   string
 	DataIdByType string
 	DataSelLast  string
+	DataRevAsc   string
+	DataRevDesc  string
+	DataDelete   string
+	MetaDelete   string
+	DataIdByJob  string
+	DataByJob    string
 	JobInsert    string
 	JobSelJob    string
+	JobSelRange  string
+	StoreStats   string
+	GraphInsert  string
+	GraphSelect  string
+	GraphSelAll  string
+	GraphDelete  string
+	EventInsert  string
+	EventSelect  string
+	EventSelLast string
+	EventDelete  string
 
 	db               *sql.DB
-	dataInsStmt      *sql.Stmt
-	dataSelStmt      *sql.Stmt
-	dataIdByTypeStmt *sql.Stmt
-	dataSelLastStmt  *sql.Stmt
-	metaInsStmt      *sql.Stmt
-	metaSelStmt      *sql.Stmt
+	dataInsStmt      *timedStmt
+	dataSelStmt      *timedStmt
+	dataIdByTypeStmt *timedStmt
+	dataSelLastStmt  *timedStmt
+	dataRevAscStmt   *timedStmt
+	dataRevDescStmt  *timedStmt
+	dataDelStmt      *timedStmt
+	metaInsStmt      *timedStmt
+	metaSelStmt      *timedStmt
+	metaDelStmt      *timedStmt
 	// metaSelInitStmt  *sql.Stmt
-	metaUpdStmt *sql.Stmt
+	metaUpdStmt *timedStmt
 	// metaUpdInitStmt  *sql.Stmt
-	jobInsStmt    *sql.Stmt
-	jobSelAllStmt *sql.Stmt
+	dataIdByJobStmt *timedStmt
+	dataByJobStmt   *timedStmt
+	jobInsStmt      *timedStmt
+	jobSelAllStmt   *timedStmt
+	jobSelRangeStmt *timedStmt
+	statsStmt       *timedStmt
+	graphInsStmt    *timedStmt
+	graphSelStmt    *timedStmt
+	graphSelAllStmt *timedStmt
+	graphDelStmt    *timedStmt
+	evtInsStmt      *timedStmt
+	evtSelStmt      *timedStmt
+	evtSelLastStmt  *timedStmt
+	evtDelStmt      *timedStmt
 }
 
 func NewSqlStmt(db *sql.DB) (*SqlStmt, error) {
 	s := &SqlStmt{
-		MetaInsert: "INSERT INTO meta (meta_key, schema_key, meta_data) VALUES (?, ?, ?)",
-		MetaSelect: "SELECT meta_data FROM meta WHERE meta_key = ? OR schema_key = ?",
+		MetaInsert: "INSERT INTO meta (meta_key, schema_key, meta_data) VALUES (?, ?, ?) " +
+			"ON CONFLICT(meta_key) DO UPDATE SET schema_key = excluded.schema_key, meta_data = excluded.meta_data",
+		// The key itself comes first, else the first key registered under the schema key
+		MetaSelect: "SELECT meta_data FROM meta WHERE meta_key = ? OR schema_key = ? ORDER BY meta_key = ? DESC, meta_key LIMIT 1",
 		// MetaSelInit: "SELECT init FROM meta WHERE meta_key = ?",
 		// MetaSelLast:  "SELECT meta_data FROM meta WHERE meta_key = ? ORDER BY rowid DESC LIMIT 1",
 		MetaUpdate: "UPDATE meta SET meta_data = ? WHERE meta_key = ?",
 		// MetaUpdInit:  "UPDATE meta SET init = ? WHERE meta_key = ?",
-		DataInsert:   "INSERT INTO data (data_id, job_id, meta_key, obj_data) VALUES (?, ?, ?, ? )",
-		DataSelect:   "SELECT * FROM data WHERE data_id = ?",
+		// The previous revision of the key is looked up while the insert holds the write lock
+		DataInsert: "INSERT INTO data (data_id, job_id, meta_key, schema_key, prev_id, obj_data) " +
+			"VALUES (?, ?, ?, ?, (SELECT MAX(data_id) FROM data WHERE meta_key = ?), ?)",
+		DataSelect:   "SELECT data_id, job_id, meta_key, obj_data FROM data WHERE data_id = ? AND meta_key = ?",
 		DataIdByType: "SELECT data_id FROM data WHERE meta_key = ? and job_id LIKE ?",
 		DataSelLast:  "SELECT data_id FROM data WHERE meta_key = ? ORDER BY data_id DESC LIMIT 1",
-		JobInsert:    "INSERT INTO job (job_id, data_id, job_data) VALUES (?, ?, ? )",
-		JobSelJob:    "SELECT * FROM job WHERE job_id = ?",
-		// DB:           db,
+		DataRevAsc:   "SELECT data_id, prev_id, job_id, schema_key, obj_data FROM data WHERE meta_key = ? AND data_id > ? ORDER BY data_id ASC LIMIT ?",
+		DataRevDesc:  "SELECT data_id, prev_id, job_id, schema_key, obj_data FROM data WHERE meta_key = ? AND data_id < ? ORDER BY data_id DESC LIMIT ?",
+		DataDelete:   "DELETE FROM data WHERE meta_key = ?",
+		MetaDelete:   "DELETE FROM meta WHERE meta_key = ?",
+		DataIdByJob:  "SELECT data_id FROM data WHERE job_id = ? ORDER BY data_id ASC",
+		DataByJob:    "SELECT data_id, prev_id, job_id, meta_key, schema_key, obj_data FROM data WHERE job_id = ? ORDER BY data_id ASC",
+		JobInsert: "INSERT INTO job (job_id, data_id, job_data, started) VALUES (?, NULL, ?, ?) " +
+			"ON CONFLICT(job_id) WHERE data_id IS NULL DO UPDATE SET job_data = excluded.job_data, started = excluded.started",
+		JobSelJob:   "SELECT job_data FROM job WHERE job_id = ? AND data_id IS NULL",
+		JobSelRange: "SELECT job_data FROM job WHERE data_id IS NULL AND started >= ? AND started < ? ORDER BY started ASC",
+		StoreStats: "SELECT " +
+			"(SELECT COUNT(*) FROM meta WHERE json_extract(meta_data, '$.oper') IS NOT 'del'), " +
+			"(SELECT COUNT(*) FROM meta WHERE json_extract(meta_data, '$.oper') IS 'del'), " +
+			"(SELECT COUNT(*) FROM data), " +
+			"(SELECT COALESCE(SUM(LENGTH(obj_data)), 0) FROM data)",
+		GraphInsert: "INSERT INTO job_graph (graph_id, top_node, graph_data) VALUES (?, ?, ?) " +
+			"ON CONFLICT(graph_id) DO UPDATE SET top_node = excluded.top_node, graph_data = excluded.graph_data",
+		GraphSelect:  "SELECT graph_data FROM job_graph WHERE graph_id = ?",
+		GraphSelAll:  "SELECT graph_data FROM job_graph ORDER BY graph_id ASC",
+		GraphDelete:  "DELETE FROM job_graph WHERE graph_id = ?",
+		EventInsert:  "INSERT INTO event (event_id, meta_key, oper, event_data) VALUES (?, ?, ?, ?)",
+		EventSelect:  "SELECT event_data FROM event WHERE event_id > ? ORDER BY event_id ASC LIMIT ?",
+		EventSelLast: "SELECT event_id FROM event ORDER BY event_id DESC LIMIT 1",
+		EventDelete:  "DELETE FROM event WHERE event_id < ?",
+		db:           db,
 	}
 
 	var err error
 
-	s.dataInsStmt, err = db.Prepare(s.DataInsert)
+	s.dataInsStmt, err = prepare(db, "DataInsert", s.DataInsert)
+	if err != nil {
+		return nil, err
+	}
+	s.dataSelStmt, err = prepare(db, "DataSelect", s.DataSelect)
+	if err != nil {
+		return nil, err
+	}
+	s.dataIdByTypeStmt, err = prepare(db, "DataIdByType", s.DataIdByType)
+	if err != nil {
+		return nil, err
+	}
+	s.dataSelLastStmt, err = prepare(db, "DataSelLast", s.DataSelLast)
 	if err != nil {
 		return nil, err
 	}
-	s.dataSelStmt, err = db.Prepare(s.DataSelect)
+	s.dataRevAscStmt, err = prepare(db, "DataRevAsc", s.DataRevAsc)
 	if err != nil {
 		return nil, err
 	}
-	s.dataIdByTypeStmt, err = db.Prepare(s.DataIdByType)
+	s.dataRevDescStmt, err = prepare(db, "DataRevDesc", s.DataRevDesc)
 	if err != nil {
 		return nil, err
 	}
-	s.dataSelLastStmt, err = db.Prepare(s.DataSelLast)
+	s.dataDelStmt, err = prepare(db, "DataDelete", s.DataDelete)
 	if err != nil {
 		return nil, err
 	}
-	s.metaInsStmt, err = db.Prepare(s.MetaInsert)
+	s.metaInsStmt, err = prepare(db, "MetaInsert", s.MetaInsert)
 	if err != nil {
 		return nil, err
 	}
-	s.metaSelStmt, err = db.Prepare(s.MetaSelect)
+	s.metaSelStmt, err = prepare(db, "MetaSelect", s.MetaSelect)
 	if err != nil {
 		return nil, err
 	}
 
-	s.metaUpdStmt, err = db.Prepare(s.MetaUpdate)
+	s.metaDelStmt, err = prepare(db, "MetaDelete", s.MetaDelete)
 	if err != nil {
 		return nil, err
 	}
 
-	s.jobInsStmt, err = db.Prepare(s.JobInsert)
+	s.metaUpdStmt, err = prepare(db, "MetaUpdate", s.MetaUpdate)
 	if err != nil {
 		return nil, err
 	}
-	s.jobSelAllStmt, err = db.Prepare(s.JobSelJob)
+
+	s.dataIdByJobStmt, err = prepare(db, "DataIdByJob", s.DataIdByJob)
+	if err != nil {
+		return nil, err
+	}
+	s.dataByJobStmt, err = prepare(db, "DataByJob", s.DataByJob)
+	if err != nil {
+		return nil, err
+	}
+	s.jobSelRangeStmt, err = prepare(db, "JobSelRange", s.JobSelRange)
+	if err != nil {
+		return nil, err
+	}
+	s.jobInsStmt, err = prepare(db, "JobInsert", s.JobInsert)
+	if err != nil {
+		return nil, err
+	}
+	s.jobSelAllStmt, err = prepare(db, "JobSelJob", s.JobSelJob)
+	if err != nil {
+		return nil, err
+	}
+	s.statsStmt, err = prepare(db, "StoreStats", s.StoreStats)
+	if err != nil {
+		return nil, err
+	}
+	s.graphInsStmt, err = prepare(db, "GraphInsert", s.GraphInsert)
+	if err != nil {
+		return nil, err
+	}
+	s.graphSelStmt, err = prepare(db, "GraphSelect", s.GraphSelect)
+	if err != nil {
+		return nil, err
+	}
+	s.graphSelAllStmt, err = prepare(db, "GraphSelAll", s.GraphSelAll)
+	if err != nil {
+		return nil, err
+	}
+	s.graphDelStmt, err = prepare(db, "GraphDelete", s.GraphDelete)
+	if err != nil {
+		return nil, err
+	}
+	s.evtInsStmt, err = prepare(db, "EventInsert", s.EventInsert)
+	if err != nil {
+		return nil, err
+	}
+	s.evtSelStmt, err = prepare(db, "EventSelect", s.EventSelect)
+	if err != nil {
+		return nil, err
+	}
+	s.evtSelLastStmt, err = prepare(db, "EventSelLast", s.EventSelLast)
+	if err != nil {
+		return nil, err
+	}
+	s.evtDelStmt, err = prepare(db, "EventDelete", s.EventDelete)
 	if err != nil {
 		return nil, err
 	}
@@ -92,10 +219,61 @@ func NewSqlStmt(db *sql.DB) (*SqlStmt, error) {
 	return s, nil
 }
 
+// timedStmt is a prepared statement that records its latency and errors under its SqlStmt field name
+type timedStmt struct {
+	*sql.Stmt
+	name string
+}
+
+func prepare(db *sql.DB, name string, query string) (*timedStmt, error) {
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return &timedStmt{Stmt: stmt, name: name}, nil
+}
+
+// Tx returns the statement bound to a transaction, keeping its name
+func (t *timedStmt) Tx(ctx context.Context, tx *sql.Tx) *timedStmt {
+	return &timedStmt{Stmt: tx.StmtContext(ctx, t.Stmt), name: t.name}
+}
+
+func (t *timedStmt) ExecContext(ctx context.Context, args ...any) (sql.Result, error) {
+	start := time.Now()
+	res, err := t.Stmt.ExecContext(ctx, args...)
+	metrics.ObserveSQL(t.name, start, err)
+	return res, err
+}
+
+// QueryContext only times the query up to the first row, not the iteration of the rows
+func (t *timedStmt) QueryContext(ctx context.Context, args ...any) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := t.Stmt.QueryContext(ctx, args...)
+	metrics.ObserveSQL(t.name, start, err)
+	return rows, err
+}
+
+func (t *timedStmt) QueryRowContext(ctx context.Context, args ...any) *sql.Row {
+	start := time.Now()
+	row := t.Stmt.QueryRowContext(ctx, args...)
+	metrics.ObserveSQL(t.name, start, row.Err())
+	return row
+}
+
+// schemaTables are the tables created by the migrations
+var schemaTables = []string{"data", "event", "job", "job_graph", "json_index", "meta", "schema_version", "search_index"}
+
+// CheckTables reports whether all the tables of the schema exist
 func (s *SqlStmt) CheckTables() bool {
-	rows, err := s.db.Query("SELECT name FROM sqlite_master WHERE type='table';")
+	missing, err := s.MissingTables(context.Background())
+	return err == nil && len(missing) == 0
+}
+
+// MissingTables lists the tables of the schema that do not exist
+func (s *SqlStmt) MissingTables(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT name FROM sqlite_master WHERE type='table';")
 	if err != nil {
-		return false
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -103,10 +281,19 @@ func (s *SqlStmt) CheckTables() bool {
 	for rows.Next() {
 		var name string
 		if err := rows.Scan(&name); err != nil {
-			return false
+			return nil, err
 		}
 		tables[name] = true
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	return tables["data"] && tables["job"] && tables["meta"] && tables["job_graph"]
+	var missing []string
+	for _, table := range schemaTables {
+		if !tables[table] {
+			missing = append(missing, table)
+		}
+	}
+	return missing, nil
 }
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/cfjello/go-store/pkg/jobGraph"
+)
+
+// listGraphsHandler lists the stored job graphs
+func (s *Server) listGraphsHandler(w http.ResponseWriter, r *http.Request) {
+	graphs, err := s.store.ListGraphs()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, graphs)
+}
+
+// putGraphHandler validates and stores the job graph in the request body,
+// an invalid or cyclic graph is answered with 422
+func (s *Server) putGraphHandler(w http.ResponseWriter, r *http.Request) {
+	var graph jobGraph.Graph
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(&graph); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid job graph: "+err.Error())
+		return
+	}
+	graph.GraphID = r.PathValue("graphId")
+	if err := graph.Validate(); err != nil {
+		var cycleErr *jobGraph.CycleError
+		if errors.As(err, &cycleErr) {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"error": err.Error(), "cycle": cycleErr.Nodes})
+			return
+		}
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	graph, err := s.store.SaveGraph(graph)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, graph)
+}
+
+// getGraphHandler returns a stored job graph
+func (s *Server) getGraphHandler(w http.ResponseWriter, r *http.Request) {
+	graph, err := s.store.GetGraph(r.PathValue("graphId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, graph)
+}
+
+// deleteGraphHandler removes a stored job graph
+func (s *Server) deleteGraphHandler(w http.ResponseWriter, r *http.Request) {
+	graphID := r.PathValue("graphId")
+	if !s.store.DeleteGraph(graphID) {
+		writeError(w, http.StatusNotFound, "job graph not found: "+graphID)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// affectedNodesHandler lists the nodes of a job graph that must rerun when
+// the keys given by ?key= receive a new revision
+func (s *Server) affectedNodesHandler(w http.ResponseWriter, r *http.Request) {
+	graph, err := s.store.GetGraph(r.PathValue("graphId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	nodes, err := graph.Affected(r.URL.Query()["key"]...)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string][]string{"nodes": nodes})
+}
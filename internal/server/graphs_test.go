@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/cfjello/go-store/pkg/jobGraph"
+)
+
+func TestGraphEndpoints(t *testing.T) {
+	_, server := newTestServer(t)
+	graphURL := server.URL + "/v1/graphs/report"
+
+	resp := sendJSON(t, http.MethodPut, graphURL, `{"nodes": [
+		{"name": "load", "consumes": ["orders"], "produces": ["sales"]},
+		{"name": "summarize", "consumes": ["sales"], "produces": ["summary"]}
+	]}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK; got %v", resp.Status)
+	}
+	var graph jobGraph.Graph
+	if err := json.NewDecoder(resp.Body).Decode(&graph); err != nil || graph.GraphID != "report" || graph.TopNode != "summarize" {
+		t.Errorf("unexpected graph: %+v (%v)", graph, err)
+	}
+
+	var affected map[string][]string
+	resp = getJSON(t, graphURL+"/affected?key=orders", &affected)
+	if resp.StatusCode != http.StatusOK || len(affected["nodes"]) != 2 {
+		t.Errorf("expected both nodes to be affected; got %v %v", resp.Status, affected)
+	}
+
+	resp = sendJSON(t, http.MethodPut, server.URL+"/v1/graphs/loop", `{"nodes": [
+		{"name": "a", "consumes": ["b"], "produces": ["a"]},
+		{"name": "b", "consumes": ["a"], "produces": ["b"]}
+	]}`)
+	var errBody struct {
+		Error string   `json:"error"`
+		Cycle []string `json:"cycle"`
+	}
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("expected status Unprocessable Entity for a cycle; got %v", resp.Status)
+	} else if err := json.NewDecoder(resp.Body).Decode(&errBody); err != nil || len(errBody.Cycle) != 2 {
+		t.Errorf("expected the cycle in the error body, got %+v (%v)", errBody, err)
+	}
+
+	var graphs []jobGraph.Graph
+	if resp := getJSON(t, server.URL+"/v1/graphs", &graphs); resp.StatusCode != http.StatusOK || len(graphs) != 1 {
+		t.Errorf("expected one graph; got %v %+v", resp.Status, graphs)
+	}
+	if resp := sendJSON(t, http.MethodDelete, graphURL, ""); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("expected status No Content; got %v", resp.Status)
+	}
+	if resp := getJSON(t, graphURL, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected status Not Found after delete; got %v", resp.Status)
+	}
+}
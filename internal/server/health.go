@@ -0,0 +1,55 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/cfjello/go-store/pkg/types"
+)
+
+// readyTimeout bounds the time the readiness checks may take
+const readyTimeout = 2 * time.Second
+
+// liveHandler reports that the process is serving requests, it does not look at the database
+func (s *Server) liveHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, types.HealthReport{
+		Status: types.HealthUp,
+		Checks: []types.HealthCheck{{
+			Name:    "process",
+			Status:  types.HealthUp,
+			Details: map[string]string{"uptime": time.Since(s.started).Round(time.Second).String()},
+		}},
+	})
+}
+
+// readyHandler runs the readiness checks, it answers 503 Service Unavailable if any is down
+func (s *Server) readyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	report := s.ready(ctx)
+	status := http.StatusOK
+	if report.Status != types.HealthUp {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+// ready runs the cheap readiness checks of the database, such as its ping and schema, within ctx.
+// The statistics of the store are too heavy for a probe, they are left to the monitor.
+func (s *Server) ready(ctx context.Context) types.HealthReport {
+	report := types.HealthReport{Status: types.HealthUp}
+	if s.db == nil {
+		// The store is kept in memory, there is nothing that can go down
+		report.Checks = []types.HealthCheck{{Name: "store", Status: types.HealthUp}}
+		return report
+	}
+	report.Checks = s.db.Checks(ctx)
+	for _, check := range report.Checks {
+		if check.Status == types.HealthDown {
+			report.Status = types.HealthDown
+		}
+	}
+	return report
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cfjello/go-store/internal/database"
+	"github.com/cfjello/go-store/pkg/store"
+	"github.com/cfjello/go-store/pkg/types"
+)
+
+func TestHealthProbes(t *testing.T) {
+	db, err := database.Open(database.Options{InMemory: true})
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	s := &Server{db: db, store: store.New(db)}
+	server := httptest.NewServer(s.RegisterRoutes())
+	t.Cleanup(server.Close)
+
+	var report types.HealthReport
+	if resp := getJSON(t, server.URL+"/health/ready", &report); resp.StatusCode != http.StatusOK || report.Status != types.HealthUp {
+		t.Fatalf("expected the server to be ready; got %v %+v", resp.Status, report)
+	}
+	if len(report.Checks) != 2 || report.Checks[0].Name != "database" || report.Checks[1].Name != "schema" {
+		t.Errorf("expected the database and schema checks; got %+v", report.Checks)
+	}
+
+	// A database that is gone makes the server unready, it does not stop it
+	db.DB.Close()
+	report = types.HealthReport{}
+	if resp := getJSON(t, server.URL+"/health/ready", &report); resp.StatusCode != http.StatusServiceUnavailable || report.Status != types.HealthDown {
+		t.Errorf("expected 503 Service Unavailable; got %v %+v", resp.Status, report)
+	}
+	if resp := getJSON(t, server.URL+"/health", nil); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 Service Unavailable; got %v", resp.Status)
+	}
+	report = types.HealthReport{}
+	if resp := getJSON(t, server.URL+"/health/live", &report); resp.StatusCode != http.StatusOK || report.Status != types.HealthUp {
+		t.Errorf("expected the server to be live; got %v %+v", resp.Status, report)
+	}
+}
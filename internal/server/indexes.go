@@ -0,0 +1,96 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/cfjello/go-store/pkg/store"
+	"github.com/cfjello/go-store/pkg/types"
+)
+
+// listIndexesHandler lists the declared JSON path indexes
+func (s *Server) listIndexesHandler(w http.ResponseWriter, r *http.Request) {
+	indexes, err := s.store.ListIndexes()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, indexes)
+}
+
+// putIndexHandler declares an index on the JSON path given by ?path= for a schemaKey
+func (s *Server) putIndexHandler(w http.ResponseWriter, r *http.Request) {
+	index, err := s.store.CreateIndex(r.PathValue("schemaKey"), r.URL.Query().Get("path"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, index)
+}
+
+// deleteIndexHandler removes the index on the JSON path given by ?path= for a schemaKey
+func (s *Server) deleteIndexHandler(w http.ResponseWriter, r *http.Request) {
+	schemaKey, path := r.PathValue("schemaKey"), r.URL.Query().Get("path")
+	if !s.store.DropIndex(schemaKey, path) {
+		writeError(w, http.StatusNotFound, "index not found: "+schemaKey+" "+path)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// lookupHandler finds the revisions of a schemaKey by an indexed JSON path.
+// The values of ?eq=, ?from= and ?to= are JSON scalars, anything that is not
+// valid JSON is taken as a string, so ?eq=Ada and ?eq="Ada" are the same.
+func (s *Server) lookupHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	lookup := types.IndexLookup{
+		SchemaKey: r.PathValue("schemaKey"),
+		Path:      query.Get("path"),
+		Equal:     jsonParam(query.Get("eq")),
+		From:      jsonParam(query.Get("from")),
+		To:        jsonParam(query.Get("to")),
+	}
+	if latest := query.Get("latest"); latest != "" {
+		var err error
+		if lookup.Latest, err = strconv.ParseBool(latest); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid latest: "+err.Error())
+			return
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		var err error
+		if lookup.Limit, err = strconv.Atoi(limit); err != nil || lookup.Limit < 0 {
+			writeError(w, http.StatusBadRequest, "invalid limit: "+limit)
+			return
+		}
+	}
+
+	revisions, err := s.store.Lookup(lookup)
+	if errors.Is(err, store.ErrNotIndexed) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, revisions)
+}
+
+// jsonParam decodes a query parameter holding a JSON scalar, an empty parameter is nil
+func jsonParam(value string) any {
+	if value == "" {
+		return nil
+	}
+	var decoded any
+	if err := json.Unmarshal([]byte(value), &decoded); err != nil {
+		return value
+	}
+	switch decoded.(type) {
+	case map[string]any, []any:
+		return value
+	}
+	return decoded
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/cfjello/go-store/pkg/types"
+)
+
+func TestIndexes(t *testing.T) {
+	s, server := newTestServer(t)
+	for i, name := range []string{"Ada", "Grace", "Edsger"} {
+		s.store.Set(types.SetArgs{Key: name, SchemaKey: "person", Object: map[string]interface{}{"name": name, "age": 36 + i}})
+	}
+
+	indexURL := server.URL + "/v1/indexes/person?path=" + url.QueryEscape("$.name")
+	if resp := sendJSON(t, http.MethodPut, indexURL, ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK; got %v", resp.Status)
+	}
+	if resp := sendJSON(t, http.MethodPut, server.URL+"/v1/indexes/person?path=name", ""); resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for an invalid path; got %v", resp.Status)
+	}
+	var indexes []types.Index
+	if getJSON(t, server.URL+"/v1/indexes", &indexes); len(indexes) != 1 || indexes[0].SchemaKey != "person" {
+		t.Errorf("unexpected indexes: %+v", indexes)
+	}
+
+	var revisions []types.Revision
+	lookupURL := server.URL + "/v1/indexes/person/lookup?path=" + url.QueryEscape("$.name")
+	if resp := getJSON(t, lookupURL+"&eq=Grace", &revisions); resp.StatusCode != http.StatusOK || len(revisions) != 1 || revisions[0].Key != "Grace" {
+		t.Errorf("expected Grace; got %v %+v", resp.Status, revisions)
+	}
+	revisions = nil
+	if getJSON(t, lookupURL+"&from=%22B%22&limit=1", &revisions); len(revisions) != 1 || revisions[0].Key != "Edsger" {
+		t.Errorf("expected Edsger; got %+v", revisions)
+	}
+	if resp := getJSON(t, server.URL+"/v1/indexes/person/lookup?path=$.age&eq=36", nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for a path without an index; got %v", resp.Status)
+	}
+
+	if resp := sendJSON(t, http.MethodDelete, indexURL, ""); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("expected 204 No Content; got %v", resp.Status)
+	}
+	if resp := sendJSON(t, http.MethodDelete, indexURL, ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 Not Found; got %v", resp.Status)
+	}
+}
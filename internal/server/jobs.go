@@ -0,0 +1,167 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cfjello/go-store/pkg/jobPool"
+	"github.com/cfjello/go-store/pkg/store"
+	"github.com/cfjello/go-store/pkg/types"
+	"github.com/cfjello/go-store/pkg/util"
+)
+
+// jobStatusRequest is the body of a job status update
+type jobStatusRequest struct {
+	Status string            `json:"status"`
+	Info   map[string]string `json:"info,omitempty"`
+}
+
+// batchRequest is the body of a batch, the objects to store in the background as one job
+type batchRequest struct {
+	Sets []batchSet `json:"sets"`
+}
+
+type batchSet struct {
+	Key       string                 `json:"key"`
+	SchemaKey string                 `json:"schemaKey,omitempty"`
+	Object    map[string]interface{} `json:"object"`
+}
+
+// listJobsHandler lists the jobs started between ?from= and ?to=, both optional
+// and given as RFC 3339 times or milliseconds since the Unix epoch
+func (s *Server) listJobsHandler(w http.ResponseWriter, r *http.Request) {
+	var from, to time.Time
+	var err error
+	if param := r.URL.Query().Get("from"); param != "" {
+		if from, err = parseTime(param); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid from: "+err.Error())
+			return
+		}
+	}
+	if param := r.URL.Query().Get("to"); param != "" {
+		if to, err = parseTime(param); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid to: "+err.Error())
+			return
+		}
+	}
+	jobs, err := s.store.ListJobs(from, to)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, jobs)
+}
+
+// startJobHandler starts a job with a new jobID
+func (s *Server) startJobHandler(w http.ResponseWriter, r *http.Request) {
+	job, err := s.store.StartJob("")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Location", "/v1/jobs/"+job.JobID)
+	writeJSON(w, http.StatusCreated, job)
+}
+
+// getJobHandler returns the recorded status of a job
+func (s *Server) getJobHandler(w http.ResponseWriter, r *http.Request) {
+	job, err := s.store.GetJob(r.PathValue("jobId"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// setJobStatusHandler records the status of a job from a {"status", "info"} body
+func (s *Server) setJobStatusHandler(w http.ResponseWriter, r *http.Request) {
+	var req jobStatusRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid job status: "+err.Error())
+		return
+	}
+	if req.Status == "" {
+		writeError(w, http.StatusBadRequest, "a job status must be given")
+		return
+	}
+	job, err := s.store.SetJobStatus(r.PathValue("jobId"), req.Status, req.Info)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// getJobStoreIDsHandler lists the storeIDs written under a jobID
+func (s *Server) getJobStoreIDsHandler(w http.ResponseWriter, r *http.Request) {
+	storeIDs, err := s.store.GetStoreIDs(r.PathValue("jobId"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, storeIDs)
+}
+
+// getJobObjectsHandler returns every revision written under a jobID
+func (s *Server) getJobObjectsHandler(w http.ResponseWriter, r *http.Request) {
+	revisions, err := s.store.GetJobObjects(r.PathValue("jobId"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, revisions)
+}
+
+// batchHandler queues the objects of a batch on the job pool and answers 202 Accepted
+// with the queued job. The objects are stored in order under the jobID of the job,
+// the first one that fails fails the job and leaves the rest unstored.
+func (s *Server) batchHandler(w http.ResponseWriter, r *http.Request) {
+	var req batchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid batch: "+err.Error())
+		return
+	}
+	if len(req.Sets) == 0 {
+		writeError(w, http.StatusBadRequest, "a batch needs at least one object to set")
+		return
+	}
+	for i, set := range req.Sets {
+		if set.Key == "" || set.Object == nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("set %d of the batch needs a key and an object", i))
+			return
+		}
+	}
+	if s.pool == nil {
+		writeError(w, http.StatusServiceUnavailable, "no job pool to run the batch")
+		return
+	}
+
+	job, err := s.store.SetJobStatus(util.Ulid(), store.JobQueued, map[string]string{"sets": strconv.Itoa(len(req.Sets))})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	err = s.pool.Submit(r.Context(), jobPool.Task{JobID: job.JobID, Run: func(ctx context.Context) error {
+		for _, set := range req.Sets {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			args := types.SetArgs{Key: set.Key, SchemaKey: set.SchemaKey, Object: set.Object, JobID: job.JobID}
+			if _, err := s.store.Set(args); err != nil {
+				return fmt.Errorf("failed to set %s: %w", set.Key, err)
+			}
+		}
+		return nil
+	}})
+	if err != nil {
+		s.recordJobStatus(job.JobID, store.JobFailed, err)
+		writeError(w, http.StatusServiceUnavailable, "failed to queue the batch: "+err.Error())
+		return
+	}
+	w.Header().Set("Location", "/v1/jobs/"+job.JobID)
+	writeJSON(w, http.StatusAccepted, job)
+}
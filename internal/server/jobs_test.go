@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cfjello/go-store/pkg/config"
+	"github.com/cfjello/go-store/pkg/jobPool"
+	"github.com/cfjello/go-store/pkg/types"
+)
+
+func TestJobEndpoints(t *testing.T) {
+	_, server := newTestServer(t)
+
+	resp := sendJSON(t, http.MethodPost, server.URL+"/v1/jobs", "")
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status Created; got %v", resp.Status)
+	}
+	var job types.Job
+	if err := json.NewDecoder(resp.Body).Decode(&job); err != nil || job.JobID == "" {
+		t.Fatalf("expected the new job, got %+v (%v)", job, err)
+	}
+	jobURL := server.URL + "/v1/jobs/" + job.JobID
+
+	sendJSON(t, http.MethodPut, server.URL+"/v1/keys/person?jobId="+job.JobID, `{"name": "Ada"}`)
+	sendJSON(t, http.MethodPut, server.URL+"/v1/keys/city?jobId="+job.JobID, `{"name": "Paris"}`)
+
+	var storeIDs []string
+	if resp := getJSON(t, jobURL+"/storeIds", &storeIDs); resp.StatusCode != http.StatusOK || len(storeIDs) != 2 {
+		t.Errorf("expected 2 storeIDs; got %v %v", resp.Status, storeIDs)
+	}
+	var objects []types.Revision
+	if resp := getJSON(t, jobURL+"/objects", &objects); resp.StatusCode != http.StatusOK || len(objects) != 2 || objects[0].Key != "person" {
+		t.Errorf("expected the job objects; got %v %+v", resp.Status, objects)
+	}
+
+	resp = sendJSON(t, http.MethodPut, jobURL, `{"status": "done", "info": {"note": "ok"}}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK; got %v", resp.Status)
+	}
+	if resp := getJSON(t, jobURL, &job); resp.StatusCode != http.StatusOK || job.Status != "done" || job.Finished == nil {
+		t.Errorf("expected the finished job; got %v %+v", resp.Status, job)
+	}
+	if resp := sendJSON(t, http.MethodPut, jobURL, `{"info": {}}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status Bad Request without a status; got %v", resp.Status)
+	}
+
+	var jobs []types.Job
+	if resp := getJSON(t, server.URL+"/v1/jobs?from=0", &jobs); resp.StatusCode != http.StatusOK || len(jobs) != 1 {
+		t.Errorf("expected one job; got %v %+v", resp.Status, jobs)
+	}
+	if resp := getJSON(t, server.URL+"/v1/jobs?to=soon", nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status Bad Request for an invalid time; got %v", resp.Status)
+	}
+	if resp := getJSON(t, server.URL+"/v1/jobs/unknown", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected status Not Found for an unknown job; got %v", resp.Status)
+	}
+}
+
+func TestRecordJobStatus(t *testing.T) {
+	s, _ := newTestServer(t)
+	pool, err := jobPool.New(config.NodeConfig{Name: "test", Minimum: 1, Maximum: 2}, 10)
+	if err != nil {
+		t.Fatalf("jobPool.New() failed: %v", err)
+	}
+	pool.OnStatus = s.recordJobStatus
+	pool.Start(context.Background())
+
+	pool.Submit(context.Background(), jobPool.Task{JobID: "ok", Run: func(ctx context.Context) error { return nil }})
+	pool.Submit(context.Background(), jobPool.Task{JobID: "bad", Run: func(ctx context.Context) error { return errors.New("boom") }})
+	pool.Stop()
+
+	if job, err := s.store.GetJob("ok"); err != nil || job.Status != "done" {
+		t.Errorf("expected job ok to be done, got %+v (%v)", job, err)
+	}
+	if job, err := s.store.GetJob("bad"); err != nil || job.Status != "failed" || job.Info["error"] != "boom" {
+		t.Errorf("expected job bad to have failed, got %+v (%v)", job, err)
+	}
+}
+
+// waitForJob polls a job until it is finished
+func waitForJob(t *testing.T, url string) types.Job {
+	t.Helper()
+	var job types.Job
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		job = types.Job{}
+		getJSON(t, url, &job)
+		if job.Finished != nil {
+			return job
+		}
+	}
+	t.Fatalf("job did not finish: %+v", job)
+	return job
+}
+
+func TestBatch(t *testing.T) {
+	s, server := newTestServer(t)
+	if resp := sendJSON(t, http.MethodPost, server.URL+"/v1/batch", `{"sets": [{"key": "a", "object": {"n": 1}}]}`); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected status Service Unavailable without a job pool; got %v", resp.Status)
+	}
+	if err := s.startPool(config.NodeConfig{Name: "test", Minimum: 1, Maximum: 2}); err != nil {
+		t.Fatalf("startPool() failed: %v", err)
+	}
+	t.Cleanup(func() { s.stopPool(context.Background()) })
+
+	resp := sendJSON(t, http.MethodPost, server.URL+"/v1/batch",
+		`{"sets": [{"key": "person/ada", "object": {"name": "Ada"}}, {"key": "person/grace", "schemaKey": "person", "object": {"name": "Grace"}}]}`)
+	var queued types.Job
+	if err := json.NewDecoder(resp.Body).Decode(&queued); err != nil || resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected status Accepted; got %v (%v)", resp.Status, err)
+	}
+	if queued.Status != "queued" || resp.Header.Get("Location") != "/v1/jobs/"+queued.JobID {
+		t.Errorf("unexpected queued job: %+v %v", queued, resp.Header)
+	}
+	if job := waitForJob(t, server.URL+"/v1/jobs/"+queued.JobID); job.Status != "done" {
+		t.Errorf("expected the batch to be done, got %+v", job)
+	}
+	var storeIDs []string
+	if getJSON(t, server.URL+"/v1/jobs/"+queued.JobID+"/storeIds", &storeIDs); len(storeIDs) != 2 {
+		t.Errorf("expected the objects to belong to the job, got %v", storeIDs)
+	}
+
+	// An object refused by the store fails the job
+	if _, err := s.store.Register(types.RegisterArgs{Key: "city", Schema: map[string]interface{}{"name": "Paris"}, Check: true}); err != nil {
+		t.Fatalf("Register() failed: %v", err)
+	}
+	resp = sendJSON(t, http.MethodPost, server.URL+"/v1/batch", `{"sets": [{"key": "city", "object": {"name": 1}}]}`)
+	queued = types.Job{}
+	json.NewDecoder(resp.Body).Decode(&queued)
+	if job := waitForJob(t, server.URL+"/v1/jobs/"+queued.JobID); job.Status != "failed" || !strings.Contains(job.Info["error"], "city") {
+		t.Errorf("expected the batch to fail, got %+v", job)
+	}
+
+	for _, body := range []string{`{"sets": []}`, `{"sets": [{"key": "a"}]}`, `{"sets": [{"object": {}}]}`, `nope`} {
+		if resp := sendJSON(t, http.MethodPost, server.URL+"/v1/batch", body); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected status Bad Request for %s; got %v", body, resp.Status)
+		}
+	}
+}
+
+func TestStopPool(t *testing.T) {
+	s, _ := newTestServer(t)
+	if err := s.stopPool(context.Background()); err != nil {
+		t.Errorf("expected a server without a pool to stop, got %v", err)
+	}
+
+	pool, err := jobPool.New(config.NodeConfig{Name: "test", Minimum: 1, Maximum: 1}, 10)
+	if err != nil {
+		t.Fatalf("jobPool.New() failed: %v", err)
+	}
+	pool.Start(context.Background())
+	s.pool = pool
+	release := make(chan struct{})
+	defer close(release)
+	pool.Submit(context.Background(), jobPool.Task{JobID: "slow", Run: func(ctx context.Context) error {
+		<-release
+		return nil
+	}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := s.stopPool(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected stopping the pool to give up at the deadline, got %v", err)
+	}
+}
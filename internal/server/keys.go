@@ -0,0 +1,247 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cfjello/go-store/pkg/store"
+	"github.com/cfjello/go-store/pkg/types"
+)
+
+// maxBodyBytes limits the size of an object sent to the store
+const maxBodyBytes = 10 << 20
+
+// getKeyHandler returns the latest object stored under a key.
+// An older revision can be selected with ?storeId= or ?asOf=, where asOf is
+// an RFC 3339 time or milliseconds since the Unix epoch.
+func (s *Server) getKeyHandler(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	query := r.URL.Query()
+
+	if !s.store.IsRegistered(key) {
+		writeError(w, http.StatusNotFound, "key not found: "+key)
+		return
+	}
+
+	if asOfParam := query.Get("asOf"); asOfParam != "" {
+		asOf, err := parseTime(asOfParam)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid asOf: "+err.Error())
+			return
+		}
+		rev, err := s.store.GetAsOf(key, asOf)
+		if err != nil {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeRevision(w, r, rev.StoreID, rev.Object)
+		return
+	}
+
+	storeID := query.Get("storeId")
+	if storeID == "" {
+		rev, err := s.store.GetVersion(key, 0)
+		if err != nil {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeRevision(w, r, rev.StoreID, rev.Object)
+		return
+	}
+	obj, err := s.store.Get(storeID, key)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeRevision(w, r, storeID, obj)
+}
+
+// writeRevision writes an object with its storeID as the ETag,
+// or answers 304 Not Modified if the client already holds that revision
+func writeRevision(w http.ResponseWriter, r *http.Request, storeID string, obj interface{}) {
+	w.Header().Set("ETag", etag(storeID))
+	w.Header().Set("X-Store-Id", storeID)
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" && matchETag(ifNoneMatch, storeID) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeJSON(w, http.StatusOK, obj)
+}
+
+// setKeyHandler stores the JSON object in the request body as a new revision of a key.
+// The optional query parameters jobId, schemaKey and check are passed on to Store.Set.
+// With an If-Match or If-None-Match header, whose entity tags are storeIDs, the object
+// is only stored if the latest revision matches, otherwise the answer is 412.
+func (s *Server) setKeyHandler(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	query := r.URL.Query()
+
+	check := false
+	if checkParam := query.Get("check"); checkParam != "" {
+		var err error
+		if check, err = strconv.ParseBool(checkParam); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid check: "+err.Error())
+			return
+		}
+	}
+
+	obj, status, err := readObject(w, r)
+	if err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+
+	args := types.SetArgs{
+		Key:       key,
+		Object:    obj,
+		JobID:     query.Get("jobId"),
+		SchemaKey: query.Get("schemaKey"),
+		Check:     check,
+	}
+	var meta types.MetaData
+	ifMatch, ifNoneMatch := r.Header.Get("If-Match"), r.Header.Get("If-None-Match")
+	if ifMatch == "" && ifNoneMatch == "" {
+		meta, err = s.store.Set(args)
+	} else {
+		current := s.currentStoreID(key)
+		if ifMatch != "" && (current == "" || !matchETag(ifMatch, current)) ||
+			ifNoneMatch != "" && current != "" && matchETag(ifNoneMatch, current) {
+			writeError(w, http.StatusPreconditionFailed, "latest revision of "+key+" does not match the precondition")
+			return
+		}
+		// The store checks again, in case another writer got in between
+		meta, err = s.store.SetArgsIf(args, current)
+	}
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	w.Header().Set("ETag", etag(meta.StoreID))
+	w.Header().Set("X-Store-Id", meta.StoreID)
+	w.Header().Set("Location", "/v1/keys/"+url.PathEscape(key)+"?storeId="+url.QueryEscape(meta.StoreID))
+	writeJSON(w, http.StatusCreated, meta)
+}
+
+// deleteKeyHandler soft deletes a key, its revisions stay in the store
+func (s *Server) deleteKeyHandler(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	if !s.store.IsRegistered(key) {
+		writeError(w, http.StatusNotFound, "key not found: "+key)
+		return
+	}
+	if !s.store.UnRegister(key) {
+		writeError(w, http.StatusInternalServerError, "failed to delete "+key)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getMetaHandler returns the metadata of a key
+func (s *Server) getMetaHandler(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	meta, err := s.store.GetMetaData(key, key)
+	if err != nil || store.IsDeleted(meta) {
+		writeError(w, http.StatusNotFound, "key not found: "+key)
+		return
+	}
+	writeJSON(w, http.StatusOK, meta)
+}
+
+// currentStoreID returns the storeID of the latest revision of a live key, or an empty string
+func (s *Server) currentStoreID(key string) string {
+	if !s.store.IsRegistered(key) {
+		return ""
+	}
+	storeID, err := s.store.GetStoreID(key)
+	if err != nil {
+		return ""
+	}
+	return storeID
+}
+
+// etag quotes a storeID as a strong entity tag
+func etag(storeID string) string {
+	return `"` + storeID + `"`
+}
+
+// matchETag reports whether an If-Match or If-None-Match header value,
+// a comma separated list of entity tags or "*", matches a storeID
+func matchETag(header string, storeID string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || strings.Trim(tag, `"`) == storeID {
+			return true
+		}
+	}
+	return false
+}
+
+// readObject decodes a request body that must hold a single JSON object
+func readObject(w http.ResponseWriter, r *http.Request) (map[string]interface{}, int, error) {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	var obj map[string]interface{}
+	if err := decoder.Decode(&obj); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, http.StatusRequestEntityTooLarge, errors.New("request body too large")
+		}
+		return nil, http.StatusBadRequest, errors.New("request body must be a JSON object: " + err.Error())
+	}
+	if obj == nil {
+		return nil, http.StatusBadRequest, errors.New("request body must be a JSON object")
+	}
+	if err := decoder.Decode(&struct{}{}); err != io.EOF {
+		return nil, http.StatusBadRequest, errors.New("request body must hold a single JSON object")
+	}
+	return obj, http.StatusOK, nil
+}
+
+// writeStoreError maps errors from the store to a status code,
+// validation errors are listed by path in the "violations" member
+func writeStoreError(w http.ResponseWriter, err error) {
+	var extErr *types.ExtError
+	if errors.Is(err, store.ErrConflict) {
+		writeError(w, http.StatusPreconditionFailed, err.Error())
+		return
+	}
+	if errors.As(err, &extErr) && extErr.Name == "ValidationError" {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{
+			"error":      extErr.Error(),
+			"violations": extErr.Info,
+		})
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err.Error())
+}
+
+// parseTime accepts an RFC 3339 timestamp or milliseconds since the Unix epoch
+func parseTime(value string) (time.Time, error) {
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	resp, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, "Failed to marshal response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(resp); err != nil {
+		log.Printf("Failed to write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
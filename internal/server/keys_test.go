@@ -0,0 +1,265 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cfjello/go-store/pkg/store"
+	"github.com/cfjello/go-store/pkg/types"
+)
+
+func newTestServer(t *testing.T) (*Server, *httptest.Server) {
+	t.Helper()
+	s := &Server{store: store.New(store.NewMemBackend())}
+	server := httptest.NewServer(s.RegisterRoutes())
+	t.Cleanup(server.Close)
+	return s, server
+}
+
+func getJSON(t *testing.T, url string, v any) *http.Response {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("error making request to server. Err: %v", err)
+	}
+	defer resp.Body.Close()
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("error decoding response body. Err: %v", err)
+		}
+	}
+	return resp
+}
+
+func TestGetKeyAsOf(t *testing.T) {
+	s, server := newTestServer(t)
+	if _, err := s.store.Set(types.SetArgs{Key: "person", Object: map[string]interface{}{"name": "Ada"}}); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	asOf := time.Now()
+	time.Sleep(5 * time.Millisecond)
+	if _, err := s.store.Set(types.SetArgs{Key: "person", Object: map[string]interface{}{"name": "Grace"}}); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+
+	var latest map[string]interface{}
+	resp := getJSON(t, server.URL+"/v1/keys/person", &latest)
+	if resp.StatusCode != http.StatusOK || latest["name"] != "Grace" {
+		t.Errorf("expected the latest revision, got %v %v", resp.Status, latest)
+	}
+
+	var old map[string]interface{}
+	resp = getJSON(t, server.URL+"/v1/keys/person?asOf="+url.QueryEscape(asOf.Format(time.RFC3339Nano)), &old)
+	if resp.StatusCode != http.StatusOK || old["name"] != "Ada" {
+		t.Errorf("expected the revision as of %v, got %v %v", asOf, resp.Status, old)
+	}
+	if resp.Header.Get("X-Store-Id") == "" {
+		t.Error("expected the X-Store-Id header to be set")
+	}
+
+	resp = getJSON(t, server.URL+"/v1/keys/person?asOf=yesterday", nil)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status Bad Request for an invalid asOf; got %v", resp.Status)
+	}
+	resp = getJSON(t, server.URL+"/v1/keys/person?asOf=0", nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected status Not Found before the first revision; got %v", resp.Status)
+	}
+}
+
+func sendJSON(t *testing.T, method string, url string, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("error creating request. Err: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("error making request to server. Err: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestKeyCRUD(t *testing.T) {
+	_, server := newTestServer(t)
+	keyURL := server.URL + "/v1/keys/person"
+
+	resp := getJSON(t, keyURL, nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected status Not Found for an unknown key; got %v", resp.Status)
+	}
+
+	resp = sendJSON(t, http.MethodPut, keyURL+"?jobId=job-1", `{"name": "Ada", "age": 36}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status Created; got %v", resp.Status)
+	}
+	var meta types.MetaData
+	if err := json.NewDecoder(resp.Body).Decode(&meta); err != nil || meta.Key != "person" {
+		t.Errorf("expected the meta data in the response, got %+v (%v)", meta, err)
+	}
+	storeID := resp.Header.Get("X-Store-Id")
+	if storeID == "" || resp.Header.Get("Location") != "/v1/keys/person?storeId="+storeID {
+		t.Errorf("unexpected headers: %v", resp.Header)
+	}
+
+	resp = sendJSON(t, http.MethodPost, keyURL, `{"name": "Grace", "age": 85}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status Created; got %v", resp.Status)
+	}
+
+	var obj map[string]interface{}
+	resp = getJSON(t, keyURL, &obj)
+	if resp.StatusCode != http.StatusOK || obj["name"] != "Grace" {
+		t.Errorf("expected the latest revision, got %v %v", resp.Status, obj)
+	}
+	resp = getJSON(t, keyURL+"?storeId="+storeID, &obj)
+	if resp.StatusCode != http.StatusOK || obj["name"] != "Ada" {
+		t.Errorf("expected the first revision, got %v %v", resp.Status, obj)
+	}
+
+	resp = getJSON(t, keyURL+"/meta", &meta)
+	if resp.StatusCode != http.StatusOK || meta.SchemaKey != "person" || meta.TypeInfo.Kind != "struct" {
+		t.Errorf("unexpected meta data: %v %+v", resp.Status, meta)
+	}
+
+	resp = sendJSON(t, http.MethodDelete, keyURL, "")
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("expected status No Content; got %v", resp.Status)
+	}
+	for _, u := range []string{keyURL, keyURL + "/meta"} {
+		if resp := getJSON(t, u, nil); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected status Not Found for %s after delete; got %v", u, resp.Status)
+		}
+	}
+	resp = sendJSON(t, http.MethodDelete, keyURL, "")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected status Not Found for a second delete; got %v", resp.Status)
+	}
+
+	resp = sendJSON(t, http.MethodPut, keyURL, `{"name": "Barbara", "age": 88}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("expected a deleted key to be set again; got %v", resp.Status)
+	}
+}
+
+func TestGetKeyWithStoreIDOfOtherKey(t *testing.T) {
+	s, server := newTestServer(t)
+	s.store.Set(types.SetArgs{Key: "a", Object: map[string]interface{}{"name": "A"}})
+	metaB, err := s.store.Set(types.SetArgs{Key: "b", Object: map[string]interface{}{"name": "B"}})
+	if err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+
+	resp := getJSON(t, server.URL+"/v1/keys/a?storeId="+metaB.StoreID, nil)
+	if resp.StatusCode != http.StatusNotFound || resp.Header.Get("ETag") != "" {
+		t.Errorf("expected status Not Found for a storeId of another key; got %v %v", resp.Status, resp.Header)
+	}
+}
+
+func TestSetKeyErrors(t *testing.T) {
+	_, server := newTestServer(t)
+	keyURL := server.URL + "/v1/keys/person"
+
+	for _, body := range []string{`{"name": `, `[1, 2]`, `"Ada"`, `null`, `{} {}`} {
+		resp := sendJSON(t, http.MethodPut, keyURL, body)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected status Bad Request for %s; got %v", body, resp.Status)
+		}
+		var errBody map[string]string
+		if err := json.NewDecoder(resp.Body).Decode(&errBody); err != nil || errBody["error"] == "" {
+			t.Errorf("expected a JSON error body for %s, got %v (%v)", body, errBody, err)
+		}
+	}
+	if resp := sendJSON(t, http.MethodPut, keyURL+"?check=maybe", `{}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status Bad Request for an invalid check; got %v", resp.Status)
+	}
+
+	if resp := sendJSON(t, http.MethodPut, keyURL+"?check=true", `{"name": "Ada", "age": 36}`); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status Created; got %v", resp.Status)
+	}
+	resp := sendJSON(t, http.MethodPut, keyURL, `{"name": 1, "age": 36}`)
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status Unprocessable Entity; got %v", resp.Status)
+	}
+	var errBody struct {
+		Error      string            `json:"error"`
+		Violations map[string]string `json:"violations"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&errBody); err != nil || errBody.Violations["$.name"] == "" {
+		t.Errorf("expected the violations in the error body, got %+v (%v)", errBody, err)
+	}
+}
+
+func sendWithHeader(t *testing.T, method string, url string, body string, header string, value string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("error creating request. Err: %v", err)
+	}
+	req.Header.Set(header, value)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("error making request to server. Err: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestConditionalRequests(t *testing.T) {
+	_, server := newTestServer(t)
+	keyURL := server.URL + "/v1/keys/counter"
+
+	resp := sendWithHeader(t, http.MethodPut, keyURL, `{"n": 1}`, "If-Match", "*")
+	if resp.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("expected status Precondition Failed for If-Match on a new key; got %v", resp.Status)
+	}
+	resp = sendWithHeader(t, http.MethodPut, keyURL, `{"n": 1}`, "If-None-Match", "*")
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status Created for If-None-Match on a new key; got %v", resp.Status)
+	}
+	first := resp.Header.Get("ETag")
+	if first == "" || first != `"`+resp.Header.Get("X-Store-Id")+`"` {
+		t.Fatalf("expected the storeID as ETag, got %q", first)
+	}
+	resp = sendWithHeader(t, http.MethodPut, keyURL, `{"n": 2}`, "If-None-Match", "*")
+	if resp.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("expected status Precondition Failed for If-None-Match on an existing key; got %v", resp.Status)
+	}
+
+	resp = sendWithHeader(t, http.MethodGet, keyURL, "", "If-None-Match", first)
+	if resp.StatusCode != http.StatusNotModified || resp.Header.Get("ETag") != first {
+		t.Errorf("expected status Not Modified for the current ETag; got %v %q", resp.Status, resp.Header.Get("ETag"))
+	}
+
+	resp = sendWithHeader(t, http.MethodPut, keyURL, `{"n": 2}`, "If-Match", first)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status Created for a matching If-Match; got %v", resp.Status)
+	}
+	second := resp.Header.Get("ETag")
+
+	resp = sendWithHeader(t, http.MethodPut, keyURL, `{"n": 3}`, "If-Match", first)
+	if resp.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("expected status Precondition Failed for a stale If-Match; got %v", resp.Status)
+	}
+	var errBody map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&errBody); err != nil || errBody["error"] == "" {
+		t.Errorf("expected a JSON error body, got %v (%v)", errBody, err)
+	}
+
+	resp = sendWithHeader(t, http.MethodGet, keyURL, "", "If-None-Match", first)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") != second {
+		t.Errorf("expected the latest revision for a stale ETag; got %v %q", resp.Status, resp.Header.Get("ETag"))
+	}
+	resp = sendWithHeader(t, http.MethodPut, keyURL, `{"n": 3}`, "If-Match", first+", "+second)
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("expected status Created when any ETag matches; got %v", resp.Status)
+	}
+}
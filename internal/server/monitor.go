@@ -0,0 +1,87 @@
+package server
+
+import (
+	_ "embed"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/cfjello/go-store/pkg/config"
+	"github.com/cfjello/go-store/pkg/jobPool"
+	"github.com/cfjello/go-store/pkg/types"
+)
+
+//go:embed monitor.html
+var monitorPage []byte
+
+// monitorStats is the document served by the monitor at /stats
+type monitorStats struct {
+	Name   string            `json:"name"`
+	Uptime string            `json:"uptime"`
+	Store  types.StoreStats  `json:"store"`
+	Pool   *jobPool.Stats    `json:"pool,omitempty"`
+	DB     map[string]string `json:"db,omitempty"`
+	Error  string            `json:"error,omitempty"`
+}
+
+// NewMonitor creates the monitoring listener on addr, separate from the API so it can stay
+// internal. It serves a small HTML page at / and the statistics it shows at /stats.
+func (s *Server) NewMonitor(name string, addr string) *http.Server {
+	return &http.Server{
+		Addr:         addr,
+		Handler:      s.RegisterMonitorRoutes(name),
+		IdleTimeout:  time.Minute,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+}
+
+// monitorAddr is the address of the monitor: the configured host, the loopback interface
+// by default, and port. MONITOR_PORT overrides the port and MONITOR_ADDR the whole address,
+// e.g. ":9999" to listen on every interface.
+func monitorAddr(cfg config.MonitorDefaults) string {
+	if addr := os.Getenv("MONITOR_ADDR"); addr != "" {
+		return addr
+	}
+	port := cfg.Port
+	if envPort, err := strconv.Atoi(os.Getenv("MONITOR_PORT")); err == nil {
+		port = envPort
+	}
+	return net.JoinHostPort(cfg.Host, strconv.Itoa(port))
+}
+
+// RegisterMonitorRoutes returns the handler of the monitoring listener
+func (s *Server) RegisterMonitorRoutes(name string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if _, err := w.Write(monitorPage); err != nil {
+			log.Printf("Failed to write response: %v", err)
+		}
+	})
+	mux.HandleFunc("GET /stats", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.monitorStats(name))
+	})
+	return mux
+}
+
+// monitorStats gathers the store, job pool and database statistics
+func (s *Server) monitorStats(name string) monitorStats {
+	stats := monitorStats{Name: name, Uptime: time.Since(s.started).Round(time.Second).String()}
+	storeStats, err := s.store.Stats()
+	if err != nil {
+		stats.Error = "store statistics unavailable: " + err.Error()
+	}
+	stats.Store = storeStats
+	if s.pool != nil {
+		poolStats := s.pool.Stats()
+		stats.Pool = &poolStats
+	}
+	if s.db != nil {
+		stats.DB = s.db.Health()
+	}
+	return stats
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cfjello/go-store/pkg/config"
+	"github.com/cfjello/go-store/pkg/types"
+)
+
+func TestMonitor(t *testing.T) {
+	s, api := newTestServer(t)
+	s.started = time.Now()
+	if err := s.startPool(config.NodeConfig{Name: "test", Minimum: 1, Maximum: 2}); err != nil {
+		t.Fatalf("startPool() failed: %v", err)
+	}
+	t.Cleanup(func() { s.stopPool(context.Background()) })
+	monitor := httptest.NewServer(s.RegisterMonitorRoutes("MonitorDefaults"))
+	t.Cleanup(monitor.Close)
+
+	// A batch runs on the job pool the monitor reports
+	resp := sendJSON(t, http.MethodPost, api.URL+"/v1/batch", `{"sets": [{"key": "batch", "object": {"n": 1}}]}`)
+	var job types.Job
+	json.NewDecoder(resp.Body).Decode(&job)
+	waitForJob(t, api.URL+"/v1/jobs/"+job.JobID)
+
+	s.store.Set(types.SetArgs{Key: "person", Object: map[string]interface{}{"name": "Ada"}})
+	s.store.Set(types.SetArgs{Key: "person", Object: map[string]interface{}{"name": "Grace"}})
+	s.store.Set(types.SetArgs{Key: "city", Object: map[string]interface{}{"name": "Paris"}})
+	s.store.UnRegister("city")
+
+	var stats monitorStats
+	resp = getJSON(t, monitor.URL+"/stats", &stats)
+	if resp.StatusCode != http.StatusOK || stats.Name != "MonitorDefaults" {
+		t.Fatalf("unexpected response: %v %+v", resp.Status, stats)
+	}
+	if stats.Store.Keys != 2 || stats.Store.DeletedKeys != 1 || stats.Store.Revisions != 4 || stats.Store.Writes != 4 {
+		t.Errorf("unexpected store statistics: %+v", stats.Store)
+	}
+	if stats.Store.Bytes == 0 || stats.Store.WritesPerSec <= 0 {
+		t.Errorf("expected bytes and a write rate, got %+v", stats.Store)
+	}
+	if stats.Pool == nil || stats.Pool.Name != "test" || stats.Pool.Workers != 1 || stats.Pool.Completed != 1 || stats.DB != nil {
+		t.Errorf("unexpected pool and database statistics: %+v %v", stats.Pool, stats.DB)
+	}
+
+	resp, err := http.Get(monitor.URL)
+	if err != nil {
+		t.Fatalf("error making request to server. Err: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") || !strings.Contains(string(body), "go-store monitor") {
+		t.Errorf("expected the monitor page, got %q", resp.Header.Get("Content-Type"))
+	}
+	if resp := getJSON(t, monitor.URL+"/v1/keys/person", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected the monitor to serve no API routes; got %v", resp.Status)
+	}
+}
+
+func TestMonitorAddr(t *testing.T) {
+	cfg := config.DefaultConfig().MonitorDefaults
+	t.Setenv("MONITOR_ADDR", "")
+	t.Setenv("MONITOR_PORT", "")
+	if addr := monitorAddr(cfg); addr != "127.0.0.1:9999" {
+		t.Errorf("expected the monitor on the loopback interface by default, got %s", addr)
+	}
+	t.Setenv("MONITOR_PORT", "9100")
+	if addr := monitorAddr(cfg); addr != "127.0.0.1:9100" {
+		t.Errorf("expected MONITOR_PORT to set the port, got %s", addr)
+	}
+	t.Setenv("MONITOR_ADDR", ":9200")
+	if addr := monitorAddr(cfg); addr != ":9200" {
+		t.Errorf("expected MONITOR_ADDR to set the address, got %s", addr)
+	}
+}
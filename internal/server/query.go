@@ -0,0 +1,24 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/cfjello/go-store/pkg/types"
+)
+
+// queryHandler runs the query in the request body and answers one page of results,
+// the nextCursor of the page goes into the cursor of the query for the next page
+func (s *Server) queryHandler(w http.ResponseWriter, r *http.Request) {
+	var query types.Query
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(&query); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid query: "+err.Error())
+		return
+	}
+	page, err := s.store.Query(query)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/cfjello/go-store/pkg/types"
+)
+
+func TestQuery(t *testing.T) {
+	s, server := newTestServer(t)
+	for i, name := range []string{"Ada", "Grace", "Edsger"} {
+		s.store.Set(types.SetArgs{Key: name, SchemaKey: "person", Object: map[string]interface{}{"name": name, "age": 36 + i}})
+	}
+
+	resp := sendJSON(t, http.MethodPost, server.URL+"/v1/query",
+		`{"schemaKey": "person", "where": [{"path": "$.age", "op": ">", "value": 36}], "sort": [{"path": "$.age", "desc": true}], "limit": 1}`)
+	var page types.QueryPage
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil || resp.StatusCode != http.StatusOK ||
+		len(page.Results) != 1 || page.Results[0].Key != "Edsger" || page.NextCursor == "" {
+		t.Fatalf("expected Edsger and a cursor; got %v %+v (%v)", resp.Status, page, err)
+	}
+
+	resp = sendJSON(t, http.MethodPost, server.URL+"/v1/query",
+		`{"schemaKey": "person", "where": [{"path": "$.age", "op": ">", "value": 36}], "sort": [{"path": "$.age", "desc": true}], "limit": 1, "cursor": "`+page.NextCursor+`"}`)
+	page = types.QueryPage{}
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil || len(page.Results) != 1 || page.Results[0].Key != "Grace" || page.NextCursor != "" {
+		t.Errorf("expected Grace on the last page; got %+v (%v)", page, err)
+	}
+
+	if resp := sendJSON(t, http.MethodPost, server.URL+"/v1/query", `{"where": [{"path": "$.age", "op": "~"}]}`); resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for an unknown operator; got %v", resp.Status)
+	}
+	if resp := sendJSON(t, http.MethodPost, server.URL+"/v1/query", `{"where": `); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid body; got %v", resp.Status)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+
+	"github.com/cfjello/go-store/pkg/metrics"
 )
 
 func (s *Server) RegisterRoutes() http.Handler {
@@ -13,9 +15,51 @@ func (s *Server) RegisterRoutes() http.Handler {
 	mux.HandleFunc("/", s.HelloWorldHandler)
 
 	mux.HandleFunc("/health", s.healthHandler)
+	mux.HandleFunc("GET /health/live", s.liveHandler)
+	mux.HandleFunc("GET /health/ready", s.readyHandler)
+	mux.Handle("GET /metrics", metrics.Handler())
+
+	// Store resources
+	mux.HandleFunc("GET /v1/keys/{key}", s.getKeyHandler)
+	mux.HandleFunc("PUT /v1/keys/{key}", s.setKeyHandler)
+	mux.HandleFunc("POST /v1/keys/{key}", s.setKeyHandler)
+	mux.HandleFunc("DELETE /v1/keys/{key}", s.deleteKeyHandler)
+	mux.HandleFunc("GET /v1/keys/{key}/meta", s.getMetaHandler)
+	mux.HandleFunc("GET /v1/watch", s.watchHandler)
+
+	// Jobs
+	mux.HandleFunc("GET /v1/jobs", s.listJobsHandler)
+	mux.HandleFunc("POST /v1/jobs", s.startJobHandler)
+	mux.HandleFunc("GET /v1/jobs/{jobId}", s.getJobHandler)
+	mux.HandleFunc("PUT /v1/jobs/{jobId}", s.setJobStatusHandler)
+	mux.HandleFunc("GET /v1/jobs/{jobId}/storeIds", s.getJobStoreIDsHandler)
+	mux.HandleFunc("GET /v1/jobs/{jobId}/objects", s.getJobObjectsHandler)
+	mux.HandleFunc("POST /v1/batch", s.batchHandler)
+
+	// Job graphs
+	mux.HandleFunc("GET /v1/graphs", s.listGraphsHandler)
+	mux.HandleFunc("PUT /v1/graphs/{graphId}", s.putGraphHandler)
+	mux.HandleFunc("GET /v1/graphs/{graphId}", s.getGraphHandler)
+	mux.HandleFunc("DELETE /v1/graphs/{graphId}", s.deleteGraphHandler)
+	mux.HandleFunc("GET /v1/graphs/{graphId}/affected", s.affectedNodesHandler)
 
-	// Wrap the mux with CORS middleware
-	return s.corsMiddleware(mux)
+	// JSON path indexes
+	mux.HandleFunc("GET /v1/indexes", s.listIndexesHandler)
+	mux.HandleFunc("PUT /v1/indexes/{schemaKey}", s.putIndexHandler)
+	mux.HandleFunc("DELETE /v1/indexes/{schemaKey}", s.deleteIndexHandler)
+	mux.HandleFunc("GET /v1/indexes/{schemaKey}/lookup", s.lookupHandler)
+
+	// Queries over the latest revisions
+	mux.HandleFunc("POST /v1/query", s.queryHandler)
+
+	// Full-text search
+	mux.HandleFunc("GET /v1/search", s.searchHandler)
+	mux.HandleFunc("GET /v1/search/indexes", s.listSearchIndexesHandler)
+	mux.HandleFunc("PUT /v1/search/indexes/{schemaKey}", s.putSearchIndexHandler)
+	mux.HandleFunc("DELETE /v1/search/indexes/{schemaKey}", s.deleteSearchIndexHandler)
+
+	// Wrap the mux with CORS middleware and record the request metrics
+	return metrics.Middleware(mux, s.corsMiddleware(mux))
 }
 
 func (s *Server) corsMiddleware(next http.Handler) http.Handler {
@@ -23,7 +67,8 @@ func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 		// Set CORS headers
 		w.Header().Set("Access-Control-Allow-Origin", "*") // Replace "*" with specific origins if needed
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS, PATCH")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept, Authorization, Content-Type, X-CSRF-Token")
+		w.Header().Set("Access-Control-Allow-Headers", "Accept, Authorization, Content-Type, X-CSRF-Token, If-Match, If-None-Match, Last-Event-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag, Location, X-Store-Id")
 		w.Header().Set("Access-Control-Allow-Credentials", "false") // Set to "true" if credentials are required
 
 		// Handle preflight OPTIONS requests
@@ -51,12 +96,16 @@ func (s *Server) HelloWorldHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
-	resp, err := json.Marshal(s.db.Health())
+	health := s.db.Health()
+	resp, err := json.Marshal(health)
 	if err != nil {
 		http.Error(w, "Failed to marshal health check response", http.StatusInternalServerError)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
+	if health["status"] != "up" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
 	if _, err := w.Write(resp); err != nil {
 		log.Printf("Failed to write response: %v", err)
 	}
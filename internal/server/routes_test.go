@@ -4,7 +4,10 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/cfjello/go-store/pkg/types"
 )
 
 func TestHandler(t *testing.T) {
@@ -29,3 +32,31 @@ func TestHandler(t *testing.T) {
 		t.Errorf("expected response body to be %v; got %v", expected, string(body))
 	}
 }
+
+func TestMetrics(t *testing.T) {
+	s, server := newTestServer(t)
+	s.store.Set(types.SetArgs{Key: "person", Object: map[string]interface{}{"name": "Ada"}})
+	getJSON(t, server.URL+"/v1/keys/person", nil)
+	getJSON(t, server.URL+"/v1/keys/nobody", nil)
+
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("error making request to server. Err: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK; got %v", resp.Status)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	for _, want := range []string{
+		`gostore_store_operations_total{operation="Set",result="ok"}`,
+		`gostore_store_operation_duration_seconds_count{operation="Get"}`,
+		`gostore_http_requests_total{code="200",method="GET",route="GET /v1/keys/{key}"}`,
+		`gostore_http_requests_total{code="404",method="GET",route="GET /v1/keys/{key}"}`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("expected %s in the metrics", want)
+		}
+	}
+}
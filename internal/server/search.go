@@ -0,0 +1,76 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/cfjello/go-store/pkg/store"
+	"github.com/cfjello/go-store/pkg/types"
+)
+
+// listSearchIndexesHandler lists the full-text search indexes
+func (s *Server) listSearchIndexesHandler(w http.ResponseWriter, r *http.Request) {
+	indexes, err := s.store.ListSearchIndexes()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, indexes)
+}
+
+// putSearchIndexHandler declares the full-text index of a schemaKey on the
+// JSON paths in the body, {"paths": ["$.name", "$.description"]}
+func (s *Server) putSearchIndexHandler(w http.ResponseWriter, r *http.Request) {
+	var req types.SearchIndex
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid search index: "+err.Error())
+		return
+	}
+	index, err := s.store.CreateSearchIndex(r.PathValue("schemaKey"), req.Paths...)
+	if errors.Is(err, store.ErrSearchUnavailable) {
+		writeError(w, http.StatusNotImplemented, err.Error())
+		return
+	}
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, index)
+}
+
+// deleteSearchIndexHandler removes the full-text index of a schemaKey
+func (s *Server) deleteSearchIndexHandler(w http.ResponseWriter, r *http.Request) {
+	schemaKey := r.PathValue("schemaKey")
+	if !s.store.DropSearchIndex(schemaKey) {
+		writeError(w, http.StatusNotFound, "search index not found: "+schemaKey)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// searchHandler searches the keys of ?schemaKey= for the words of ?q=
+func (s *Server) searchHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	search := types.SearchQuery{SchemaKey: query.Get("schemaKey"), Text: query.Get("q")}
+	if limit := query.Get("limit"); limit != "" {
+		var err error
+		if search.Limit, err = strconv.Atoi(limit); err != nil || search.Limit < 0 {
+			writeError(w, http.StatusBadRequest, "invalid limit: "+limit)
+			return
+		}
+	}
+
+	hits, err := s.store.Search(search)
+	switch {
+	case errors.Is(err, store.ErrSearchUnavailable):
+		writeError(w, http.StatusNotImplemented, err.Error())
+	case errors.Is(err, store.ErrNotIndexed):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case err != nil:
+		writeStoreError(w, err)
+	default:
+		writeJSON(w, http.StatusOK, hits)
+	}
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/cfjello/go-store/pkg/types"
+)
+
+func TestSearch(t *testing.T) {
+	s, server := newTestServer(t)
+	for _, name := range []string{"Restaurant", "Bakery"} {
+		obj := map[string]interface{}{"rdfs:label": name, "rdfs:comment": "A " + name + " serving food."}
+		s.store.Set(types.SetArgs{Key: "schema:" + name, SchemaKey: "schema.org", Object: obj})
+	}
+	searchURL := server.URL + "/v1/search?schemaKey=schema.org&q="
+
+	if resp := getJSON(t, searchURL+"food", nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 without a search index; got %v", resp.Status)
+	}
+	indexURL := server.URL + "/v1/search/indexes/schema.org"
+	if resp := sendJSON(t, http.MethodPut, indexURL, `{"paths": ["$.\"rdfs:label\"", "$.\"rdfs:comment\""]}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK; got %v", resp.Status)
+	}
+	if resp := sendJSON(t, http.MethodPut, indexURL, `{"paths": ["rdfs:label"]}`); resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for an invalid path; got %v", resp.Status)
+	}
+	var indexes []types.SearchIndex
+	if getJSON(t, server.URL+"/v1/search/indexes", &indexes); len(indexes) != 1 || indexes[0].SchemaKey != "schema.org" {
+		t.Errorf("unexpected search indexes: %+v", indexes)
+	}
+
+	var hits []types.SearchHit
+	if resp := getJSON(t, searchURL+url.QueryEscape("bak*"), &hits); resp.StatusCode != http.StatusOK || len(hits) != 1 || hits[0].Key != "schema:Bakery" {
+		t.Errorf("expected the bakery; got %v %+v", resp.Status, hits)
+	}
+	hits = nil
+	if getJSON(t, searchURL+"food&limit=1", &hits); len(hits) != 1 {
+		t.Errorf("expected the limit to apply; got %+v", hits)
+	}
+	if resp := getJSON(t, searchURL, nil); resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for a search without words; got %v", resp.Status)
+	}
+
+	if resp := sendJSON(t, http.MethodDelete, indexURL, ""); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("expected status No Content; got %v", resp.Status)
+	}
+	if resp := sendJSON(t, http.MethodDelete, indexURL, ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected status Not Found; got %v", resp.Status)
+	}
+}
@@ -3,6 +3,7 @@ package server
 import (
 	"context"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"os/signal"
@@ -11,12 +12,21 @@ import (
 	"time"
 
 	"github.com/cfjello/go-store/internal/database"
+	"github.com/cfjello/go-store/pkg/config"
+	"github.com/cfjello/go-store/pkg/jobPool"
+	"github.com/cfjello/go-store/pkg/store"
 	"github.com/cfjello/go-store/pkg/util"
 )
 
+// jobQueueSize bounds the number of jobs waiting for a worker
+const jobQueueSize = 1024
+
 type Server struct {
-	port int
-	db   *database.DBService
+	port    int
+	db      *database.DBService
+	store   *store.Store
+	pool    *jobPool.Pool
+	started time.Time
 }
 
 func NewServer() *http.Server {
@@ -25,9 +35,21 @@ func NewServer() *http.Server {
 	port, _ := strconv.Atoi(os.Getenv("PORT"))
 
 	// Initialize the database service
+	db := database.New()
 	NewServer := &Server{
-		port: port,
-		db:   database.New(),
+		port:    port,
+		db:      db,
+		store:   store.New(db),
+		started: time.Now(),
+	}
+	if hours, err := strconv.Atoi(os.Getenv("EVENT_RETENTION_HOURS")); err == nil {
+		NewServer.store.EventRetention = time.Duration(hours) * time.Hour
+	}
+	cfg := config.DefaultConfig()
+
+	// Start the job pool that runs the batches
+	if err := NewServer.startPool(cfg.Nodes); err != nil {
+		log.Fatalf("failed to create the job pool: %v", err)
 	}
 
 	// Declare Server config
@@ -39,6 +61,19 @@ func NewServer() *http.Server {
 		WriteTimeout: 30 * time.Second,
 	}
 
+	// Start the monitor on its own address
+	var monitor *http.Server
+	if cfg.MonitorDefaults.RunServer {
+		addr := monitorAddr(cfg.MonitorDefaults)
+		monitor = NewServer.NewMonitor(cfg.MonitorDefaults.Name, addr)
+		go func() {
+			if err := monitor.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("Monitor server error: %v", err)
+			}
+		}()
+		fmt.Printf("Monitor is running on %s\n", addr)
+	}
+
 	// Graceful shutdown handler
 	go func() {
 		// Wait for interrupt signal to gracefully shutdown the server
@@ -50,14 +85,25 @@ func NewServer() *http.Server {
 		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 		defer cancel()
 
-		// Attempt to gracefully shutdown the database
-		if err := NewServer.db.Close(); err != nil {
-			fmt.Printf("Database forced shutdown: %v\n", err)
+		if monitor != nil {
+			if err := monitor.Shutdown(ctx); err != nil {
+				fmt.Printf("Monitor forced shutdown: %v\n", err)
+			}
 		}
-		// Attempt to gracefully shutdown
+		// End the watch streams, they would keep the server from shutting down
+		NewServer.store.CloseWatches()
+		// Attempt to gracefully shutdown, the open requests still use the database
 		if err := server.Shutdown(ctx); err != nil {
 			fmt.Printf("Server forced shutdown: %v\n", err)
 		}
+		// Let the queued jobs finish
+		if err := NewServer.stopPool(ctx); err != nil {
+			fmt.Printf("Job pool forced shutdown: %v\n", err)
+		}
+		// Close the database last, it writes the final snapshot
+		if err := NewServer.store.Close(); err != nil {
+			fmt.Printf("Database forced shutdown: %v\n", err)
+		}
 
 		fmt.Println("Server gracefully shutdown")
 		os.Exit(0)
@@ -67,3 +113,45 @@ func NewServer() *http.Server {
 
 	return server
 }
+
+// startPool starts the job pool of the server from a node configuration,
+// the status of its jobs is recorded in the store
+func (s *Server) startPool(cfg config.NodeConfig) error {
+	pool, err := jobPool.New(cfg, jobQueueSize)
+	if err != nil {
+		return err
+	}
+	pool.OnStatus = s.recordJobStatus
+	pool.Start(context.Background())
+	s.pool = pool
+	return nil
+}
+
+// stopPool stops the job pool and waits for the queued jobs to finish until ctx is done
+func (s *Server) stopPool(ctx context.Context) error {
+	if s.pool == nil {
+		return nil
+	}
+	stopped := make(chan struct{})
+	go func() {
+		s.pool.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// recordJobStatus keeps the store job status in step with the job pool
+func (s *Server) recordJobStatus(jobID string, status string, err error) {
+	var info map[string]string
+	if err != nil {
+		info = map[string]string{"error": err.Error()}
+	}
+	if _, err := s.store.SetJobStatus(jobID, status, info); err != nil {
+		log.Printf("Failed to record status %s of job %s: %v", status, jobID, err)
+	}
+}
@@ -0,0 +1,245 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/cfjello/go-store/pkg/types"
+	"github.com/gorilla/websocket"
+)
+
+// heartbeatInterval keeps idle watch connections open through proxies
+const heartbeatInterval = 15 * time.Second
+
+// pongWait is how long a WebSocket watch waits for the pong to a ping, or any other message,
+// before it gives up on the client
+const pongWait = 2 * heartbeatInterval
+
+// upgrader accepts WebSocket connections from any origin, like the CORS middleware
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// watchMessage is sent by WebSocket clients to change their subscriptions
+type watchMessage struct {
+	Action  string `json:"action"` // "subscribe" or "unsubscribe"
+	Pattern string `json:"pattern"`
+}
+
+// watchReply is sent to WebSocket clients
+type watchReply struct {
+	Type    string       `json:"type"` // "event", "subscribed", "unsubscribed" or "error"
+	Pattern string       `json:"pattern,omitempty"`
+	Event   *types.Event `json:"event,omitempty"`
+	Error   string       `json:"error,omitempty"`
+}
+
+// watchHandler streams store change events for the key patterns given by ?key=.
+// Plain requests get Server-Sent Events, of every key if there are no patterns.
+// WebSocket upgrades get a connection that starts out subscribed to the patterns,
+// to none if there are no patterns, and can subscribe to and unsubscribe from others.
+// Both resume after the storeID in the Last-Event-ID header or ?lastEventId=.
+func (s *Server) watchHandler(w http.ResponseWriter, r *http.Request) {
+	patterns := r.URL.Query()["key"]
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid key pattern: "+pattern)
+			return
+		}
+	}
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("lastEventId")
+	}
+
+	if websocket.IsWebSocketUpgrade(r) {
+		s.watchWebSocket(w, r, patterns, lastEventID)
+		return
+	}
+	if len(patterns) == 0 {
+		patterns = []string{""}
+	}
+	s.watchSSE(w, r, patterns, lastEventID)
+}
+
+// watch starts a watch over every key, the handlers filter by their own patterns
+// so that a single cursor covers all of them
+func (s *Server) watch(ctx context.Context, lastEventID string) (<-chan types.Event, error) {
+	if lastEventID == "" {
+		return s.store.Watch(ctx, "")
+	}
+	return s.store.WatchFrom(ctx, "", lastEventID)
+}
+
+func (s *Server) watchSSE(w http.ResponseWriter, r *http.Request, patterns []string, lastEventID string) {
+	events, err := s.watch(r.Context(), lastEventID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	// The stream outlives the server write timeout
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("Failed to clear the write deadline of a watch: %v", err)
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		log.Printf("Failed to flush a watch: %v", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if !matchAny(patterns, event.Key) {
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Printf("Failed to marshal event %s: %v", event.ID, err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %s\ndata: %s\n\n", event.ID, data); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) watchWebSocket(w http.ResponseWriter, r *http.Request, patterns []string, lastEventID string) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already answered the request
+		log.Printf("Failed to upgrade a watch to WebSocket: %v", err)
+		return
+	}
+	defer conn.Close()
+	// Drop clients that send oversized messages or stop answering the pings below
+	conn.SetReadLimit(maxBodyBytes)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	events, err := s.watch(ctx, lastEventID)
+	if err != nil {
+		conn.WriteJSON(watchReply{Type: "error", Error: err.Error()})
+		return
+	}
+
+	// The reader passes subscription changes to the writer below, the only one writing to conn
+	messages := make(chan watchMessage)
+	go func() {
+		defer cancel()
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.SetReadDeadline(time.Now().Add(pongWait))
+			var msg watchMessage
+			if err := json.Unmarshal(data, &msg); err != nil {
+				msg = watchMessage{}
+			}
+			select {
+			case messages <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	subscribed := make(map[string]bool)
+	for _, pattern := range patterns {
+		subscribed[pattern] = true
+	}
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		var reply watchReply
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "store closed"))
+				return
+			}
+			if !matchAny(mapKeys(subscribed), event.Key) {
+				continue
+			}
+			reply = watchReply{Type: "event", Event: &event}
+		case msg := <-messages:
+			reply = handleWatchMessage(subscribed, msg)
+		case <-heartbeat.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(heartbeatInterval)); err != nil {
+				return
+			}
+			continue
+		}
+		conn.SetWriteDeadline(time.Now().Add(heartbeatInterval))
+		if err := conn.WriteJSON(reply); err != nil {
+			return
+		}
+	}
+}
+
+// handleWatchMessage applies a subscription change and returns the reply to the client
+func handleWatchMessage(subscribed map[string]bool, msg watchMessage) watchReply {
+	if _, err := path.Match(msg.Pattern, ""); err != nil {
+		return watchReply{Type: "error", Pattern: msg.Pattern, Error: "invalid key pattern"}
+	}
+	switch msg.Action {
+	case "subscribe":
+		subscribed[msg.Pattern] = true
+		return watchReply{Type: "subscribed", Pattern: msg.Pattern}
+	case "unsubscribe":
+		delete(subscribed, msg.Pattern)
+		return watchReply{Type: "unsubscribed", Pattern: msg.Pattern}
+	}
+	return watchReply{Type: "error", Error: `expected a JSON message with action "subscribe" or "unsubscribe"`}
+}
+
+// matchAny reports whether a key matches one of the patterns, an empty pattern matches every key
+func matchAny(patterns []string, key string) bool {
+	for _, pattern := range patterns {
+		if pattern == "" {
+			return true
+		}
+		if matched, _ := path.Match(pattern, key); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func mapKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cfjello/go-store/pkg/types"
+	"github.com/gorilla/websocket"
+)
+
+// readSSE reads the next event from a Server-Sent Events stream, skipping comments
+func readSSE(t *testing.T, reader *bufio.Reader) (string, types.Event) {
+	t.Helper()
+	var id string
+	var event types.Event
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("error reading the event stream. Err: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+				t.Fatalf("error decoding event data. Err: %v", err)
+			}
+		case line == "" && id != "":
+			return id, event
+		}
+	}
+}
+
+func openSSE(t *testing.T, url string, lastEventID string) *bufio.Reader {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("error creating request. Err: %v", err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("error making request to server. Err: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected an event stream; got %v %q", resp.Status, resp.Header.Get("Content-Type"))
+	}
+	return bufio.NewReader(resp.Body)
+}
+
+func TestWatchSSE(t *testing.T) {
+	s, server := newTestServer(t)
+	stream := openSSE(t, server.URL+"/v1/watch?key=person/*", "")
+
+	s.store.Set(types.SetArgs{Key: "city/paris", Object: map[string]interface{}{"name": "Paris"}})
+	s.store.Set(types.SetArgs{Key: "person/ada", Object: map[string]interface{}{"name": "Ada"}})
+	s.store.Set(types.SetArgs{Key: "person/grace", Object: map[string]interface{}{"name": "Grace"}})
+
+	firstID, first := readSSE(t, stream)
+	if first.Key != "person/ada" || first.Oper != "set" || firstID != first.StoreID {
+		t.Errorf("unexpected first event %s: %+v", firstID, first)
+	}
+	if _, second := readSSE(t, stream); second.Key != "person/grace" {
+		t.Errorf("unexpected second event: %+v", second)
+	}
+
+	resumed := openSSE(t, server.URL+"/v1/watch?key=person/*", firstID)
+	if _, event := readSSE(t, resumed); event.Key != "person/grace" {
+		t.Errorf("expected the stream to resume after %s, got %+v", firstID, event)
+	}
+
+	resp := getJSON(t, server.URL+"/v1/watch?key=[", nil)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status Bad Request for an invalid pattern; got %v", resp.Status)
+	}
+}
+
+func TestWatchWebSocket(t *testing.T) {
+	s, server := newTestServer(t)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/v1/watch?key=person/*", nil)
+	if err != nil {
+		t.Fatalf("error dialing the watch. Err: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	readReply := func() watchReply {
+		t.Helper()
+		var reply watchReply
+		if err := conn.ReadJSON(&reply); err != nil {
+			t.Fatalf("error reading from the watch. Err: %v", err)
+		}
+		return reply
+	}
+
+	conn.WriteJSON(watchMessage{Action: "subscribe", Pattern: "city/*"})
+	if reply := readReply(); reply.Type != "subscribed" || reply.Pattern != "city/*" {
+		t.Fatalf("unexpected reply: %+v", reply)
+	}
+	s.store.Set(types.SetArgs{Key: "city/paris", Object: map[string]interface{}{"name": "Paris"}})
+	s.store.Set(types.SetArgs{Key: "person/ada", Object: map[string]interface{}{"name": "Ada"}})
+	for _, key := range []string{"city/paris", "person/ada"} {
+		if reply := readReply(); reply.Type != "event" || reply.Event.Key != key {
+			t.Errorf("expected an event for %s, got %+v", key, reply)
+		}
+	}
+
+	conn.WriteJSON(watchMessage{Action: "unsubscribe", Pattern: "person/*"})
+	if reply := readReply(); reply.Type != "unsubscribed" {
+		t.Fatalf("unexpected reply: %+v", reply)
+	}
+	s.store.Set(types.SetArgs{Key: "person/grace", Object: map[string]interface{}{"name": "Grace"}})
+	s.store.Set(types.SetArgs{Key: "city/rome", Object: map[string]interface{}{"name": "Rome"}})
+	if reply := readReply(); reply.Type != "event" || reply.Event.Key != "city/rome" {
+		t.Errorf("expected only the city event after unsubscribing, got %+v", reply)
+	}
+
+	conn.WriteMessage(websocket.TextMessage, []byte("hello"))
+	if reply := readReply(); reply.Type != "error" {
+		t.Errorf("expected an error for an invalid message, got %+v", reply)
+	}
+}
+
+func TestWatchWebSocketLimits(t *testing.T) {
+	_, server := newTestServer(t)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/v1/watch", nil)
+	if err != nil {
+		t.Fatalf("error dialing the watch. Err: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	conn.WriteMessage(websocket.TextMessage, make([]byte, maxBodyBytes+1))
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+		t.Errorf("expected an oversized message to close the watch, got %v", err)
+	}
+}
@@ -24,6 +24,7 @@ type NodeConfig struct {
 // MonitorDefaults represents monitor configuration settings
 type MonitorDefaults struct {
 	Name      string `json:"name"`
+	Host      string `json:"host"` // the monitor has no authentication, keep it on the loopback interface
 	Port      int    `json:"port"`
 	RunServer bool   `json:"runServer"`
 }
@@ -59,20 +60,25 @@ func DefaultConfig() Config {
 		},
 		MonitorDefaults: MonitorDefaults{
 			Name:      "MonitorDefaults",
+			Host:      "127.0.0.1",
 			Port:      9999,
 			RunServer: true,
 		},
 		Sqlite3: Sqlite3{
-			Flags: ";PRAGMA journal_mode=WAL;",
+			Flags: ";PRAGMA journal_mode=WAL;PRAGMA busy_timeout=5000;",
 			File:  "F:/sqlite3/go-store.db",
 		},
 		DefaultEnv: map[string]string{
-			"PORT":            "9090",
-			"APP_ENV":         "local",
-			"SQLITE_DB_URL":   "file:F:/Sqlite3/go-store.db",
-			"SQLITE_DB_FLAGS": ";PRAGMA journal_mode=WAL;",
-			"LOG_FILE_DEST":   "file:F:/Work/go-store/logs/go-store.log",
-			"CGO_ENABLED":     "1",
+			"PORT":                   "9090",
+			"APP_ENV":                "local",
+			"SQLITE_DB_URL":          "file:F:/Sqlite3/go-store.db",
+			"SQLITE_DB_FLAGS":        ";PRAGMA journal_mode=WAL;PRAGMA busy_timeout=5000;",
+			"SQLITE_DB_MODE":         "disk",
+			"SQLITE_SNAPSHOT_MS":     "60000",
+			"SQLITE_MIGRATE_DRY_RUN": "false",
+			"EVENT_RETENTION_HOURS":  "168",
+			"LOG_FILE_DEST":          "file:F:/Work/go-store/logs/go-store.log",
+			"CGO_ENABLED":            "1",
 		},
 	}
 }
@@ -3,6 +3,7 @@ package dynReflect
 import (
 	"encoding/json"
 	"reflect"
+	"strings"
 )
 
 // TypeInfo represents type information of a value
@@ -13,6 +14,7 @@ type TypeInfo struct {
 	Elem      *TypeInfo           `json:"elem,omitempty"`      // For pointer, slice, array, chan
 	KeyType   *TypeInfo           `json:"keyType,omitempty"`   // For maps
 	ValueType *TypeInfo           `json:"valueType,omitempty"` // For maps
+	Optional  bool                `json:"optional,omitempty"`  // For struct fields that may be left out
 }
 
 // ObjectToTypeJSON converts an object to a JSON representation of its type structure
@@ -25,7 +27,8 @@ func ObjectToTypeJSON(obj interface{}) (string, error) {
 	return string(jsonData), nil
 }
 
-// BuildTypeInfo recursively builds type information for a value
+// BuildTypeInfo recursively builds type information for a value.
+// Struct fields are named by their JSON member names, see jsonFields.
 func BuildTypeInfo(v reflect.Value) TypeInfo {
 	t := v.Type()
 	info := TypeInfo{
@@ -36,14 +39,15 @@ func BuildTypeInfo(v reflect.Value) TypeInfo {
 	switch t.Kind() {
 	case reflect.Struct:
 		info.Fields = make(map[string]TypeInfo)
-		for i := 0; i < t.NumField(); i++ {
-			field := t.Field(i)
-			// Skip unexported fields
-			if field.PkgPath != "" {
-				continue
+		for _, field := range jsonFields(t) {
+			fieldValue, err := v.FieldByIndexErr(field.Index)
+			if err != nil {
+				// The field is promoted through a nil embedded pointer
+				fieldValue = reflect.Zero(t.FieldByIndex(field.Index).Type)
 			}
-			fieldValue := v.Field(i)
-			info.Fields[field.Name] = BuildTypeInfo(fieldValue)
+			fieldInfo := BuildTypeInfo(fieldValue)
+			fieldInfo.Optional = field.OmitEmpty
+			info.Fields[field.Name] = fieldInfo
 		}
 
 	case reflect.Map:
@@ -123,6 +127,92 @@ func BuildTypeInfo(v reflect.Value) TypeInfo {
 	return info
 }
 
+// jsonField is a struct field as encoding/json sees it
+type jsonField struct {
+	Name      string
+	Index     []int
+	OmitEmpty bool
+}
+
+// jsonFields lists the fields of a struct type under their JSON member names, following
+// the rules of encoding/json: the json tag renames a field, "-" leaves it out, "omitempty"
+// lets it be left out, and the fields of an embedded struct without a tag name are promoted.
+// A promoted field is hidden by a field of the same name closer to the top, and of several
+// at the same depth the one with a tag name wins, if there is no single one they all drop out.
+func jsonFields(t reflect.Type) []jsonField {
+	type embedded struct {
+		t     reflect.Type
+		index []int
+	}
+	var fields []jsonField
+	taken := make(map[string]bool)
+	visited := make(map[reflect.Type]bool)
+	for level := []embedded{{t: t}}; len(level) > 0; {
+		var next []embedded
+		var names []string
+		candidates := make(map[string][]jsonField)
+		tagged := make(map[string][]jsonField)
+		for _, e := range level {
+			if visited[e.t] {
+				continue
+			}
+			visited[e.t] = true
+			for i := 0; i < e.t.NumField(); i++ {
+				sf := e.t.Field(i)
+				ft := sf.Type
+				if ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				if sf.Anonymous {
+					if !sf.IsExported() && ft.Kind() != reflect.Struct {
+						continue
+					}
+				} else if !sf.IsExported() {
+					continue
+				}
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name, opts, _ := strings.Cut(tag, ",")
+				index := append(e.index[:len(e.index):len(e.index)], i)
+				if name == "" && sf.Anonymous && ft.Kind() == reflect.Struct {
+					next = append(next, embedded{t: ft, index: index})
+					continue
+				}
+				if !sf.IsExported() {
+					continue
+				}
+				field := jsonField{Name: name, Index: index, OmitEmpty: strings.Contains(","+opts+",", ",omitempty,")}
+				if name == "" {
+					field.Name = sf.Name
+				}
+				if taken[field.Name] {
+					continue
+				}
+				if len(candidates[field.Name]) == 0 {
+					names = append(names, field.Name)
+				}
+				candidates[field.Name] = append(candidates[field.Name], field)
+				if name != "" {
+					tagged[field.Name] = append(tagged[field.Name], field)
+				}
+			}
+		}
+		for _, name := range names {
+			taken[name] = true
+			switch {
+			case len(candidates[name]) == 1:
+				fields = append(fields, candidates[name][0])
+			case len(tagged[name]) == 1:
+				fields = append(fields, tagged[name][0])
+			}
+		}
+		level = next
+	}
+	return fields
+}
+
 // isComplexType returns true if the kind represents a complex type
 // that might need further inspection
 func isComplexType(kind reflect.Kind) bool {
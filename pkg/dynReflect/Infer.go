@@ -0,0 +1,205 @@
+package dynReflect
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// InferTypeInfo builds type information from a JSON value, as decoded by encoding/json
+// into map[string]interface{}, []interface{}, string, float64, bool and nil.
+// Other values are converted to JSON first.
+//
+// Unlike BuildTypeInfo it looks at every element: JSON objects become structs of type
+// "object" with one field per member, numbers become float64, as JSON does not tell
+// 10 from 10.0, and the elements of an array are unified with Unify, so members missing
+// from some elements are marked optional and nulls make the element type a pointer.
+func InferTypeInfo(value interface{}) TypeInfo {
+	switch value.(type) {
+	case nil, bool, string, float64, json.Number, map[string]interface{}, []interface{}:
+		return inferJSON(value)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return TypeInfo{Type: "interface {}", Kind: "interface"}
+	}
+	var decoded interface{}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		return TypeInfo{Type: "interface {}", Kind: "interface"}
+	}
+	return inferJSON(decoded)
+}
+
+// MergeTypeInfo unifies type information taken from several samples of the same data,
+// for instance the revisions of a key.
+func MergeTypeInfo(infos ...TypeInfo) TypeInfo {
+	var merged TypeInfo
+	for _, info := range infos {
+		merged = Unify(merged, info)
+	}
+	return merged
+}
+
+func inferJSON(value interface{}) TypeInfo {
+	switch v := value.(type) {
+	case nil:
+		return nullInfo()
+	case bool:
+		return TypeInfo{Type: "bool", Kind: "bool"}
+	case string:
+		return TypeInfo{Type: "string", Kind: "string"}
+	case float64, json.Number:
+		return TypeInfo{Type: "float64", Kind: "float64"}
+	case map[string]interface{}:
+		info := TypeInfo{Type: "object", Kind: "struct", Fields: make(map[string]TypeInfo, len(v))}
+		for name, member := range v {
+			info.Fields[name] = inferJSON(member)
+		}
+		return info
+	case []interface{}:
+		var elem TypeInfo
+		for _, item := range v {
+			elem = Unify(elem, inferJSON(item))
+		}
+		if elem.Kind == "" {
+			elem = TypeInfo{Type: "interface {}", Kind: "interface"}
+		}
+		return TypeInfo{Type: "[]" + elem.Type, Kind: "slice", Elem: &elem}
+	}
+	return TypeInfo{Type: "interface {}", Kind: "interface"}
+}
+
+// Unify returns type information that describes values of both a and b.
+// The zero TypeInfo is the unknown type and unifies to the other side.
+//   - integers and floats unify to float64 (a JSON number)
+//   - a pointer, or a null, and a type unify to a pointer to that type
+//   - structs unify field by field, fields missing from either side become optional
+//   - structs and maps unify to a map of the unified member types
+//   - slices and arrays unify their elements
+//   - any other mix unifies to interface {}
+func Unify(a TypeInfo, b TypeInfo) TypeInfo {
+	optional := a.Optional || b.Optional
+	info := unify(a, b)
+	info.Optional = optional
+	return info
+}
+
+func unify(a TypeInfo, b TypeInfo) TypeInfo {
+	a.Optional, b.Optional = false, false
+	switch {
+	case a.Kind == "":
+		return b
+	case b.Kind == "":
+		return a
+	case a.Kind == "interface" || b.Kind == "interface":
+		return TypeInfo{Type: "interface {}", Kind: "interface"}
+	case a.Kind == "ptr" || b.Kind == "ptr":
+		return pointerTo(Unify(pointee(a), pointee(b)))
+	case IsNumberKind(a.Kind) && IsNumberKind(b.Kind):
+		if a.Kind == b.Kind {
+			return a
+		}
+		if strings.HasPrefix(a.Kind, "float") || strings.HasPrefix(b.Kind, "float") {
+			return TypeInfo{Type: "float64", Kind: "float64"}
+		}
+		return TypeInfo{Type: "int64", Kind: "int64"}
+	case a.Kind == "struct" && b.Kind == "struct":
+		return unifyStructs(a, b)
+	case (a.Kind == "struct" || a.Kind == "map") && (b.Kind == "struct" || b.Kind == "map"):
+		value := Unify(memberType(a), memberType(b))
+		value.Optional = false
+		if value.Kind == "" {
+			value = TypeInfo{Type: "interface {}", Kind: "interface"}
+		}
+		return TypeInfo{
+			Type:      "map[string]" + value.Type,
+			Kind:      "map",
+			KeyType:   &TypeInfo{Type: "string", Kind: "string"},
+			ValueType: &value,
+		}
+	case (a.Kind == "slice" || a.Kind == "array") && (b.Kind == "slice" || b.Kind == "array"):
+		var elemA, elemB TypeInfo
+		if a.Elem != nil {
+			elemA = *a.Elem
+		}
+		if b.Elem != nil {
+			elemB = *b.Elem
+		}
+		elem := Unify(elemA, elemB)
+		if a.Type == b.Type {
+			return TypeInfo{Type: a.Type, Kind: a.Kind, Elem: &elem}
+		}
+		return TypeInfo{Type: "[]" + elem.Type, Kind: "slice", Elem: &elem}
+	case a.Kind == b.Kind:
+		return a
+	}
+	return TypeInfo{Type: "interface {}", Kind: "interface"}
+}
+
+func unifyStructs(a TypeInfo, b TypeInfo) TypeInfo {
+	if a.Fields == nil {
+		return b
+	}
+	if b.Fields == nil {
+		return a
+	}
+	typeName := a.Type
+	if a.Type != b.Type {
+		typeName = "object"
+	}
+	info := TypeInfo{Type: typeName, Kind: "struct", Fields: make(map[string]TypeInfo)}
+	for name, field := range a.Fields {
+		other, ok := b.Fields[name]
+		if !ok {
+			field.Optional = true
+			info.Fields[name] = field
+			continue
+		}
+		info.Fields[name] = Unify(field, other)
+	}
+	for name, field := range b.Fields {
+		if _, ok := a.Fields[name]; !ok {
+			field.Optional = true
+			info.Fields[name] = field
+		}
+	}
+	return info
+}
+
+// memberType unifies the field types of a struct or returns the value type of a map
+func memberType(info TypeInfo) TypeInfo {
+	if info.Kind == "map" {
+		if info.ValueType == nil {
+			return TypeInfo{}
+		}
+		return *info.ValueType
+	}
+	names := make([]string, 0, len(info.Fields))
+	for name := range info.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var member TypeInfo
+	for _, name := range names {
+		member = Unify(member, info.Fields[name])
+	}
+	return member
+}
+
+// nullInfo describes a JSON null, a pointer to a type that is not known yet
+func nullInfo() TypeInfo {
+	return TypeInfo{Type: "*", Kind: "ptr"}
+}
+
+// pointee returns the type a pointer points to, or the type itself
+func pointee(info TypeInfo) TypeInfo {
+	if info.Kind != "ptr" {
+		return info
+	}
+	if info.Elem == nil {
+		return TypeInfo{}
+	}
+	return *info.Elem
+}
@@ -0,0 +1,105 @@
+package dynReflect
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func decode(t *testing.T, doc string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(doc), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestInferTypeInfo(t *testing.T) {
+	info := InferTypeInfo(decode(t, `{
+		"name": "Ada",
+		"age": 36,
+		"height": 1.65,
+		"active": true,
+		"tags": ["math", "code"],
+		"scores": [1, 2.5],
+		"children": [
+			{"name": "Byron", "age": 3},
+			{"name": "Anne", "nick": null}
+		],
+		"empty": []
+	}`))
+
+	if info.Kind != "struct" || info.Type != "object" || len(info.Fields) != 8 {
+		t.Fatalf("unexpected type info: %+v", info)
+	}
+	for name, kind := range map[string]string{"name": "string", "age": "float64", "height": "float64", "active": "bool"} {
+		if info.Fields[name].Kind != kind {
+			t.Errorf("expected %s to be %s, got %+v", name, kind, info.Fields[name])
+		}
+	}
+	if tags := info.Fields["tags"]; tags.Kind != "slice" || tags.Elem.Kind != "string" {
+		t.Errorf("unexpected tags: %+v", tags)
+	}
+	if scores := info.Fields["scores"]; scores.Elem.Kind != "float64" {
+		t.Errorf("expected int and float to unify to float64, got %+v", scores.Elem)
+	}
+	if empty := info.Fields["empty"]; empty.Elem.Kind != "interface" {
+		t.Errorf("expected an empty array of interface {}, got %+v", empty.Elem)
+	}
+
+	child := info.Fields["children"].Elem
+	if child.Kind != "struct" || child.Fields["name"].Optional {
+		t.Fatalf("unexpected children: %+v", child)
+	}
+	if age := child.Fields["age"]; !age.Optional || age.Kind != "float64" {
+		t.Errorf("expected age to be an optional float64, got %+v", age)
+	}
+	if nick := child.Fields["nick"]; !nick.Optional || nick.Kind != "ptr" {
+		t.Errorf("expected nick to be an optional null, got %+v", nick)
+	}
+}
+
+func TestInferTypeInfoFromStruct(t *testing.T) {
+	type person struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+	info := InferTypeInfo(person{Name: "Ada", Age: 36})
+	if info.Fields["name"].Kind != "string" || info.Fields["age"].Kind != "float64" {
+		t.Errorf("expected the JSON shape of the struct, got %+v", info)
+	}
+}
+
+func TestMergeTypeInfo(t *testing.T) {
+	merged := MergeTypeInfo(
+		InferTypeInfo(decode(t, `{"id": 1, "name": "Ada", "email": null}`)),
+		InferTypeInfo(decode(t, `{"id": 2.5, "name": "Grace", "email": "grace@example.com"}`)),
+		InferTypeInfo(decode(t, `{"id": 3, "name": 42, "phone": "555"}`)),
+	)
+
+	if id := merged.Fields["id"]; id.Kind != "float64" || id.Optional {
+		t.Errorf("expected id to be a required number, got %+v", id)
+	}
+	if name := merged.Fields["name"]; name.Kind != "interface" {
+		t.Errorf("expected a string and a number to unify to interface {}, got %+v", name)
+	}
+	if email := merged.Fields["email"]; email.Kind != "ptr" || email.Elem.Kind != "string" || !email.Optional {
+		t.Errorf("expected email to be an optional nullable string, got %+v", email)
+	}
+	if phone := merged.Fields["phone"]; !phone.Optional {
+		t.Errorf("expected phone to be optional, got %+v", phone)
+	}
+
+	valid := decode(t, `{"id": 4, "name": "Barbara", "email": null}`)
+	if violations := merged.Validate(valid); len(violations) != 0 {
+		t.Errorf("expected the merged schema to accept %v, got %v", valid, violations)
+	}
+
+	mixed := Unify(InferTypeInfo(decode(t, `{"a": 1}`)), InferTypeInfo(decode(t, `{"b": "x"}`)))
+	if mixed.Kind != "struct" || !mixed.Fields["a"].Optional || !mixed.Fields["b"].Optional {
+		t.Errorf("unexpected unification of objects: %+v", mixed)
+	}
+	if m := Unify(mixed, TypeInfo{Type: "map[string]int64", Kind: "map", ValueType: &TypeInfo{Type: "int64", Kind: "int64"}}); m.Kind != "map" || m.ValueType.Kind != "interface" {
+		t.Errorf("expected a struct and a map to unify to a map, got %+v", m)
+	}
+}
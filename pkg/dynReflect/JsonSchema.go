@@ -0,0 +1,343 @@
+package dynReflect
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// JSONSchemaDraft is the JSON Schema dialect written by ToJSONSchema
+const JSONSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// ToJSONSchema converts type information to a JSON Schema draft 2020-12 document.
+// Structs become closed objects whose properties carry the JSON names of the fields, as
+// recorded by BuildTypeInfo, and whose non-optional fields are required, maps become
+// objects described by additionalProperties, slices and arrays become arrays and
+// pointers add "null" to the allowed types. Channels and functions, which cannot be
+// stored as JSON, are described by the empty schema.
+func ToJSONSchema(info TypeInfo) map[string]interface{} {
+	schema := toJSONSchema(info)
+	schema["$schema"] = JSONSchemaDraft
+	return schema
+}
+
+func toJSONSchema(info TypeInfo) map[string]interface{} {
+	switch info.Kind {
+	case "ptr":
+		if info.Elem == nil {
+			return map[string]interface{}{}
+		}
+		return nullable(toJSONSchema(*info.Elem))
+
+	case "struct":
+		if info.Type == "time.Time" {
+			return map[string]interface{}{"type": "string", "format": "date-time"}
+		}
+		schema := map[string]interface{}{"type": "object"}
+		if info.Type != "" && info.Type != "object" {
+			schema["title"] = info.Type
+		}
+		if info.Fields == nil {
+			return schema
+		}
+		names := make([]string, 0, len(info.Fields))
+		for name := range info.Fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		properties := make(map[string]interface{}, len(names))
+		required := []interface{}{}
+		for _, name := range names {
+			field := info.Fields[name]
+			properties[name] = toJSONSchema(field)
+			if !field.Optional {
+				required = append(required, name)
+			}
+		}
+		schema["properties"] = properties
+		schema["additionalProperties"] = false
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+
+	case "map":
+		schema := map[string]interface{}{"type": "object"}
+		if info.ValueType != nil {
+			schema["additionalProperties"] = toJSONSchema(*info.ValueType)
+		}
+		return schema
+
+	case "slice", "array":
+		if info.Type == "[]uint8" || info.Type == "[]byte" {
+			// encoding/json writes byte slices as base64 strings
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+		schema := map[string]interface{}{"type": "array"}
+		if info.Elem != nil {
+			schema["items"] = toJSONSchema(*info.Elem)
+		}
+		return schema
+
+	case "string":
+		return map[string]interface{}{"type": "string"}
+	case "bool":
+		return map[string]interface{}{"type": "boolean"}
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64":
+		return map[string]interface{}{"type": "integer"}
+	case "float32", "float64":
+		return map[string]interface{}{"type": "number"}
+	}
+	return map[string]interface{}{}
+}
+
+// nullable adds "null" to the types allowed by a schema
+func nullable(schema map[string]interface{}) map[string]interface{} {
+	switch t := schema["type"].(type) {
+	case string:
+		schema["type"] = []interface{}{t, "null"}
+	case []interface{}:
+		schema["type"] = append(t, "null")
+	}
+	return schema
+}
+
+// FromJSONSchema builds type information from a decoded JSON Schema document.
+// Objects with properties become structs, where properties that are not required
+// are marked optional, objects with only additionalProperties become maps,
+// and a "null" alternative, either in the type or in anyOf/oneOf, makes the value
+// a pointer. Local references into $defs or definitions are resolved.
+func FromJSONSchema(schema map[string]interface{}) (TypeInfo, error) {
+	reader := &schemaReader{root: schema, resolving: make(map[string]bool)}
+	return reader.read(schema, "$")
+}
+
+// IsJSONSchema reports whether a decoded JSON object looks like a JSON Schema document
+// rather than a sample object.
+func IsJSONSchema(obj map[string]interface{}) bool {
+	if _, ok := obj["$schema"]; ok {
+		return true
+	}
+	types, err := schemaTypes(obj["type"])
+	if err != nil || len(types) == 0 {
+		return false
+	}
+	for _, t := range types {
+		switch t {
+		case "object", "array", "string", "integer", "number", "boolean", "null":
+		default:
+			return false
+		}
+	}
+	for key := range obj {
+		switch key {
+		case "type", "properties", "required", "items", "additionalProperties", "title", "description", "$id", "$defs", "$ref", "enum", "format":
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// schemaReader converts a schema document, resolving references against its root
+type schemaReader struct {
+	root      map[string]interface{}
+	resolving map[string]bool
+}
+
+func (r *schemaReader) read(schema map[string]interface{}, path string) (TypeInfo, error) {
+	if ref, ok := schema["$ref"].(string); ok {
+		return r.readRef(ref, path)
+	}
+	for _, keyword := range []string{"anyOf", "oneOf"} {
+		if alternatives, ok := schema[keyword].([]interface{}); ok {
+			return r.readAlternatives(alternatives, path+"."+keyword)
+		}
+	}
+
+	types, err := schemaTypes(schema["type"])
+	if err != nil {
+		return TypeInfo{}, fmt.Errorf("%s: %w", path, err)
+	}
+	isNullable := false
+	var kinds []string
+	for _, t := range types {
+		if t == "null" {
+			isNullable = true
+		} else {
+			kinds = append(kinds, t)
+		}
+	}
+
+	var info TypeInfo
+	switch {
+	case len(kinds) == 0 || len(kinds) > 1:
+		info = TypeInfo{Type: "interface {}", Kind: "interface"}
+	case kinds[0] == "object":
+		if info, err = r.readObject(schema, path); err != nil {
+			return TypeInfo{}, err
+		}
+	case kinds[0] == "array":
+		elem := TypeInfo{Type: "interface {}", Kind: "interface"}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			if elem, err = r.read(items, path+"[]"); err != nil {
+				return TypeInfo{}, err
+			}
+		}
+		info = TypeInfo{Type: "[]" + elem.Type, Kind: "slice", Elem: &elem}
+	case kinds[0] == "string":
+		info = TypeInfo{Type: "string", Kind: "string"}
+	case kinds[0] == "integer":
+		info = TypeInfo{Type: "int64", Kind: "int64"}
+	case kinds[0] == "number":
+		info = TypeInfo{Type: "float64", Kind: "float64"}
+	case kinds[0] == "boolean":
+		info = TypeInfo{Type: "bool", Kind: "bool"}
+	default:
+		return TypeInfo{}, fmt.Errorf("%s: unsupported type %q", path, kinds[0])
+	}
+
+	if isNullable {
+		info = pointerTo(info)
+	}
+	return info, nil
+}
+
+func (r *schemaReader) readObject(schema map[string]interface{}, path string) (TypeInfo, error) {
+	properties, hasProperties := schema["properties"].(map[string]interface{})
+	if !hasProperties {
+		value := TypeInfo{Type: "interface {}", Kind: "interface"}
+		if additional, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+			var err error
+			if value, err = r.read(additional, path+".*"); err != nil {
+				return TypeInfo{}, err
+			}
+		}
+		return TypeInfo{
+			Type:      "map[string]" + value.Type,
+			Kind:      "map",
+			KeyType:   &TypeInfo{Type: "string", Kind: "string"},
+			ValueType: &value,
+		}, nil
+	}
+
+	required := make(map[string]bool)
+	if list, ok := schema["required"].([]interface{}); ok {
+		for _, name := range list {
+			if s, ok := name.(string); ok {
+				required[s] = true
+			}
+		}
+	}
+
+	typeName := "object"
+	if title, ok := schema["title"].(string); ok && title != "" {
+		typeName = title
+	}
+	info := TypeInfo{Type: typeName, Kind: "struct", Fields: make(map[string]TypeInfo)}
+	for name, property := range properties {
+		propSchema, ok := property.(map[string]interface{})
+		if !ok {
+			return TypeInfo{}, fmt.Errorf("%s.%s: property schema must be an object", path, name)
+		}
+		field, err := r.read(propSchema, path+"."+name)
+		if err != nil {
+			return TypeInfo{}, err
+		}
+		field.Optional = !required[name]
+		info.Fields[name] = field
+	}
+	return info, nil
+}
+
+// readRef resolves a local reference such as "#/$defs/Address"
+func (r *schemaReader) readRef(ref string, path string) (TypeInfo, error) {
+	if !strings.HasPrefix(ref, "#/") {
+		return TypeInfo{}, fmt.Errorf("%s: only local references are supported, got %q", path, ref)
+	}
+	if r.resolving[ref] {
+		return TypeInfo{}, fmt.Errorf("%s: recursive reference %q is not supported", path, ref)
+	}
+
+	var target interface{} = r.root
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		obj, ok := target.(map[string]interface{})
+		if !ok {
+			target = nil
+			break
+		}
+		target = obj[part]
+	}
+	def, ok := target.(map[string]interface{})
+	if !ok {
+		return TypeInfo{}, fmt.Errorf("%s: unresolved reference %q", path, ref)
+	}
+
+	r.resolving[ref] = true
+	defer delete(r.resolving, ref)
+	return r.read(def, path)
+}
+
+// readAlternatives supports the common anyOf/oneOf pattern of a schema or null
+func (r *schemaReader) readAlternatives(alternatives []interface{}, path string) (TypeInfo, error) {
+	isNullable := false
+	var schemas []map[string]interface{}
+	for _, alternative := range alternatives {
+		schema, ok := alternative.(map[string]interface{})
+		if !ok {
+			return TypeInfo{}, fmt.Errorf("%s: alternative must be an object", path)
+		}
+		if t, _ := schema["type"].(string); t == "null" && len(schema) == 1 {
+			isNullable = true
+			continue
+		}
+		schemas = append(schemas, schema)
+	}
+	if len(schemas) != 1 {
+		return TypeInfo{Type: "interface {}", Kind: "interface"}, nil
+	}
+	info, err := r.read(schemas[0], path)
+	if err != nil {
+		return TypeInfo{}, err
+	}
+	if isNullable {
+		info = pointerTo(info)
+	}
+	return info, nil
+}
+
+// pointerTo wraps type information in a pointer, any value already accepts null
+func pointerTo(info TypeInfo) TypeInfo {
+	if info.Kind == "" {
+		return nullInfo()
+	}
+	if info.Kind == "interface" || info.Kind == "ptr" {
+		return info
+	}
+	elem := info
+	elem.Optional = false
+	return TypeInfo{Type: "*" + elem.Type, Kind: "ptr", Elem: &elem, Optional: info.Optional}
+}
+
+// schemaTypes returns the "type" keyword of a schema as a list
+func schemaTypes(value interface{}) ([]string, error) {
+	switch t := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{t}, nil
+	case []interface{}:
+		types := make([]string, 0, len(t))
+		for _, item := range t {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid type %v", item)
+			}
+			types = append(types, s)
+		}
+		return types, nil
+	}
+	return nil, fmt.Errorf("invalid type %v", value)
+}
@@ -0,0 +1,170 @@
+package dynReflect
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestToJSONSchema(t *testing.T) {
+	type order struct {
+		ID     int
+		Items  []string
+		Prices map[string]float64
+		Note   *string
+		Raw    []byte
+	}
+	schema := ToJSONSchema(BuildTypeInfo(reflect.ValueOf(order{})))
+
+	if schema["$schema"] != JSONSchemaDraft || schema["type"] != "object" || schema["title"] != "dynReflect.order" {
+		t.Errorf("unexpected root schema: %v", schema)
+	}
+	if schema["additionalProperties"] != false {
+		t.Errorf("expected structs to be closed objects, got %v", schema["additionalProperties"])
+	}
+	props := schema["properties"].(map[string]interface{})
+	want := map[string]interface{}{
+		"ID":     map[string]interface{}{"type": "integer"},
+		"Items":  map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		"Prices": map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{"type": "number"}},
+		"Note":   map[string]interface{}{"type": []interface{}{"string", "null"}},
+		"Raw":    map[string]interface{}{"type": "string", "contentEncoding": "base64"},
+	}
+	if !reflect.DeepEqual(props, want) {
+		t.Errorf("unexpected properties:\n got %v\nwant %v", props, want)
+	}
+	if len(schema["required"].([]interface{})) != 5 {
+		t.Errorf("expected all fields to be required, got %v", schema["required"])
+	}
+	if _, err := json.Marshal(schema); err != nil {
+		t.Errorf("expected the schema to marshal: %v", err)
+	}
+}
+
+func TestToJSONSchemaJSONTags(t *testing.T) {
+	type person struct {
+		FirstName string `json:"first_name"`
+		Nick      string `json:"nick,omitempty"`
+		Password  string `json:"-"`
+	}
+	schema := ToJSONSchema(BuildTypeInfo(reflect.ValueOf(person{})))
+
+	props := schema["properties"].(map[string]interface{})
+	want := map[string]interface{}{
+		"first_name": map[string]interface{}{"type": "string"},
+		"nick":       map[string]interface{}{"type": "string"},
+	}
+	if !reflect.DeepEqual(props, want) {
+		t.Errorf("expected the JSON names as properties:\n got %v\nwant %v", props, want)
+	}
+	if required := schema["required"]; !reflect.DeepEqual(required, []interface{}{"first_name"}) {
+		t.Errorf("expected only first_name to be required, got %v", required)
+	}
+}
+
+func TestFromJSONSchema(t *testing.T) {
+	doc := `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title": "Person",
+		"type": "object",
+		"properties": {
+			"name": {"type": "string"},
+			"age": {"type": "integer"},
+			"address": {"$ref": "#/$defs/address"},
+			"manager": {"anyOf": [{"$ref": "#/$defs/address"}, {"type": "null"}]},
+			"tags": {"type": "array", "items": {"type": "string"}},
+			"scores": {"type": "object", "additionalProperties": {"type": "number"}}
+		},
+		"required": ["name", "address"],
+		"$defs": {
+			"address": {"type": "object", "properties": {"city": {"type": "string"}}, "required": ["city"]}
+		}
+	}`
+	var schema map[string]interface{}
+	if err := json.Unmarshal([]byte(doc), &schema); err != nil {
+		t.Fatal(err)
+	}
+	if !IsJSONSchema(schema) {
+		t.Error("expected the document to be recognised as a JSON Schema")
+	}
+
+	info, err := FromJSONSchema(schema)
+	if err != nil {
+		t.Fatalf("FromJSONSchema() failed: %v", err)
+	}
+	if info.Type != "Person" || info.Kind != "struct" || len(info.Fields) != 6 {
+		t.Fatalf("unexpected type info: %+v", info)
+	}
+	if f := info.Fields["name"]; f.Kind != "string" || f.Optional {
+		t.Errorf("unexpected name field: %+v", f)
+	}
+	if f := info.Fields["age"]; f.Kind != "int64" || !f.Optional {
+		t.Errorf("unexpected age field: %+v", f)
+	}
+	if f := info.Fields["address"]; f.Kind != "struct" || f.Fields["city"].Kind != "string" {
+		t.Errorf("expected the address reference to be resolved: %+v", f)
+	}
+	if f := info.Fields["manager"]; f.Kind != "ptr" || f.Elem == nil || f.Elem.Kind != "struct" {
+		t.Errorf("expected anyOf with null to become a pointer: %+v", f)
+	}
+	if f := info.Fields["tags"]; f.Kind != "slice" || f.Elem.Kind != "string" {
+		t.Errorf("unexpected tags field: %+v", f)
+	}
+	if f := info.Fields["scores"]; f.Kind != "map" || f.ValueType.Kind != "float64" {
+		t.Errorf("expected additionalProperties to become a map: %+v", f)
+	}
+
+	violations := info.Validate(map[string]interface{}{"name": "Ada", "address": map[string]interface{}{"city": "London"}, "manager": nil})
+	if len(violations) != 0 {
+		t.Errorf("expected a valid object, got %v", violations)
+	}
+}
+
+func TestJSONSchemaRoundTrip(t *testing.T) {
+	type address struct {
+		City string
+	}
+	type person struct {
+		Name    string
+		Age     int64
+		Address *address
+		Tags    []string
+		Extra   map[string]interface{}
+	}
+	info := BuildTypeInfo(reflect.ValueOf(person{Address: &address{}}))
+	info.Fields["Age"] = TypeInfo{Type: "int64", Kind: "int64", Optional: true}
+
+	// Round trip through JSON, the way schemas are published
+	data, err := json.Marshal(ToJSONSchema(info))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+	back, err := FromJSONSchema(schema)
+	if err != nil {
+		t.Fatalf("FromJSONSchema() failed: %v", err)
+	}
+	if !reflect.DeepEqual(ToJSONSchema(back), ToJSONSchema(info)) {
+		t.Errorf("schema changed in the round trip:\n got %v\nwant %v", ToJSONSchema(back), ToJSONSchema(info))
+	}
+	if !back.Fields["Age"].Optional || back.Fields["Name"].Optional {
+		t.Errorf("expected optional fields to survive the round trip: %+v", back.Fields)
+	}
+}
+
+func TestFromJSONSchemaErrors(t *testing.T) {
+	for name, schema := range map[string]map[string]interface{}{
+		"unknown type":   {"type": "decimal"},
+		"bad reference":  {"$ref": "#/$defs/missing"},
+		"remote":         {"$ref": "https://example.com/schema.json"},
+		"recursive":      {"$ref": "#/$defs/node", "$defs": map[string]interface{}{"node": map[string]interface{}{"$ref": "#/$defs/node"}}},
+		"bad properties": {"type": "object", "properties": map[string]interface{}{"name": "string"}},
+	} {
+		if _, err := FromJSONSchema(schema); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
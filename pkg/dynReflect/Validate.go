@@ -0,0 +1,187 @@
+package dynReflect
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Violation describes a single place where a value does not match a TypeInfo
+type Violation struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// Validate compares the shape of obj against the type information and returns
+// every violation found, ordered by path. Paths are JSON paths rooted at "$".
+//
+// Maps are accepted where a struct is expected, their keys are matched to the JSON
+// names of the struct fields, falling back to a case-insensitive match like encoding/json.
+// All numeric kinds are interchangeable, except that integers must not carry a fraction.
+func (t TypeInfo) Validate(obj interface{}) []Violation {
+	var violations []Violation
+	validate(t, reflect.ValueOf(obj), "$", &violations)
+	sort.SliceStable(violations, func(i, j int) bool {
+		return violations[i].Path < violations[j].Path
+	})
+	return violations
+}
+
+func validate(info TypeInfo, v reflect.Value, path string, violations *[]Violation) {
+	addViolation := func(path string, format string, args ...interface{}) {
+		*violations = append(*violations, Violation{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	nullable := false
+	for info.Kind == "ptr" {
+		if info.Elem == nil {
+			// Only nulls have been seen so far
+			return
+		}
+		info = *info.Elem
+		nullable = true
+	}
+	if info.Kind == "" || info.Kind == "interface" {
+		return
+	}
+
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			v = reflect.Value{}
+			break
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		switch {
+		case nullable, info.Kind == "map", info.Kind == "slice":
+		default:
+			addViolation(path, "null value, expected %s", info.Type)
+		}
+		return
+	}
+
+	switch info.Kind {
+	case "struct":
+		fields, ok := objectFields(v)
+		if !ok {
+			addViolation(path, "kind mismatch, expected %s got %s", info.Type, v.Type())
+			return
+		}
+		if info.Fields == nil {
+			// The fields are unknown, e.g. the type info was taken from a nil pointer
+			return
+		}
+		matched := make(map[string]bool)
+		for name, fieldInfo := range info.Fields {
+			key, found := matchField(fields, name)
+			if !found {
+				if fieldInfo.Optional {
+					continue
+				}
+				addViolation(path+"."+name, "missing field, expected %s", fieldInfo.Type)
+				continue
+			}
+			matched[key] = true
+			validate(fieldInfo, fields[key], path+"."+key, violations)
+		}
+		for key := range fields {
+			if !matched[key] {
+				addViolation(path+"."+key, "unexpected field")
+			}
+		}
+
+	case "map":
+		fields, ok := objectFields(v)
+		if !ok {
+			addViolation(path, "kind mismatch, expected %s got %s", info.Type, v.Type())
+			return
+		}
+		if info.ValueType != nil {
+			for key, value := range fields {
+				validate(*info.ValueType, value, path+"."+key, violations)
+			}
+		}
+
+	case "slice", "array":
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			addViolation(path, "kind mismatch, expected %s got %s", info.Type, v.Type())
+			return
+		}
+		if info.Elem != nil {
+			for i := 0; i < v.Len(); i++ {
+				validate(*info.Elem, v.Index(i), fmt.Sprintf("%s[%d]", path, i), violations)
+			}
+		}
+
+	default:
+		if IsNumberKind(info.Kind) {
+			if !IsNumberKind(v.Kind().String()) {
+				addViolation(path, "kind mismatch, expected %s got %s", info.Type, v.Type())
+			} else if isFloatKind(v.Kind()) && !strings.HasPrefix(info.Kind, "float") && v.Float() != math.Trunc(v.Float()) {
+				addViolation(path, "expected an integer for %s, got %v", info.Type, v.Float())
+			}
+			return
+		}
+		if v.Kind().String() != info.Kind {
+			addViolation(path, "kind mismatch, expected %s got %s", info.Type, v.Type())
+		}
+	}
+}
+
+// objectFields returns the members of a map with string keys or the fields of a struct
+// under their JSON member names
+func objectFields(v reflect.Value) (map[string]reflect.Value, bool) {
+	fields := make(map[string]reflect.Value)
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			fields[iter.Key().String()] = iter.Value()
+		}
+	case reflect.Struct:
+		for _, field := range jsonFields(v.Type()) {
+			// A field promoted through a nil embedded pointer is left out, as by encoding/json
+			if fieldValue, err := v.FieldByIndexErr(field.Index); err == nil {
+				fields[field.Name] = fieldValue
+			}
+		}
+	default:
+		return nil, false
+	}
+	return fields, true
+}
+
+// matchField finds the member of fields that holds the struct field of JSON name name
+func matchField(fields map[string]reflect.Value, name string) (string, bool) {
+	if _, ok := fields[name]; ok {
+		return name, true
+	}
+	for key := range fields {
+		if strings.EqualFold(key, name) {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// IsNumberKind reports whether a TypeInfo Kind is one of the Go number kinds,
+// all of which hold a JSON number
+func IsNumberKind(kind string) bool {
+	switch kind {
+	case "int", "int8", "int16", "int32", "int64",
+		"uint", "uint8", "uint16", "uint32", "uint64",
+		"float32", "float64":
+		return true
+	}
+	return false
+}
+
+func isFloatKind(kind reflect.Kind) bool {
+	return kind == reflect.Float32 || kind == reflect.Float64
+}
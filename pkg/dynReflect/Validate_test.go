@@ -0,0 +1,128 @@
+package dynReflect
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+type address struct {
+	City string
+	Zip  int
+}
+
+type person struct {
+	Name    string
+	Age     int
+	Tags    []string
+	Address *address
+}
+
+func TestValidateStruct(t *testing.T) {
+	info := BuildTypeInfo(reflect.ValueOf(person{}))
+
+	valid := person{Name: "Ada", Age: 36, Tags: []string{"math"}, Address: &address{City: "London"}}
+	if violations := info.Validate(valid); len(violations) != 0 {
+		t.Errorf("expected no violations, got %v", violations)
+	}
+
+	// JSON decoded objects use lower case keys and float64 numbers
+	decoded := map[string]interface{}{
+		"name":    "Ada",
+		"age":     float64(36),
+		"tags":    []interface{}{"math"},
+		"address": nil,
+	}
+	if violations := info.Validate(decoded); len(violations) != 0 {
+		t.Errorf("expected no violations for a decoded map, got %v", violations)
+	}
+}
+
+func TestValidateViolations(t *testing.T) {
+	info := BuildTypeInfo(reflect.ValueOf(person{Address: &address{}}))
+
+	obj := map[string]interface{}{
+		"name":    42,
+		"age":     36.5,
+		"tags":    []interface{}{"math", true},
+		"address": map[string]interface{}{"city": "London", "zip": "N1", "country": "UK"},
+		"email":   "ada@example.com",
+	}
+	want := map[string]string{
+		"$.address.country": "unexpected field",
+		"$.address.zip":     "kind mismatch, expected int got string",
+		"$.age":             "expected an integer for int, got 36.5",
+		"$.email":           "unexpected field",
+		"$.name":            "kind mismatch, expected string got int",
+		"$.tags[1]":         "kind mismatch, expected string got bool",
+	}
+	violations := info.Validate(obj)
+	got := make(map[string]string)
+	for _, v := range violations {
+		got[v.Path] = v.Message
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected violations:\n got %v\nwant %v", got, want)
+	}
+	for i := 1; i < len(violations); i++ {
+		if violations[i-1].Path > violations[i].Path {
+			t.Errorf("expected violations ordered by path, got %v", violations)
+		}
+	}
+
+	missing := info.Validate(map[string]interface{}{"name": "Ada"})
+	if len(missing) != 3 {
+		t.Errorf("expected 3 missing fields, got %v", missing)
+	}
+	if violations := info.Validate("Ada"); len(violations) != 1 || violations[0].Path != "$" {
+		t.Errorf("expected a root kind mismatch, got %v", violations)
+	}
+}
+
+func TestValidateJSONTags(t *testing.T) {
+	type base struct {
+		ID int `json:"id"`
+	}
+	type user struct {
+		base
+		FirstName string `json:"first_name"`
+		Nick      string `json:"nick,omitempty"`
+		Password  string `json:"-"`
+		Dash      string `json:"-,"`
+		Email     string `json:",omitempty"`
+	}
+	info := BuildTypeInfo(reflect.ValueOf(user{}))
+
+	names := make([]string, 0, len(info.Fields))
+	for name := range info.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if want := []string{"-", "Email", "first_name", "id", "nick"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("expected the JSON names of the fields, got %v want %v", names, want)
+	}
+	if !info.Fields["nick"].Optional || !info.Fields["Email"].Optional || info.Fields["first_name"].Optional {
+		t.Errorf("expected only omitempty fields to be optional, got %+v", info.Fields)
+	}
+
+	decoded := map[string]interface{}{"id": float64(1), "first_name": "Ada", "-": "x"}
+	if violations := info.Validate(decoded); len(violations) != 0 {
+		t.Errorf("expected no violations for a decoded map, got %v", violations)
+	}
+	if violations := info.Validate(user{FirstName: "Ada"}); len(violations) != 0 {
+		t.Errorf("expected no violations for the struct itself, got %v", violations)
+	}
+	violations := info.Validate(map[string]interface{}{"id": float64(1), "FirstName": "Ada", "-": "x", "Password": "secret"})
+	got := make(map[string]string)
+	for _, v := range violations {
+		got[v.Path] = v.Message
+	}
+	want := map[string]string{
+		"$.FirstName":  "unexpected field",
+		"$.Password":   "unexpected field",
+		"$.first_name": "missing field, expected string",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected violations:\n got %v\nwant %v", got, want)
+	}
+}
@@ -0,0 +1,198 @@
+package jobGraph
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Node is a named job in a graph, it reads the consumed store keys and writes the produced ones
+type Node struct {
+	Name     string   `json:"name"`
+	Consumes []string `json:"consumes,omitempty"`
+	Produces []string `json:"produces,omitempty"`
+}
+
+// Graph is a DAG of jobs, a node depends on the nodes producing the keys it consumes.
+// TopNode is the node that completes the graph, the last node in topological order by default.
+type Graph struct {
+	GraphID string `json:"graphId"`
+	TopNode string `json:"topNode,omitempty"`
+	Nodes   []Node `json:"nodes"`
+}
+
+// CycleError is returned by Validate when the nodes of a graph depend on each other
+type CycleError struct {
+	Nodes []string // the nodes on or behind a cycle, in declaration order
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("job graph has a cycle through %s", strings.Join(e.Nodes, ", "))
+}
+
+// Validate checks that the graph has an ID, uniquely named nodes, at most one producer
+// per key, an existing TopNode and no cycles, which are reported as a *CycleError
+func (g Graph) Validate() error {
+	_, err := g.Order()
+	return err
+}
+
+// Order returns the node names in topological order, producers before consumers.
+// Nodes that do not depend on each other keep their declaration order.
+func (g Graph) Order() ([]string, error) {
+	if g.GraphID == "" {
+		return nil, errors.New("a job graph needs a graphId")
+	}
+	if len(g.Nodes) == 0 {
+		return nil, fmt.Errorf("job graph %s has no nodes", g.GraphID)
+	}
+	index := make(map[string]int, len(g.Nodes))
+	producer := make(map[string]string)
+	for i, node := range g.Nodes {
+		if node.Name == "" {
+			return nil, fmt.Errorf("job graph %s: node %d has no name", g.GraphID, i)
+		}
+		if _, dup := index[node.Name]; dup {
+			return nil, fmt.Errorf("job graph %s: duplicate node %s", g.GraphID, node.Name)
+		}
+		index[node.Name] = i
+		for _, key := range node.Produces {
+			if other, dup := producer[key]; dup {
+				return nil, fmt.Errorf("job graph %s: key %s is produced by both %s and %s", g.GraphID, key, other, node.Name)
+			}
+			producer[key] = node.Name
+		}
+	}
+	if _, ok := index[g.TopNode]; g.TopNode != "" && !ok {
+		return nil, fmt.Errorf("job graph %s: top node %s is not a node of the graph", g.GraphID, g.TopNode)
+	}
+
+	// Kahn's algorithm, always taking the first ready node in declaration order
+	downstream := g.downstream(producer)
+	inDegree := make([]int, len(g.Nodes))
+	for _, next := range downstream {
+		for _, i := range next {
+			inDegree[i]++
+		}
+	}
+	var ready []int
+	for i := range g.Nodes {
+		if inDegree[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	order := make([]string, 0, len(g.Nodes))
+	for len(ready) > 0 {
+		sort.Ints(ready)
+		i := ready[0]
+		ready = ready[1:]
+		order = append(order, g.Nodes[i].Name)
+		for _, next := range downstream[i] {
+			if inDegree[next]--; inDegree[next] == 0 {
+				ready = append(ready, next)
+			}
+		}
+	}
+	if len(order) < len(g.Nodes) {
+		var cyclic []string
+		for i, node := range g.Nodes {
+			if inDegree[i] > 0 {
+				cyclic = append(cyclic, node.Name)
+			}
+		}
+		return nil, &CycleError{Nodes: cyclic}
+	}
+	return order, nil
+}
+
+// Top returns TopNode, or the last node in topological order if it is not set
+func (g Graph) Top() (string, error) {
+	order, err := g.Order()
+	if err != nil {
+		return "", err
+	}
+	if g.TopNode != "" {
+		return g.TopNode, nil
+	}
+	return order[len(order)-1], nil
+}
+
+// Affected returns the nodes that must rerun when the given keys receive a new revision,
+// the nodes consuming them and everything downstream of those, in topological order
+func (g Graph) Affected(keys ...string) ([]string, error) {
+	order, err := g.Order()
+	if err != nil {
+		return nil, err
+	}
+	changed := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		changed[key] = true
+	}
+	rerun := make(map[string]bool)
+	byName := make(map[string]Node, len(g.Nodes))
+	for _, node := range g.Nodes {
+		byName[node.Name] = node
+	}
+	// Walking in topological order sees every producer before its consumers
+	affected := []string{}
+	for _, name := range order {
+		node := byName[name]
+		for _, key := range node.Consumes {
+			if changed[key] {
+				rerun[name] = true
+				break
+			}
+		}
+		if rerun[name] {
+			affected = append(affected, name)
+			for _, key := range node.Produces {
+				changed[key] = true
+			}
+		}
+	}
+	return affected, nil
+}
+
+// Inputs returns the keys consumed by the graph that no node produces, sorted
+func (g Graph) Inputs() []string {
+	produced := make(map[string]bool)
+	for _, node := range g.Nodes {
+		for _, key := range node.Produces {
+			produced[key] = true
+		}
+	}
+	seen := make(map[string]bool)
+	inputs := []string{}
+	for _, node := range g.Nodes {
+		for _, key := range node.Consumes {
+			if !produced[key] && !seen[key] {
+				seen[key] = true
+				inputs = append(inputs, key)
+			}
+		}
+	}
+	sort.Strings(inputs)
+	return inputs
+}
+
+// downstream lists, for every node index, the indexes of the nodes consuming its keys
+func (g Graph) downstream(producer map[string]string) [][]int {
+	index := make(map[string]int, len(g.Nodes))
+	for i, node := range g.Nodes {
+		index[node.Name] = i
+	}
+	downstream := make([][]int, len(g.Nodes))
+	for i, node := range g.Nodes {
+		seen := make(map[string]bool)
+		for _, key := range node.Consumes {
+			from, ok := producer[key]
+			if !ok || seen[from] {
+				continue
+			}
+			seen[from] = true
+			downstream[index[from]] = append(downstream[index[from]], i)
+		}
+	}
+	return downstream
+}
@@ -0,0 +1,92 @@
+package jobGraph
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// etl is a small pipeline: extract -> clean -> (report, stats) -> publish
+var etl = Graph{
+	GraphID: "etl",
+	Nodes: []Node{
+		{Name: "publish", Consumes: []string{"report", "stats"}, Produces: []string{"site"}},
+		{Name: "report", Consumes: []string{"clean"}, Produces: []string{"report"}},
+		{Name: "stats", Consumes: []string{"clean", "config"}, Produces: []string{"stats"}},
+		{Name: "clean", Consumes: []string{"raw"}, Produces: []string{"clean"}},
+		{Name: "extract", Consumes: []string{"source"}, Produces: []string{"raw"}},
+	},
+}
+
+func TestOrder(t *testing.T) {
+	order, err := etl.Order()
+	if err != nil {
+		t.Fatalf("Order() failed: %v", err)
+	}
+	want := []string{"extract", "clean", "report", "stats", "publish"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("expected %v, got %v", want, order)
+	}
+	if top, _ := etl.Top(); top != "publish" {
+		t.Errorf("expected publish as the top node, got %s", top)
+	}
+	if inputs := etl.Inputs(); !reflect.DeepEqual(inputs, []string{"config", "source"}) {
+		t.Errorf("unexpected inputs: %v", inputs)
+	}
+}
+
+func TestAffected(t *testing.T) {
+	tests := []struct {
+		keys []string
+		want []string
+	}{
+		{[]string{"source"}, []string{"extract", "clean", "report", "stats", "publish"}},
+		{[]string{"config"}, []string{"stats", "publish"}},
+		{[]string{"report"}, []string{"publish"}},
+		{[]string{"site"}, []string{}},
+		{[]string{"config", "clean"}, []string{"report", "stats", "publish"}},
+	}
+	for _, tt := range tests {
+		got, err := etl.Affected(tt.keys...)
+		if err != nil {
+			t.Fatalf("Affected(%v) failed: %v", tt.keys, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Affected(%v) = %v, want %v", tt.keys, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cyclic := Graph{GraphID: "loop", Nodes: []Node{
+		{Name: "a", Consumes: []string{"c"}, Produces: []string{"a"}},
+		{Name: "b", Consumes: []string{"a"}, Produces: []string{"b"}},
+		{Name: "c", Consumes: []string{"b"}, Produces: []string{"c"}},
+		{Name: "d", Consumes: []string{"x"}, Produces: []string{"d"}},
+	}}
+	var cycleErr *CycleError
+	if err := cyclic.Validate(); !errors.As(err, &cycleErr) || !reflect.DeepEqual(cycleErr.Nodes, []string{"a", "b", "c"}) {
+		t.Errorf("expected a cycle through a, b and c, got %v", err)
+	}
+	self := Graph{GraphID: "self", Nodes: []Node{{Name: "a", Consumes: []string{"a"}, Produces: []string{"a"}}}}
+	if err := self.Validate(); !errors.As(err, &cycleErr) {
+		t.Errorf("expected a node consuming its own key to be a cycle, got %v", err)
+	}
+
+	invalid := []Graph{
+		{Nodes: []Node{{Name: "a"}}},
+		{GraphID: "empty"},
+		{GraphID: "unnamed", Nodes: []Node{{Produces: []string{"a"}}}},
+		{GraphID: "dup", Nodes: []Node{{Name: "a"}, {Name: "a"}}},
+		{GraphID: "producers", Nodes: []Node{{Name: "a", Produces: []string{"x"}}, {Name: "b", Produces: []string{"x"}}}},
+		{GraphID: "top", TopNode: "z", Nodes: []Node{{Name: "a"}}},
+	}
+	for _, g := range invalid {
+		if err := g.Validate(); err == nil {
+			t.Errorf("expected graph %q to be invalid", g.GraphID)
+		}
+	}
+	if err := etl.Validate(); err != nil {
+		t.Errorf("expected the etl graph to be valid, got %v", err)
+	}
+}
@@ -0,0 +1,334 @@
+package jobPool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/cfjello/go-store/pkg/config"
+)
+
+// Scaling approaches read from config.NodeConfig.Approach
+const (
+	// ApproachBinary moves half the distance to Minimum or Maximum at each decision
+	ApproachBinary = "binary"
+	// ApproachLinear adds or removes one worker at each decision
+	ApproachLinear = "linear"
+)
+
+// Job status values passed to OnStatus, the same as the store job statuses
+const (
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// maxDecisions is the number of recent scaling decisions kept for Stats
+const maxDecisions = 32
+
+// ErrStopped is returned by Submit once the pool is stopped
+var ErrStopped = errors.New("job pool stopped")
+
+// Task is a queued job, Run is called by a worker with the pool context
+type Task struct {
+	JobID string
+	Run   func(ctx context.Context) error
+}
+
+// Decision records a sample taken by the pool and the worker count it chose
+type Decision struct {
+	Time    time.Time `json:"time"`
+	Queued  int       `json:"queued"`
+	Busy    int       `json:"busy"`
+	From    int       `json:"from"`
+	To      int       `json:"to"`
+	Reason  string    `json:"reason"`
+	Skipped bool      `json:"skipped,omitempty"` // one of the first SkipFirst samples
+}
+
+// Stats is a snapshot of the state of a pool
+type Stats struct {
+	Name      string     `json:"name"`
+	Approach  string     `json:"approach"`
+	Minimum   int        `json:"minimum"`
+	Maximum   int        `json:"maximum"`
+	Workers   int        `json:"workers"`
+	Busy      int        `json:"busy"`
+	Queued    int        `json:"queued"`
+	Completed int64      `json:"completed"`
+	Failed    int64      `json:"failed"`
+	Samples   int64      `json:"samples"`
+	Grown     int64      `json:"grown"`
+	Shrunk    int64      `json:"shrunk"`
+	Decisions []Decision `json:"decisions"`
+}
+
+// Pool runs queued tasks on a number of workers that grows and shrinks between
+// NodeConfig.Minimum and NodeConfig.Maximum. Every NodeConfig.TimerMS the queue is
+// sampled: more than JobThreshold queued tasks grows the pool, an empty queue with
+// idle workers shrinks it. The first SkipFirst samples only warm up and change nothing.
+type Pool struct {
+	cfg   config.NodeConfig
+	queue chan Task
+	quit  chan struct{}
+
+	// OnStatus, when set before Start, is called as tasks start and finish
+	OnStatus func(jobID string, status string, err error)
+
+	mu        sync.Mutex
+	workers   int // workers that have not been told to quit
+	busy      int
+	completed int64
+	failed    int64
+	samples   int64
+	grown     int64
+	shrunk    int64
+	decisions []Decision
+	closing   bool // set by Stop, no more workers are started
+
+	// submitMu guards closing the queue against concurrent submits
+	submitMu sync.RWMutex
+	stopped  bool
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	workerWG  sync.WaitGroup
+	samplerWG sync.WaitGroup
+}
+
+// New creates a pool from a node configuration, queueSize bounds the number of waiting tasks
+func New(cfg config.NodeConfig, queueSize int) (*Pool, error) {
+	if cfg.Minimum < 1 || cfg.Maximum < cfg.Minimum {
+		return nil, fmt.Errorf("job pool %s: invalid worker range %d to %d", cfg.Name, cfg.Minimum, cfg.Maximum)
+	}
+	if cfg.Approach == "" {
+		cfg.Approach = ApproachBinary
+	}
+	if cfg.Approach != ApproachBinary && cfg.Approach != ApproachLinear {
+		return nil, fmt.Errorf("job pool %s: unknown approach %q", cfg.Name, cfg.Approach)
+	}
+	if queueSize < 1 {
+		return nil, fmt.Errorf("job pool %s: invalid queue size %d", cfg.Name, queueSize)
+	}
+	return &Pool{
+		cfg:   cfg,
+		queue: make(chan Task, queueSize),
+		quit:  make(chan struct{}, cfg.Maximum),
+	}, nil
+}
+
+// Start starts Minimum workers and the sampling timer, tasks run with ctx
+func (p *Pool) Start(ctx context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ctx, p.cancel = context.WithCancel(ctx)
+	for i := 0; i < p.cfg.Minimum; i++ {
+		p.startWorker()
+	}
+	if p.cfg.TimerMS > 0 {
+		p.samplerWG.Add(1)
+		go p.sample(time.Duration(p.cfg.TimerMS) * time.Millisecond)
+	}
+}
+
+// Stop stops accepting tasks, lets the workers finish the queued ones and waits for them
+func (p *Pool) Stop() {
+	p.mu.Lock()
+	p.closing = true
+	p.mu.Unlock()
+
+	p.submitMu.Lock()
+	if p.stopped {
+		p.submitMu.Unlock()
+		return
+	}
+	p.stopped = true
+	close(p.queue)
+	p.submitMu.Unlock()
+
+	p.workerWG.Wait()
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.samplerWG.Wait()
+}
+
+// Submit queues a task, waiting for room in the queue until ctx is done
+func (p *Pool) Submit(ctx context.Context, task Task) error {
+	if task.Run == nil {
+		return fmt.Errorf("job %s has nothing to run", task.JobID)
+	}
+	p.submitMu.RLock()
+	defer p.submitMu.RUnlock()
+	if p.stopped {
+		return ErrStopped
+	}
+	select {
+	case p.queue <- task:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats returns a snapshot of the pool state and its recent decisions
+func (p *Pool) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return Stats{
+		Name:      p.cfg.Name,
+		Approach:  p.cfg.Approach,
+		Minimum:   p.cfg.Minimum,
+		Maximum:   p.cfg.Maximum,
+		Workers:   p.workers,
+		Busy:      p.busy,
+		Queued:    len(p.queue),
+		Completed: p.completed,
+		Failed:    p.failed,
+		Samples:   p.samples,
+		Grown:     p.grown,
+		Shrunk:    p.shrunk,
+		Decisions: append([]Decision{}, p.decisions...),
+	}
+}
+
+// Evaluate takes a sample and resizes the pool, it is called every TimerMS
+// and returns the decision taken
+func (p *Pool) Evaluate() Decision {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.samples++
+	d := Decision{Time: time.Now(), Queued: len(p.queue), Busy: p.busy, From: p.workers, To: p.workers}
+	switch {
+	case p.closing:
+		d.Reason = "stopping"
+	case p.samples <= int64(p.cfg.SkipFirst):
+		d.Skipped = true
+		d.Reason = "warming up"
+	case d.Queued > p.cfg.JobThreshold && p.workers < p.cfg.Maximum:
+		d.To = p.workers + p.step(p.cfg.Maximum-p.workers)
+		d.Reason = fmt.Sprintf("%d queued jobs exceed the threshold of %d", d.Queued, p.cfg.JobThreshold)
+	case d.Queued == 0 && p.busy < p.workers && p.workers > p.cfg.Minimum:
+		idle := p.workers - p.busy
+		d.To = p.workers - min(p.step(p.workers-p.cfg.Minimum), idle)
+		d.Reason = fmt.Sprintf("%d idle workers", idle)
+	default:
+		d.Reason = "steady"
+	}
+
+	for n := d.From; n < d.To; n++ {
+		p.startWorker()
+	}
+	for n := d.To; n < d.From; n++ {
+		// An idle worker takes the token and quits
+		p.workers--
+		p.quit <- struct{}{}
+	}
+	if d.To > d.From {
+		p.grown++
+	} else if d.To < d.From {
+		p.shrunk++
+	}
+	p.decisions = append(p.decisions, d)
+	if len(p.decisions) > maxDecisions {
+		p.decisions = p.decisions[len(p.decisions)-maxDecisions:]
+	}
+	return d
+}
+
+// step is the number of workers to add or remove given the distance to the bound
+func (p *Pool) step(distance int) int {
+	if p.cfg.Approach == ApproachLinear || distance <= 1 {
+		return min(distance, 1)
+	}
+	return (distance + 1) / 2
+}
+
+func (p *Pool) sample(interval time.Duration) {
+	defer p.samplerWG.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+			d := p.Evaluate()
+			if d.To != d.From {
+				log.Printf("Job pool %s: %d -> %d workers, %s", p.cfg.Name, d.From, d.To, d.Reason)
+			}
+		}
+	}
+}
+
+// startWorker starts a worker, the caller holds p.mu
+func (p *Pool) startWorker() {
+	p.workers++
+	p.workerWG.Add(1)
+	go p.work()
+}
+
+func (p *Pool) work() {
+	defer p.workerWG.Done()
+	for {
+		select {
+		case <-p.quit:
+			return
+		case task, ok := <-p.queue:
+			if !ok {
+				p.mu.Lock()
+				p.workers--
+				p.mu.Unlock()
+				return
+			}
+			p.run(task)
+		}
+	}
+}
+
+func (p *Pool) run(task Task) {
+	p.mu.Lock()
+	p.busy++
+	p.mu.Unlock()
+	p.status(task.JobID, StatusRunning, nil)
+
+	ctx := p.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	err := safeRun(ctx, task)
+
+	p.mu.Lock()
+	p.busy--
+	if err != nil {
+		p.failed++
+	} else {
+		p.completed++
+	}
+	p.mu.Unlock()
+	if err != nil {
+		p.status(task.JobID, StatusFailed, err)
+	} else {
+		p.status(task.JobID, StatusDone, nil)
+	}
+}
+
+func (p *Pool) status(jobID string, status string, err error) {
+	if p.OnStatus != nil && jobID != "" {
+		p.OnStatus(jobID, status, err)
+	}
+}
+
+// safeRun turns a panicking task into a failed one
+func safeRun(ctx context.Context, task Task) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job %s panicked: %v", task.JobID, r)
+		}
+	}()
+	return task.Run(ctx)
+}
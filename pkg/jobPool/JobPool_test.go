@@ -0,0 +1,138 @@
+package jobPool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cfjello/go-store/pkg/config"
+)
+
+func testConfig() config.NodeConfig {
+	return config.NodeConfig{Name: "test", JobThreshold: 2, Minimum: 1, Maximum: 9, Approach: ApproachBinary, SkipFirst: 1}
+}
+
+func TestNewValidatesConfig(t *testing.T) {
+	for _, cfg := range []config.NodeConfig{
+		{Minimum: 0, Maximum: 2},
+		{Minimum: 3, Maximum: 2},
+		{Minimum: 1, Maximum: 2, Approach: "random"},
+	} {
+		if _, err := New(cfg, 10); err == nil {
+			t.Errorf("expected New() to refuse %+v", cfg)
+		}
+	}
+	if _, err := New(config.DefaultConfig().Nodes, 10); err != nil {
+		t.Errorf("expected the default node config to be valid, got %v", err)
+	}
+}
+
+func TestPoolRunsTasks(t *testing.T) {
+	pool, _ := New(testConfig(), 10)
+	var mu sync.Mutex
+	statuses := map[string][]string{}
+	pool.OnStatus = func(jobID string, status string, err error) {
+		mu.Lock()
+		statuses[jobID] = append(statuses[jobID], status)
+		mu.Unlock()
+	}
+	pool.Start(context.Background())
+
+	ctx := context.Background()
+	pool.Submit(ctx, Task{JobID: "ok", Run: func(ctx context.Context) error { return nil }})
+	pool.Submit(ctx, Task{JobID: "err", Run: func(ctx context.Context) error { return errors.New("boom") }})
+	pool.Submit(ctx, Task{JobID: "panic", Run: func(ctx context.Context) error { panic("boom") }})
+	pool.Stop()
+
+	stats := pool.Stats()
+	if stats.Completed != 1 || stats.Failed != 2 || stats.Workers != 0 {
+		t.Errorf("unexpected stats after Stop(): %+v", stats)
+	}
+	if got := statuses["ok"]; len(got) != 2 || got[1] != StatusDone {
+		t.Errorf("unexpected statuses for ok: %v", got)
+	}
+	if got := statuses["panic"]; len(got) != 2 || got[1] != StatusFailed {
+		t.Errorf("unexpected statuses for panic: %v", got)
+	}
+	if err := pool.Submit(ctx, Task{Run: func(ctx context.Context) error { return nil }}); !errors.Is(err, ErrStopped) {
+		t.Errorf("expected ErrStopped after Stop(), got %v", err)
+	}
+}
+
+func TestPoolScaling(t *testing.T) {
+	pool, _ := New(testConfig(), 100)
+	pool.Start(context.Background())
+	defer pool.Stop()
+
+	// Block the workers so the queue builds up
+	release := make(chan struct{})
+	blocked := func(ctx context.Context) error { <-release; return nil }
+	for i := 0; i < 20; i++ {
+		pool.Submit(context.Background(), Task{Run: blocked})
+	}
+
+	if d := pool.Evaluate(); !d.Skipped || d.To != 1 {
+		t.Errorf("expected the first sample to be skipped, got %+v", d)
+	}
+	// Binary steps halve the distance to Maximum: 1 -> 5 -> 7 -> 8 -> 9
+	for _, want := range []int{5, 7, 8, 9, 9} {
+		if d := pool.Evaluate(); d.To != want {
+			t.Errorf("expected to grow to %d workers, got %+v", want, d)
+		}
+	}
+
+	close(release)
+	waitFor(t, func() bool { s := pool.Stats(); return s.Queued == 0 && s.Busy == 0 })
+	// And halve the distance to Minimum on the way down: 9 -> 5 -> 3 -> 2 -> 1
+	for _, want := range []int{5, 3, 2, 1, 1} {
+		if d := pool.Evaluate(); d.To != want {
+			t.Errorf("expected to shrink to %d workers, got %+v", want, d)
+		}
+	}
+	stats := pool.Stats()
+	if stats.Grown != 4 || stats.Shrunk != 4 || stats.Samples != 11 || len(stats.Decisions) != 11 {
+		t.Errorf("unexpected decision metrics: %+v", stats)
+	}
+}
+
+func TestPoolLinearApproach(t *testing.T) {
+	cfg := testConfig()
+	cfg.Approach = ApproachLinear
+	cfg.SkipFirst = 0
+	pool, _ := New(cfg, 100)
+	pool.Start(context.Background())
+	defer pool.Stop()
+
+	release := make(chan struct{})
+	defer close(release)
+	for i := 0; i < 10; i++ {
+		pool.Submit(context.Background(), Task{Run: func(ctx context.Context) error { <-release; return nil }})
+	}
+	for _, want := range []int{2, 3} {
+		if d := pool.Evaluate(); d.To != want {
+			t.Errorf("expected to grow to %d workers, got %+v", want, d)
+		}
+	}
+}
+
+func TestPoolTimer(t *testing.T) {
+	cfg := testConfig()
+	cfg.TimerMS = 5
+	pool, _ := New(cfg, 10)
+	pool.Start(context.Background())
+	waitFor(t, func() bool { return pool.Stats().Samples >= 3 })
+	pool.Stop()
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the pool")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
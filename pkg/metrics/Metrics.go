@@ -0,0 +1,200 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cfjello/go-store/pkg/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Namespace prefixes the names of all go-store metrics
+const Namespace = "gostore"
+
+// Registry holds the go-store metrics together with the Go runtime and process collectors
+var Registry = prometheus.NewRegistry()
+
+var (
+	storeOps = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "store",
+		Name:      "operations_total",
+		Help:      "Store operations by operation and result.",
+	}, []string{"operation", "result"})
+
+	storeLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "store",
+		Name:      "operation_duration_seconds",
+		Help:      "Latency of store operations.",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 9),
+	}, []string{"operation"})
+
+	sqlLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "sql",
+		Name:      "statement_duration_seconds",
+		Help:      "Latency of the prepared SQL statements by statement name.",
+		Buckets:   prometheus.ExponentialBuckets(0.00005, 4, 9),
+	}, []string{"statement"})
+
+	errorCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "errors_total",
+		Help:      "Errors by source (store, sql) and kind.",
+	}, []string{"source", "kind"})
+
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests by method, route pattern and status code.",
+	}, []string{"method", "route", "code"})
+
+	httpLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of HTTP requests by method and route pattern.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	httpInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "http",
+		Name:      "requests_in_flight",
+		Help:      "HTTP requests currently being served.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		storeOps, storeLatency, sqlLatency, errorCount,
+		httpRequests, httpLatency, httpInFlight,
+	)
+}
+
+// Handler serves the metrics of the Registry in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// RegisterDB exports the sql.DBStats of a database connection pool, labelled with dbName.
+// Registering the same dbName again replaces the earlier collector.
+func RegisterDB(db *sql.DB, dbName string) {
+	collector := collectors.NewDBStatsCollector(db, dbName)
+	if err := Registry.Register(collector); err != nil {
+		var already prometheus.AlreadyRegisteredError
+		if errors.As(err, &already) {
+			Registry.Unregister(already.ExistingCollector)
+			Registry.MustRegister(collector)
+		}
+	}
+}
+
+// ObserveStore records a store operation that started at start and ended with err
+func ObserveStore(operation string, start time.Time, err error) {
+	storeLatency.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		storeOps.WithLabelValues(operation, "error").Inc()
+		ObserveError("store", err)
+		return
+	}
+	storeOps.WithLabelValues(operation, "ok").Inc()
+}
+
+// ObserveSQL records an execution of the named prepared statement.
+// sql.ErrNoRows is an empty result rather than an error.
+func ObserveSQL(statement string, start time.Time, err error) {
+	sqlLatency.WithLabelValues(statement).Observe(time.Since(start).Seconds())
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		ObserveError("sql", err)
+	}
+}
+
+// ObserveError counts an error by source and kind, see ErrorKind
+func ObserveError(source string, err error) {
+	errorCount.WithLabelValues(source, ErrorKind(err)).Inc()
+}
+
+// ErrorKind classifies an error: the Name of a types.ExtError, "Timeout" or
+// "Canceled" for context errors, and "Error" for anything else
+func ErrorKind(err error) string {
+	var extErr *types.ExtError
+	switch {
+	case errors.As(err, &extErr) && extErr.Name != "":
+		return extErr.Name
+	case errors.Is(err, context.DeadlineExceeded):
+		return "Timeout"
+	case errors.Is(err, context.Canceled):
+		return "Canceled"
+	}
+	return "Error"
+}
+
+// Middleware records the HTTP request metrics of the handlers routed by mux.
+// Requests are labelled with the matched route pattern, or "unmatched", so that
+// path values such as keys do not create a series each.
+func Middleware(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, route := mux.Handler(r)
+		if route == "" {
+			route = "unmatched"
+		}
+		httpInFlight.Inc()
+		defer httpInFlight.Dec()
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		httpLatency.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+		httpRequests.WithLabelValues(r.Method, route, strconv.Itoa(rec.status)).Inc()
+	})
+}
+
+// statusRecorder remembers the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap gives http.ResponseController access to Flush, Hijack and the deadlines
+// of the underlying writer, which the watch streams depend on
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Hijack lets WebSocket upgrades take over the connection, they report 101 Switching Protocols
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(r.ResponseWriter).Hijack()
+	if err == nil && !r.wroteHeader {
+		r.status = http.StatusSwitchingProtocols
+		r.wroteHeader = true
+	}
+	return conn, rw, err
+}
@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cfjello/go-store/pkg/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestErrorKind(t *testing.T) {
+	conflict := &types.ExtError{Name: "Conflict", Message: "revision conflict"}
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{conflict, "Conflict"},
+		{fmt.Errorf("set failed: %w", conflict), "Conflict"},
+		{fmt.Errorf("query failed: %w", context.DeadlineExceeded), "Timeout"},
+		{context.Canceled, "Canceled"},
+		{errors.New("boom"), "Error"},
+	}
+	for _, test := range tests {
+		if got := ErrorKind(test.err); got != test.want {
+			t.Errorf("ErrorKind(%v) = %s, want %s", test.err, got, test.want)
+		}
+	}
+}
+
+func TestObserveStore(t *testing.T) {
+	ok := testutil.ToFloat64(storeOps.WithLabelValues("Test", "ok"))
+	failed := testutil.ToFloat64(storeOps.WithLabelValues("Test", "error"))
+	mismatches := testutil.ToFloat64(errorCount.WithLabelValues("store", "TypeMismatch"))
+
+	ObserveStore("Test", time.Now(), nil)
+	ObserveStore("Test", time.Now(), &types.ExtError{Name: "TypeMismatch"})
+
+	if got := testutil.ToFloat64(storeOps.WithLabelValues("Test", "ok")); got != ok+1 {
+		t.Errorf("expected %v successful operations, got %v", ok+1, got)
+	}
+	if got := testutil.ToFloat64(storeOps.WithLabelValues("Test", "error")); got != failed+1 {
+		t.Errorf("expected %v failed operations, got %v", failed+1, got)
+	}
+	if got := testutil.ToFloat64(errorCount.WithLabelValues("store", "TypeMismatch")); got != mismatches+1 {
+		t.Errorf("expected %v type mismatches, got %v", mismatches+1, got)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /things/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") == "missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	handler := Middleware(mux, mux)
+
+	for _, path := range []string{"/things/1", "/things/2", "/things/missing", "/other"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := testutil.ToFloat64(httpRequests.WithLabelValues("GET", "GET /things/{id}", "200")); got != 2 {
+		t.Errorf("expected 2 requests labelled with the route pattern, got %v", got)
+	}
+	if got := testutil.ToFloat64(httpRequests.WithLabelValues("GET", "GET /things/{id}", "404")); got != 1 {
+		t.Errorf("expected 1 not found request, got %v", got)
+	}
+	if got := testutil.ToFloat64(httpRequests.WithLabelValues("GET", "unmatched", "404")); got != 1 {
+		t.Errorf("expected 1 unmatched request, got %v", got)
+	}
+	if got := testutil.ToFloat64(httpInFlight); got != 0 {
+		t.Errorf("expected no requests in flight, got %v", got)
+	}
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"github.com/cfjello/go-store/pkg/jobGraph"
+	"github.com/cfjello/go-store/pkg/types"
+)
+
+// ErrNotFound is wrapped in the errors of every Backend when a key, storeID, job
+// or job graph does not exist
+var ErrNotFound = errors.New("not found")
+
+// Backend is the storage behind a Store.
+// The SQLite DBService in internal/database is the production implementation,
+// MemBackend keeps everything in process and needs no cgo.
+type Backend interface {
+	// SetMeta creates or replaces the metadata of a key
+	SetMeta(key string, meta types.MetaData) bool
+	// GetMeta gets the metadata of a key, or of a key registered under schemaKey
+	GetMeta(key string, schemaKey string) (types.MetaData, error)
+	// SetData stores a new revision of an object and, unless event is nil, logs the
+	// event in the same transaction, so the change log never misses a stored revision
+	SetData(key string, data types.SetArgs, event *types.Event) bool
+	// GetData gets the object stored under a storeID, if that revision belongs to key
+	GetData(key string, storeID string) (any, error)
+	// GetCurrStoreID gets the storeID of the latest revision of a key
+	GetCurrStoreID(key string) (string, error)
+	// GetRevisions gets the revisions of a key in storeID order, starting after opts.Cursor
+	GetRevisions(key string, opts types.HistoryOpts) ([]types.Revision, error)
+	// SetJob creates or replaces the status record of a job
+	SetJob(job types.Job) bool
+	// GetJob gets the status record of a job
+	GetJob(jobID string) (types.Job, error)
+	// GetJobs gets the jobs started in [from, to), ordered by start time
+	GetJobs(from time.Time, to time.Time) ([]types.Job, error)
+	// GetJobStoreIDs gets the storeIDs of the revisions stored under a jobID, in storeID order
+	GetJobStoreIDs(jobID string) ([]string, error)
+	// GetJobRevisions gets the revisions stored under a jobID, in storeID order
+	GetJobRevisions(jobID string) ([]types.Revision, error)
+	// SetGraph creates or replaces a job graph
+	SetGraph(graph jobGraph.Graph) bool
+	// GetGraph gets a job graph
+	GetGraph(graphID string) (jobGraph.Graph, error)
+	// GetGraphs gets every job graph, ordered by graphID
+	GetGraphs() ([]jobGraph.Graph, error)
+	// DeleteGraph removes a job graph
+	DeleteGraph(graphID string) bool
+	// SetIndex declares a JSON path index, declaring an existing index again is a no-op
+	SetIndex(index types.Index) bool
+	// GetIndexes gets the declared indexes, ordered by schemaKey and path
+	GetIndexes() ([]types.Index, error)
+	// DeleteIndex removes a declared index
+	DeleteIndex(name string) bool
+	// LookupIndex gets the revisions whose value at lookup.Path matches, in value and storeID order
+	LookupIndex(lookup types.IndexLookup) ([]types.Revision, error)
+	// Query gets the latest revisions of the live keys that match query, sorted by
+	// query.Sort, which ends with "storeId", and starting after the sort values in after
+	Query(query types.Query, after []any) ([]types.Revision, error)
+	// SetSearchIndex creates or replaces the full-text index of a schema and fills it with
+	// the latest revisions of its live keys, the index follows every later change
+	SetSearchIndex(index types.SearchIndex) error
+	// GetSearchIndexes gets the full-text indexes, ordered by schemaKey
+	GetSearchIndexes() ([]types.SearchIndex, error)
+	// DeleteSearchIndex removes the full-text index of a schema
+	DeleteSearchIndex(schemaKey string) bool
+	// Search finds the live keys of query.SchemaKey whose indexed text matches query.Text,
+	// it fails with ErrNotIndexed without a search index and ErrSearchUnavailable without
+	// full-text search
+	Search(query types.SearchQuery) ([]types.SearchHit, error)
+	// AppendEvent adds an event to the durable change log
+	AppendEvent(event types.Event) bool
+	// GetEvents gets at most limit events logged after the event with ID after, in ID order
+	GetEvents(after string, limit int) ([]types.Event, error)
+	// LastEventID gets the ID of the latest logged event, or an empty string
+	LastEventID() (string, error)
+	// PruneEvents removes the events logged before the event with ID before and counts them
+	PruneEvents(before string) (int64, error)
+	// Stats counts the keys, revisions and bytes stored, leaving the write counters zero
+	Stats() (types.StoreStats, error)
+	// Delete permanently removes a key, its metadata and all its revisions
+	Delete(key string) bool
+	// Close releases the resources held by the backend
+	Close() error
+}
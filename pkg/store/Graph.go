@@ -0,0 +1,60 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/cfjello/go-store/pkg/jobGraph"
+)
+
+// SaveGraph validates a job graph and stores it, replacing a graph with the same ID.
+// The returned graph has its TopNode filled in.
+func (s *Store) SaveGraph(graph jobGraph.Graph) (jobGraph.Graph, error) {
+	top, err := graph.Top()
+	if err != nil {
+		return jobGraph.Graph{}, err
+	}
+	graph.TopNode = top
+	if !s.db.SetGraph(graph) {
+		return jobGraph.Graph{}, fmt.Errorf("failed to store job graph %s", graph.GraphID)
+	}
+	return graph, nil
+}
+
+// GetGraph gets a stored job graph
+func (s *Store) GetGraph(graphID string) (jobGraph.Graph, error) {
+	graph, err := s.db.GetGraph(graphID)
+	if err != nil {
+		return jobGraph.Graph{}, fmt.Errorf("no job graph found for %s: %w", graphID, err)
+	}
+	return graph, nil
+}
+
+// ListGraphs lists the stored job graphs, ordered by graphID
+func (s *Store) ListGraphs() ([]jobGraph.Graph, error) {
+	return s.db.GetGraphs()
+}
+
+// DeleteGraph removes a stored job graph
+func (s *Store) DeleteGraph(graphID string) bool {
+	return s.db.DeleteGraph(graphID)
+}
+
+// AffectedNodes returns, per graphID, the nodes that must rerun when the given keys
+// receive a new revision. Graphs without affected nodes are left out.
+func (s *Store) AffectedNodes(keys ...string) (map[string][]string, error) {
+	graphs, err := s.db.GetGraphs()
+	if err != nil {
+		return nil, err
+	}
+	affected := make(map[string][]string)
+	for _, graph := range graphs {
+		nodes, err := graph.Affected(keys...)
+		if err != nil {
+			return nil, fmt.Errorf("job graph %s: %w", graph.GraphID, err)
+		}
+		if len(nodes) > 0 {
+			affected[graph.GraphID] = nodes
+		}
+	}
+	return affected, nil
+}
@@ -0,0 +1,52 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cfjello/go-store/pkg/jobGraph"
+)
+
+func TestGraphs(t *testing.T) {
+	s := newTestStore(t)
+	graph := jobGraph.Graph{GraphID: "report", Nodes: []jobGraph.Node{
+		{Name: "load", Consumes: []string{"orders"}, Produces: []string{"sales"}},
+		{Name: "summarize", Consumes: []string{"sales"}, Produces: []string{"summary"}},
+	}}
+	saved, err := s.SaveGraph(graph)
+	if err != nil || saved.TopNode != "summarize" {
+		t.Fatalf("SaveGraph() failed: %+v (%v)", saved, err)
+	}
+	if _, err := s.SaveGraph(jobGraph.Graph{GraphID: "bad", Nodes: []jobGraph.Node{
+		{Name: "a", Consumes: []string{"a"}, Produces: []string{"a"}},
+	}}); err == nil {
+		t.Error("expected SaveGraph() to refuse a cyclic graph")
+	}
+	s.SaveGraph(jobGraph.Graph{GraphID: "audit", Nodes: []jobGraph.Node{{Name: "check", Consumes: []string{"orders", "summary"}}}})
+
+	loaded, err := s.GetGraph("report")
+	if err != nil || !reflect.DeepEqual(loaded, saved) {
+		t.Errorf("expected the saved graph, got %+v (%v)", loaded, err)
+	}
+	graphs, _ := s.ListGraphs()
+	if len(graphs) != 2 || graphs[0].GraphID != "audit" {
+		t.Errorf("unexpected graphs: %+v", graphs)
+	}
+
+	affected, err := s.AffectedNodes("sales")
+	want := map[string][]string{"report": {"summarize"}}
+	if err != nil || !reflect.DeepEqual(affected, want) {
+		t.Errorf("expected %v, got %v (%v)", want, affected, err)
+	}
+	affected, _ = s.AffectedNodes("orders")
+	if len(affected) != 2 || len(affected["report"]) != 2 {
+		t.Errorf("expected both graphs to be affected, got %v", affected)
+	}
+
+	if !s.DeleteGraph("report") || s.DeleteGraph("report") {
+		t.Error("expected DeleteGraph() to remove the graph once")
+	}
+	if _, err := s.GetGraph("report"); err == nil {
+		t.Error("expected the graph to be gone")
+	}
+}
@@ -0,0 +1,81 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cfjello/go-store/pkg/types"
+	"github.com/cfjello/go-store/pkg/util"
+)
+
+// History lists the stored revisions of a key, oldest first unless opts.Desc is set.
+// When opts.Limit is reached, NextCursor is set and can be passed back in opts.Cursor
+// to fetch the following page.
+func (s *Store) History(key string, opts types.HistoryOpts) (types.HistoryPage, error) {
+	if key == "" {
+		return types.HistoryPage{}, errors.New("no \"key\" provided for History()")
+	}
+	query := opts
+	if query.Limit > 0 {
+		// Fetch one extra revision to find out if there is a next page
+		query.Limit++
+	}
+	revisions, err := s.db.GetRevisions(key, query)
+	if err != nil {
+		return types.HistoryPage{}, fmt.Errorf("failed to fetch history for %s: %w", key, err)
+	}
+
+	page := types.HistoryPage{Revisions: revisions}
+	if opts.Limit > 0 && len(revisions) > opts.Limit {
+		page.Revisions = revisions[:opts.Limit]
+		page.NextCursor = page.Revisions[opts.Limit-1].StoreID
+	}
+	for i := range page.Revisions {
+		page.Revisions[i].Timestamp, _ = util.UlidTime(page.Revisions[i].StoreID)
+	}
+	return page, nil
+}
+
+// GetVersion gets a single revision of a key.
+// A version of 0 is the latest revision, -1 the previous one and so on,
+// while a positive version counts from the first revision, starting at 1.
+func (s *Store) GetVersion(key string, version int) (types.Revision, error) {
+	opts := types.HistoryOpts{Limit: version, Desc: false}
+	if version <= 0 {
+		opts = types.HistoryOpts{Limit: 1 - version, Desc: true}
+	}
+	page, err := s.History(key, opts)
+	if err != nil {
+		return types.Revision{}, err
+	}
+	if len(page.Revisions) < opts.Limit {
+		return types.Revision{}, fmt.Errorf("no version %d found for %s", version, key)
+	}
+	return page.Revisions[opts.Limit-1], nil
+}
+
+// GetAsOf gets the newest revision of a key whose storeID timestamp is at or before t,
+// which is the value a reader would have seen at that moment.
+func (s *Store) GetAsOf(key string, t time.Time) (types.Revision, error) {
+	if key == "" {
+		return types.Revision{}, errors.New("no \"key\" provided for GetAsOf()")
+	}
+	notFound := fmt.Errorf("no version of %s found as of %s", key, t.Format(time.RFC3339Nano))
+	if t.Before(time.UnixMilli(0)) {
+		// ULIDs cannot carry a time before the Unix epoch, and without a cursor the latest revision would qualify
+		return types.Revision{}, notFound
+	}
+	// storeIDs are ULIDs, so everything before the first ULID of the next millisecond qualifies
+	cursor := util.UlidLowerBound(t.Add(time.Millisecond))
+	revisions, err := s.db.GetRevisions(key, types.HistoryOpts{Limit: 1, Cursor: cursor, Desc: true})
+	if err != nil {
+		return types.Revision{}, fmt.Errorf("failed to fetch history for %s: %w", key, err)
+	}
+	if len(revisions) == 0 {
+		return types.Revision{}, notFound
+	}
+	rev := revisions[0]
+	rev.Timestamp, _ = util.UlidTime(rev.StoreID)
+	return rev, nil
+}
@@ -0,0 +1,226 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cfjello/go-store/pkg/dynReflect"
+	"github.com/cfjello/go-store/pkg/types"
+)
+
+func setVersions(t *testing.T, s *Store, key string, count int) {
+	t.Helper()
+	for i := 1; i <= count; i++ {
+		if _, err := s.Set(types.SetArgs{Key: key, Object: map[string]interface{}{"version": i}}); err != nil {
+			t.Fatalf("Set() failed: %v", err)
+		}
+	}
+}
+
+func TestHistoryPaging(t *testing.T) {
+	s := newTestStore(t)
+	before := time.Now().Add(-time.Second)
+	setVersions(t, s, "counter", 5)
+
+	var versions []float64
+	cursor, pages := "", 0
+	for {
+		page, err := s.History("counter", types.HistoryOpts{Limit: 2, Cursor: cursor})
+		if err != nil {
+			t.Fatalf("History() failed: %v", err)
+		}
+		pages++
+		for _, rev := range page.Revisions {
+			if rev.Timestamp.Before(before) || rev.StoreID == "" || rev.JobID == "" {
+				t.Errorf("unexpected revision: %+v", rev)
+			}
+			versions = append(versions, rev.Object.(map[string]interface{})["version"].(float64))
+		}
+		if cursor = page.NextCursor; cursor == "" {
+			break
+		}
+	}
+	if pages != 3 {
+		t.Errorf("expected 3 pages, got %d", pages)
+	}
+	if len(versions) != 5 {
+		t.Fatalf("expected 5 revisions, got %v", versions)
+	}
+	for i, v := range versions {
+		if v != float64(i+1) {
+			t.Errorf("expected revisions in order, got %v", versions)
+			break
+		}
+	}
+
+	page, err := s.History("counter", types.HistoryOpts{Desc: true})
+	if err != nil || len(page.Revisions) != 5 || page.NextCursor != "" {
+		t.Fatalf("unexpected descending history: %+v (%v)", page, err)
+	}
+	if page.Revisions[0].Object.(map[string]interface{})["version"] != float64(5) {
+		t.Errorf("expected the newest revision first, got %v", page.Revisions[0].Object)
+	}
+}
+
+func TestGetVersion(t *testing.T) {
+	s := newTestStore(t)
+	setVersions(t, s, "counter", 3)
+
+	for version, want := range map[int]float64{0: 3, -1: 2, -2: 1, 1: 1, 3: 3} {
+		rev, err := s.GetVersion("counter", version)
+		if err != nil {
+			t.Errorf("GetVersion(%d) failed: %v", version, err)
+			continue
+		}
+		if got := rev.Object.(map[string]interface{})["version"]; got != want {
+			t.Errorf("GetVersion(%d) = %v, want %v", version, got, want)
+		}
+	}
+	if _, err := s.GetVersion("counter", -3); err == nil {
+		t.Error("expected GetVersion() before the first revision to fail")
+	}
+	if _, err := s.GetVersion("counter", 4); err == nil {
+		t.Error("expected GetVersion() after the last revision to fail")
+	}
+}
+
+func TestGetAsOf(t *testing.T) {
+	s := newTestStore(t)
+	start := time.Now()
+	setVersions(t, s, "counter", 1)
+	time.Sleep(5 * time.Millisecond)
+	middle := time.Now()
+	time.Sleep(5 * time.Millisecond)
+	setVersions(t, s, "counter", 2)
+
+	if _, err := s.GetAsOf("counter", start.Add(-time.Second)); err == nil {
+		t.Error("expected GetAsOf() before the first revision to fail")
+	}
+	for _, before := range []time.Time{time.Unix(-1, 0), {}} {
+		if rev, err := s.GetAsOf("counter", before); err == nil {
+			t.Errorf("expected GetAsOf() before the Unix epoch to fail, got %+v", rev)
+		}
+	}
+	rev, err := s.GetAsOf("counter", middle)
+	if err != nil {
+		t.Fatalf("GetAsOf() failed: %v", err)
+	}
+	if rev.Object.(map[string]interface{})["version"] != float64(1) || rev.Timestamp.After(middle) {
+		t.Errorf("unexpected revision as of %v: %+v", middle, rev)
+	}
+	rev, err = s.GetAsOf("counter", time.Now())
+	if err != nil || rev.Object.(map[string]interface{})["version"] != float64(2) {
+		t.Errorf("expected the latest revision as of now, got %+v (%v)", rev, err)
+	}
+}
+
+func TestInferSchema(t *testing.T) {
+	s := newTestStore(t)
+	for _, obj := range []map[string]interface{}{
+		{"name": "Ada", "age": 36},
+		{"name": "Grace", "age": 85.5, "email": "grace@example.com"},
+		{"name": "Barbara", "age": nil},
+	} {
+		if _, err := s.Set(types.SetArgs{Key: "person", Object: obj}); err != nil {
+			t.Fatalf("Set() failed: %v", err)
+		}
+	}
+
+	info, err := s.InferSchema("person")
+	if err != nil {
+		t.Fatalf("InferSchema() failed: %v", err)
+	}
+	if age := info.Fields["age"]; age.Kind != "ptr" || age.Elem.Kind != "float64" {
+		t.Errorf("expected age to be a nullable number, got %+v", age)
+	}
+	if email := info.Fields["email"]; !email.Optional {
+		t.Errorf("expected email to be optional, got %+v", email)
+	}
+	if _, err := s.InferSchema("nobody"); err == nil {
+		t.Error("expected InferSchema() of an unknown key to fail")
+	}
+}
+
+func TestInferSchemaOfTaggedStruct(t *testing.T) {
+	s := newTestStore(t)
+	type person struct {
+		FirstName string `json:"first_name"`
+		Age       int    `json:"age"`
+	}
+	meta, err := s.Set(types.SetArgs{Key: "person", Object: person{FirstName: "Ada", Age: 36}})
+	if err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	inferred, err := s.InferSchema("person")
+	if err != nil {
+		t.Fatalf("InferSchema() failed: %v", err)
+	}
+
+	unified := dynReflect.Unify(meta.TypeInfo, inferred)
+	if len(unified.Fields) != 2 {
+		t.Errorf("expected the struct and its JSON to share field names, got %+v", unified.Fields)
+	}
+	for _, name := range []string{"first_name", "age"} {
+		if field, ok := unified.Fields[name]; !ok || field.Optional {
+			t.Errorf("expected %s to be a required field, got %+v", name, unified.Fields)
+		}
+	}
+}
+
+func TestRevisionChain(t *testing.T) {
+	s := newTestStore(t)
+	var storeIDs []string
+	for _, name := range []string{"Ada", "Grace", "Edsger"} {
+		meta, err := s.Set(types.SetArgs{Key: "person", Object: map[string]interface{}{"name": name}})
+		if err != nil {
+			t.Fatalf("Set() failed: %v", err)
+		}
+		if meta.StoreID == "" || meta.JobID != meta.StoreID {
+			t.Fatalf("expected Set() to return the new storeID, got %+v", meta)
+		}
+		storeIDs = append(storeIDs, meta.StoreID)
+	}
+
+	for i, name := range []string{"Ada", "Grace", "Edsger"} {
+		obj, err := s.Get(storeIDs[i], "person")
+		if err != nil || obj.(map[string]interface{})["name"] != name {
+			t.Errorf("expected revision %s to hold %s, got %v (%v)", storeIDs[i], name, obj, err)
+		}
+	}
+	page, _ := s.History("person", types.HistoryOpts{})
+	for i, rev := range page.Revisions {
+		prev := ""
+		if i > 0 {
+			prev = storeIDs[i-1]
+		}
+		if rev.StoreID != storeIDs[i] || rev.PrevStoreID != prev || rev.SchemaKey != "person" {
+			t.Errorf("unexpected revision %d: %+v", i, rev)
+		}
+	}
+	meta, _ := s.GetMetaData("person", "")
+	if meta.StoreID != storeIDs[2] {
+		t.Errorf("expected the metadata to point at the latest revision, got %+v", meta)
+	}
+}
+
+func TestSetSharedSchemaKey(t *testing.T) {
+	s := newTestStore(t)
+	ada, err := s.Set(types.SetArgs{Key: "ada", SchemaKey: "person", Object: map[string]interface{}{"name": "Ada"}, Check: true})
+	if err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	grace, err := s.Set(types.SetArgs{Key: "grace", SchemaKey: "person", Object: map[string]interface{}{"name": "Grace"}})
+	if err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if grace.Key != "grace" || grace.SchemaKey != "person" || !grace.Check {
+		t.Errorf("expected grace to share the schema of person, got %+v", grace)
+	}
+	meta, _ := s.GetMetaData("ada", "")
+	if meta.StoreID != ada.StoreID {
+		t.Errorf("expected ada to keep its latest revision, got %+v", meta)
+	}
+	if _, err := s.Set(types.SetArgs{Key: "edsger", SchemaKey: "person", Object: map[string]interface{}{"age": 72}}); err == nil {
+		t.Error("expected an object that does not match the shared schema to be refused")
+	}
+}
@@ -0,0 +1,78 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/cfjello/go-store/pkg/types"
+	"github.com/cfjello/go-store/pkg/util"
+)
+
+// ErrNotIndexed is returned by Lookup when no index is declared on the path
+var ErrNotIndexed = errors.New("no index declared")
+
+// CreateIndex declares an index on a JSON path of the objects stored under schemaKey,
+// such as "$.name" or "$.address.city". The existing revisions are indexed right away
+// and new ones as they are stored. Declaring an existing index again returns it.
+func (s *Store) CreateIndex(schemaKey string, path string) (types.Index, error) {
+	if schemaKey == "" {
+		return types.Index{}, errors.New("no \"schemaKey\" provided for CreateIndex()")
+	}
+	if !util.IsJSONPath(path) {
+		return types.Index{}, &types.ExtError{
+			Name:    "ValidationError",
+			Message: fmt.Sprintf("invalid JSON path %q, expected member names and array indexes like $.address.city", path),
+			Info:    map[string]string{"path": path},
+		}
+	}
+	index := types.Index{Name: indexName(schemaKey, path), SchemaKey: schemaKey, Path: path}
+	if !s.db.SetIndex(index) {
+		return types.Index{}, fmt.Errorf("failed to create index on %s %s", schemaKey, path)
+	}
+	return index, nil
+}
+
+// DropIndex removes the index on a JSON path of schemaKey
+func (s *Store) DropIndex(schemaKey string, path string) bool {
+	return s.db.DeleteIndex(indexName(schemaKey, path))
+}
+
+// ListIndexes lists the declared indexes, ordered by schemaKey and path
+func (s *Store) ListIndexes() ([]types.Index, error) {
+	return s.db.GetIndexes()
+}
+
+// Lookup finds the revisions stored under lookup.SchemaKey whose value at lookup.Path
+// equals lookup.Equal or lies in [lookup.From, lookup.To). The path must be indexed,
+// otherwise Lookup fails with ErrNotIndexed rather than scanning every revision.
+func (s *Store) Lookup(lookup types.IndexLookup) ([]types.Revision, error) {
+	indexes, err := s.db.GetIndexes()
+	if err != nil {
+		return nil, err
+	}
+	name := indexName(lookup.SchemaKey, lookup.Path)
+	indexed := false
+	for _, index := range indexes {
+		indexed = indexed || index.Name == name
+	}
+	if !indexed {
+		return nil, fmt.Errorf("%w on %s %s", ErrNotIndexed, lookup.SchemaKey, lookup.Path)
+	}
+
+	revisions, err := s.db.LookupIndex(lookup)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up %s %s: %w", lookup.SchemaKey, lookup.Path, err)
+	}
+	for i := range revisions {
+		revisions[i].Timestamp, _ = util.UlidTime(revisions[i].StoreID)
+	}
+	return revisions, nil
+}
+
+// indexName derives a stable index name that is safe to use in SQL
+func indexName(schemaKey string, path string) string {
+	sum := sha256.Sum256([]byte(schemaKey + "\x00" + path))
+	return "jx_" + hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,91 @@
+package store
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cfjello/go-store/pkg/types"
+)
+
+func setPeople(t *testing.T, s *Store) {
+	t.Helper()
+	people := []struct {
+		key  string
+		name string
+		age  int
+		city string
+	}{
+		{"ada", "Ada", 36, "London"},
+		{"grace", "Grace", 85, "New York"},
+		{"edsger", "Edsger", 72, "Nuenen"},
+		{"alan", "Alan", 41, "Wilmslow"},
+	}
+	for _, p := range people {
+		obj := map[string]interface{}{"name": p.name, "age": p.age, "address": map[string]interface{}{"city": p.city}}
+		if _, err := s.Set(types.SetArgs{Key: p.key, SchemaKey: "person", Object: obj}); err != nil {
+			t.Fatalf("Set() failed: %v", err)
+		}
+	}
+}
+
+func lookupKeys(t *testing.T, s *Store, lookup types.IndexLookup) []string {
+	t.Helper()
+	revisions, err := s.Lookup(lookup)
+	if err != nil {
+		t.Fatalf("Lookup() failed: %v", err)
+	}
+	keys := make([]string, len(revisions))
+	for i, rev := range revisions {
+		keys[i] = rev.Key
+	}
+	return keys
+}
+
+func TestIndexLookup(t *testing.T) {
+	s := newTestStore(t)
+	setPeople(t, s)
+
+	if _, err := s.Lookup(types.IndexLookup{SchemaKey: "person", Path: "$.age", Equal: 36}); !errors.Is(err, ErrNotIndexed) {
+		t.Fatalf("expected ErrNotIndexed, got %v", err)
+	}
+	var extErr *types.ExtError
+	if _, err := s.CreateIndex("person", "$.name'; DROP TABLE data; --"); !errors.As(err, &extErr) || extErr.Name != "ValidationError" {
+		t.Errorf("expected an invalid path to be refused, got %v", err)
+	}
+	for _, path := range []string{"$.age", "$.address.city"} {
+		if _, err := s.CreateIndex("person", path); err != nil {
+			t.Fatalf("CreateIndex() failed: %v", err)
+		}
+	}
+	if indexes, _ := s.ListIndexes(); len(indexes) != 2 || indexes[0].Path != "$.address.city" {
+		t.Errorf("unexpected indexes: %+v", indexes)
+	}
+
+	if keys := lookupKeys(t, s, types.IndexLookup{SchemaKey: "person", Path: "$.address.city", Equal: "Nuenen"}); len(keys) != 1 || keys[0] != "edsger" {
+		t.Errorf("expected edsger, got %v", keys)
+	}
+	if keys := lookupKeys(t, s, types.IndexLookup{SchemaKey: "person", Path: "$.age", From: 40, To: 80.0}); len(keys) != 2 || keys[0] != "alan" || keys[1] != "edsger" {
+		t.Errorf("expected alan and edsger by age, got %v", keys)
+	}
+	if keys := lookupKeys(t, s, types.IndexLookup{SchemaKey: "person", Path: "$.age", From: 40, Limit: 1}); len(keys) != 1 || keys[0] != "alan" {
+		t.Errorf("expected the limit to apply, got %v", keys)
+	}
+
+	// Older revisions match unless only the latest ones are asked for
+	s.Set(types.SetArgs{Key: "ada", Object: map[string]interface{}{"name": "Ada", "age": 37, "address": map[string]interface{}{"city": "London"}}})
+	s.UnRegister("grace")
+	if keys := lookupKeys(t, s, types.IndexLookup{SchemaKey: "person", Path: "$.address.city"}); len(keys) != 5 {
+		t.Errorf("expected every revision, got %v", keys)
+	}
+	revisions, _ := s.Lookup(types.IndexLookup{SchemaKey: "person", Path: "$.age", Latest: true})
+	if len(revisions) != 3 || revisions[0].Key != "ada" || revisions[0].Object.(map[string]interface{})["age"] != float64(37) {
+		t.Errorf("expected the latest revisions of the live keys, got %+v", revisions)
+	}
+
+	if !s.DropIndex("person", "$.age") || s.DropIndex("person", "$.age") {
+		t.Error("expected DropIndex() to remove the index once")
+	}
+	if _, err := s.Lookup(types.IndexLookup{SchemaKey: "person", Path: "$.age", Equal: 36}); !errors.Is(err, ErrNotIndexed) {
+		t.Errorf("expected ErrNotIndexed after DropIndex(), got %v", err)
+	}
+}
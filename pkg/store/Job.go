@@ -0,0 +1,118 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cfjello/go-store/pkg/types"
+	"github.com/cfjello/go-store/pkg/util"
+)
+
+// Job status values, a job is finished once it is done or failed
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// StartJob records a running job and returns it, a new jobID is generated if none is given.
+// Objects stored with SetArgs.JobID set to the jobID belong to the job.
+func (s *Store) StartJob(jobID string) (types.Job, error) {
+	if jobID == "" {
+		jobID = util.Ulid()
+	}
+	now := time.Now().UTC()
+	job := types.Job{JobID: jobID, Status: JobRunning, Started: now, Updated: now}
+	if !s.db.SetJob(job) {
+		return types.Job{}, fmt.Errorf("failed to store job %s", jobID)
+	}
+	return job, nil
+}
+
+// SetJobStatus records the status of a job, starting it if it is unknown.
+// Info is merged into the info already recorded for the job.
+func (s *Store) SetJobStatus(jobID string, status string, info map[string]string) (types.Job, error) {
+	if jobID == "" {
+		return types.Job{}, errors.New("no \"jobId\" provided for SetJobStatus()")
+	}
+	if status == "" {
+		return types.Job{}, errors.New("no \"status\" provided for SetJobStatus()")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	job, err := s.db.GetJob(jobID)
+	if err != nil {
+		job = types.Job{JobID: jobID, Started: now}
+	}
+	job.Status = status
+	job.Updated = now
+	job.Finished = nil
+	if status == JobDone || status == JobFailed {
+		job.Finished = &now
+	}
+	if len(info) > 0 && job.Info == nil {
+		job.Info = make(map[string]string, len(info))
+	}
+	for k, v := range info {
+		job.Info[k] = v
+	}
+	if !s.db.SetJob(job) {
+		return types.Job{}, fmt.Errorf("failed to store job %s", jobID)
+	}
+	return job, nil
+}
+
+// GetJob gets the recorded status of a job
+func (s *Store) GetJob(jobID string) (types.Job, error) {
+	job, err := s.db.GetJob(jobID)
+	if err != nil {
+		return types.Job{}, fmt.Errorf("no job found for %s: %w", jobID, err)
+	}
+	return job, nil
+}
+
+// ListJobs lists the jobs started in [from, to), oldest first.
+// A zero to lists every job started since from.
+func (s *Store) ListJobs(from time.Time, to time.Time) ([]types.Job, error) {
+	if to.IsZero() {
+		to = time.Now().Add(time.Hour)
+	}
+	return s.db.GetJobs(from, to)
+}
+
+// GetStoreIDs lists the storeIDs of every revision stored under a jobID, oldest first
+func (s *Store) GetStoreIDs(jobID string) ([]string, error) {
+	if jobID == "" {
+		return nil, errors.New("no \"jobId\" provided for GetStoreIDs()")
+	}
+	return s.db.GetJobStoreIDs(jobID)
+}
+
+// GetJobObjects gets every revision stored under a jobID, oldest first
+func (s *Store) GetJobObjects(jobID string) ([]types.Revision, error) {
+	if jobID == "" {
+		return nil, errors.New("no \"jobId\" provided for GetJobObjects()")
+	}
+	revisions, err := s.db.GetJobRevisions(jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch objects of job %s: %w", jobID, err)
+	}
+	for i := range revisions {
+		revisions[i].Timestamp, _ = util.UlidTime(revisions[i].StoreID)
+	}
+	return revisions, nil
+}
+
+// touchJob updates the timestamp of a recorded job when an object is stored under it,
+// the caller holds s.mu
+func (s *Store) touchJob(jobID string) {
+	job, err := s.db.GetJob(jobID)
+	if err != nil {
+		return
+	}
+	job.Updated = time.Now().UTC()
+	s.db.SetJob(job)
+}
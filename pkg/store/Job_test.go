@@ -0,0 +1,59 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cfjello/go-store/pkg/types"
+)
+
+func TestJobs(t *testing.T) {
+	s := newTestStore(t)
+	before := time.Now().Add(-time.Second)
+
+	job, err := s.StartJob("")
+	if err != nil || job.JobID == "" || job.Status != JobRunning {
+		t.Fatalf("StartJob() failed: %+v (%v)", job, err)
+	}
+	for _, key := range []string{"person", "city", "person"} {
+		if _, err := s.Set(types.SetArgs{Key: key, Object: map[string]interface{}{"key": key}, JobID: job.JobID}); err != nil {
+			t.Fatalf("Set() failed: %v", err)
+		}
+	}
+	s.Set(types.SetArgs{Key: "person", Object: map[string]interface{}{"key": "other"}})
+
+	storeIDs, err := s.GetStoreIDs(job.JobID)
+	if err != nil || len(storeIDs) != 3 {
+		t.Fatalf("expected 3 storeIDs for the job, got %v (%v)", storeIDs, err)
+	}
+	objects, err := s.GetJobObjects(job.JobID)
+	if err != nil || len(objects) != 3 {
+		t.Fatalf("expected 3 objects for the job, got %v (%v)", objects, err)
+	}
+	if objects[1].Key != "city" || objects[1].StoreID != storeIDs[1] || objects[1].Timestamp.IsZero() {
+		t.Errorf("unexpected job object: %+v", objects[1])
+	}
+
+	updated, _ := s.GetJob(job.JobID)
+	if updated.Updated.Before(job.Updated) || updated.Finished != nil {
+		t.Errorf("expected Set() to update the job timestamp, got %+v", updated)
+	}
+	done, err := s.SetJobStatus(job.JobID, JobDone, map[string]string{"objects": "3"})
+	if err != nil || done.Finished == nil || done.Info["objects"] != "3" || !done.Started.Equal(job.Started) {
+		t.Errorf("unexpected finished job: %+v (%v)", done, err)
+	}
+
+	if _, err := s.SetJobStatus("nightly", JobRunning, nil); err != nil {
+		t.Fatalf("SetJobStatus() failed for an unknown job: %v", err)
+	}
+	jobs, err := s.ListJobs(before, time.Time{})
+	if err != nil || len(jobs) != 2 || jobs[0].JobID != job.JobID || jobs[1].JobID != "nightly" {
+		t.Errorf("expected both jobs in start order, got %+v (%v)", jobs, err)
+	}
+	if jobs, _ := s.ListJobs(before, job.Started); len(jobs) != 0 {
+		t.Errorf("expected the range to exclude its end, got %+v", jobs)
+	}
+	if _, err := s.GetJob("unknown"); err == nil {
+		t.Error("expected GetJob() to fail for an unknown job")
+	}
+}
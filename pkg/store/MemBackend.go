@@ -0,0 +1,929 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/cfjello/go-store/pkg/jobGraph"
+	"github.com/cfjello/go-store/pkg/types"
+	"github.com/cfjello/go-store/pkg/util"
+)
+
+// memRecord is a single stored revision
+type memRecord struct {
+	key       string
+	prevID    string
+	jobID     string
+	schemaKey string
+	objData   []byte
+}
+
+// MemBackend is a pure Go, in-process Backend built on maps.
+// Objects are kept as JSON, so reads return the same shapes as the SQLite backend.
+type MemBackend struct {
+	mu      sync.RWMutex
+	meta    map[string]types.MetaData
+	data    map[string]memRecord
+	byKey   map[string][]string
+	jobs    map[string]types.Job
+	graphs  map[string]jobGraph.Graph
+	indexes map[string]types.Index
+	search  map[string]types.SearchIndex
+	log     []types.Event
+}
+
+var _ Backend = (*MemBackend)(nil)
+
+// NewMemBackend creates an empty in-process backend
+func NewMemBackend() *MemBackend {
+	return &MemBackend{
+		meta:    make(map[string]types.MetaData),
+		data:    make(map[string]memRecord),
+		byKey:   make(map[string][]string),
+		jobs:    make(map[string]types.Job),
+		graphs:  make(map[string]jobGraph.Graph),
+		indexes: make(map[string]types.Index),
+		search:  make(map[string]types.SearchIndex),
+	}
+}
+
+// SetMeta creates or replaces the metadata of a key
+func (m *MemBackend) SetMeta(key string, meta types.MetaData) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if meta.SchemaKey == "" {
+		meta.SchemaKey = key
+	}
+	m.meta[key] = meta
+	return true
+}
+
+// GetMeta gets the metadata of a key, or else of the first key registered under schemaKey
+func (m *MemBackend) GetMeta(key string, schemaKey string) (types.MetaData, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if meta, ok := m.meta[key]; ok {
+		return meta, nil
+	}
+	if schemaKey == "" {
+		schemaKey = key
+	}
+	found := ""
+	for other, meta := range m.meta {
+		if meta.SchemaKey == schemaKey && (found == "" || other < found) {
+			found = other
+		}
+	}
+	if found == "" {
+		return types.MetaData{}, ErrNotFound
+	}
+	return m.meta[found], nil
+}
+
+// SetData stores a new revision of an object and logs event with it
+func (m *MemBackend) SetData(key string, value types.SetArgs, event *types.Event) bool {
+	objJSON, err := json.Marshal(value.Object)
+	if err != nil {
+		log.Printf("Failed to marshal object data for key: %s, error: %v", key, err)
+		return false
+	}
+
+	storeID := value.StoreID
+	if storeID == "" {
+		storeID = util.Ulid()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.data[storeID]; exists {
+		return false
+	}
+	if event != nil && !m.canAppend(*event) {
+		return false
+	}
+	schemaKey := value.SchemaKey
+	if schemaKey == "" {
+		schemaKey = key
+	}
+	var prevID string
+	if ids := m.byKey[key]; len(ids) > 0 {
+		prevID = ids[len(ids)-1]
+	}
+	m.data[storeID] = memRecord{
+		key:       key,
+		prevID:    prevID,
+		jobID:     value.JobID,
+		schemaKey: schemaKey,
+		objData:   objJSON,
+	}
+	m.byKey[key] = append(m.byKey[key], storeID)
+	if event != nil {
+		m.log = append(m.log, *event)
+	}
+	return true
+}
+
+// GetData gets the object stored under a storeID
+func (m *MemBackend) GetData(key string, storeID string) (any, error) {
+	m.mu.RLock()
+	rec, ok := m.data[storeID]
+	m.mu.RUnlock()
+	if !ok || rec.key != key {
+		return nil, ErrNotFound
+	}
+	var obj any
+	if err := json.Unmarshal(rec.objData, &obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// GetCurrStoreID gets the storeID of the latest revision of a key
+func (m *MemBackend) GetCurrStoreID(key string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	ids := m.byKey[key]
+	if len(ids) == 0 {
+		return "", ErrNotFound
+	}
+	return ids[len(ids)-1], nil
+}
+
+// GetRevisions gets the revisions of a key in storeID order, starting after opts.Cursor
+func (m *MemBackend) GetRevisions(key string, opts types.HistoryOpts) ([]types.Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ids := m.byKey[key]
+	revisions := []types.Revision{}
+	for i := range ids {
+		storeID := ids[i]
+		if opts.Desc {
+			storeID = ids[len(ids)-1-i]
+		}
+		if opts.Cursor != "" && (!opts.Desc && storeID <= opts.Cursor || opts.Desc && storeID >= opts.Cursor) {
+			continue
+		}
+		if opts.Limit > 0 && len(revisions) == opts.Limit {
+			break
+		}
+		rec := m.data[storeID]
+		rev := types.Revision{StoreID: storeID, PrevStoreID: rec.prevID, JobID: rec.jobID, SchemaKey: rec.schemaKey}
+		if err := json.Unmarshal(rec.objData, &rev.Object); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, nil
+}
+
+// SetJob creates or replaces the status record of a job
+func (m *MemBackend) SetJob(job types.Job) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[job.JobID] = job
+	return true
+}
+
+// GetJob gets the status record of a job
+func (m *MemBackend) GetJob(jobID string) (types.Job, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	job, ok := m.jobs[jobID]
+	if !ok {
+		return types.Job{}, ErrNotFound
+	}
+	return job, nil
+}
+
+// GetJobs gets the jobs started in [from, to), ordered by start time
+func (m *MemBackend) GetJobs(from time.Time, to time.Time) ([]types.Job, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	jobs := []types.Job{}
+	for _, job := range m.jobs {
+		if !job.Started.Before(from) && job.Started.Before(to) {
+			jobs = append(jobs, job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Started.Before(jobs[j].Started) })
+	return jobs, nil
+}
+
+// GetJobStoreIDs gets the storeIDs of the revisions stored under a jobID, in storeID order
+func (m *MemBackend) GetJobStoreIDs(jobID string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	storeIDs := []string{}
+	for storeID, rec := range m.data {
+		if rec.jobID == jobID {
+			storeIDs = append(storeIDs, storeID)
+		}
+	}
+	sort.Strings(storeIDs)
+	return storeIDs, nil
+}
+
+// GetJobRevisions gets the revisions stored under a jobID, in storeID order
+func (m *MemBackend) GetJobRevisions(jobID string) ([]types.Revision, error) {
+	storeIDs, _ := m.GetJobStoreIDs(jobID)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	revisions := make([]types.Revision, 0, len(storeIDs))
+	for _, storeID := range storeIDs {
+		rec, ok := m.data[storeID]
+		if !ok {
+			continue
+		}
+		rev := types.Revision{Key: rec.key, StoreID: storeID, PrevStoreID: rec.prevID, JobID: rec.jobID, SchemaKey: rec.schemaKey}
+		if err := json.Unmarshal(rec.objData, &rev.Object); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, nil
+}
+
+// SetGraph creates or replaces a job graph
+func (m *MemBackend) SetGraph(graph jobGraph.Graph) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.graphs[graph.GraphID] = graph
+	return true
+}
+
+// GetGraph gets a job graph
+func (m *MemBackend) GetGraph(graphID string) (jobGraph.Graph, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	graph, ok := m.graphs[graphID]
+	if !ok {
+		return jobGraph.Graph{}, ErrNotFound
+	}
+	return graph, nil
+}
+
+// GetGraphs gets every job graph, ordered by graphID
+func (m *MemBackend) GetGraphs() ([]jobGraph.Graph, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	graphs := make([]jobGraph.Graph, 0, len(m.graphs))
+	for _, graph := range m.graphs {
+		graphs = append(graphs, graph)
+	}
+	sort.Slice(graphs, func(i, j int) bool { return graphs[i].GraphID < graphs[j].GraphID })
+	return graphs, nil
+}
+
+// DeleteGraph removes a job graph
+func (m *MemBackend) DeleteGraph(graphID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, found := m.graphs[graphID]
+	delete(m.graphs, graphID)
+	return found
+}
+
+// SetIndex declares a JSON path index, lookups scan the revisions of its schema key
+func (m *MemBackend) SetIndex(index types.Index) bool {
+	if !util.IsJSONPath(index.Path) || index.Name == "" {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.indexes[index.Name] = index
+	return true
+}
+
+// GetIndexes gets the declared indexes, ordered by schemaKey and path
+func (m *MemBackend) GetIndexes() ([]types.Index, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	indexes := make([]types.Index, 0, len(m.indexes))
+	for _, index := range m.indexes {
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool {
+		if indexes[i].SchemaKey != indexes[j].SchemaKey {
+			return indexes[i].SchemaKey < indexes[j].SchemaKey
+		}
+		return indexes[i].Path < indexes[j].Path
+	})
+	return indexes, nil
+}
+
+// DeleteIndex removes a declared index
+func (m *MemBackend) DeleteIndex(name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.indexes[name]; !ok {
+		return false
+	}
+	delete(m.indexes, name)
+	return true
+}
+
+// LookupIndex gets the revisions whose value at lookup.Path matches, in value and storeID order.
+// Values compare like they do in SQLite: nulls first, then numbers and booleans, then strings.
+func (m *MemBackend) LookupIndex(lookup types.IndexLookup) ([]types.Revision, error) {
+	if !util.IsJSONPath(lookup.Path) {
+		return nil, errors.New("invalid JSON path " + lookup.Path)
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	type match struct {
+		value any
+		rev   types.Revision
+	}
+	var matches []match
+	for storeID, rec := range m.data {
+		if rec.schemaKey != lookup.SchemaKey {
+			continue
+		}
+		if lookup.Latest {
+			ids := m.byKey[rec.key]
+			if ids[len(ids)-1] != storeID || m.meta[rec.key].Oper == "del" {
+				continue
+			}
+		}
+		var obj any
+		if err := json.Unmarshal(rec.objData, &obj); err != nil {
+			return nil, err
+		}
+		value, found := util.JSONPathValue(obj, lookup.Path)
+		if !found || value == nil {
+			// Like SQL, a missing or null value matches no bound
+			if lookup.Equal != nil || lookup.From != nil || lookup.To != nil {
+				continue
+			}
+		}
+		if lookup.Equal != nil && compareJSON(value, lookup.Equal) != 0 ||
+			lookup.From != nil && compareJSON(value, lookup.From) < 0 ||
+			lookup.To != nil && compareJSON(value, lookup.To) >= 0 {
+			continue
+		}
+		rev := types.Revision{Key: rec.key, StoreID: storeID, PrevStoreID: rec.prevID, JobID: rec.jobID, SchemaKey: rec.schemaKey, Object: obj}
+		matches = append(matches, match{value: value, rev: rev})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if c := compareJSON(matches[i].value, matches[j].value); c != 0 {
+			return c < 0
+		}
+		return matches[i].rev.StoreID < matches[j].rev.StoreID
+	})
+	if lookup.Limit > 0 && len(matches) > lookup.Limit {
+		matches = matches[:lookup.Limit]
+	}
+	revisions := make([]types.Revision, len(matches))
+	for i := range matches {
+		revisions[i] = matches[i].rev
+	}
+	return revisions, nil
+}
+
+// Query gets the latest revisions of the live keys that match query, in query.Sort order
+// and starting after the sort values in after. It scans every key, like SQLite does
+// for the paths that are not indexed.
+func (m *MemBackend) Query(query types.Query, after []any) ([]types.Revision, error) {
+	if err := validateQuery(query); err != nil {
+		return nil, err
+	}
+	sortFields := query.Sort
+	if len(sortFields) == 0 || sortFields[len(sortFields)-1].Path != "storeId" {
+		return nil, errors.New("the sort of a query must end with storeId")
+	}
+	if after != nil && len(after) != len(sortFields) {
+		return nil, errors.New("the sort values to start after do not match the sort")
+	}
+	var glob *regexp.Regexp
+	if query.KeyGlob != "" {
+		var err error
+		if glob, err = globPattern(query.KeyGlob); err != nil {
+			return nil, err
+		}
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	type match struct {
+		values []any
+		rev    types.Revision
+	}
+	var matches []match
+	for key, ids := range m.byKey {
+		if len(ids) == 0 || m.meta[key].Oper == "del" ||
+			!strings.HasPrefix(key, query.KeyPrefix) || glob != nil && !glob.MatchString(key) {
+			continue
+		}
+		storeID := ids[len(ids)-1]
+		rec := m.data[storeID]
+		if query.SchemaKey != "" && rec.schemaKey != query.SchemaKey {
+			continue
+		}
+		var obj any
+		if err := json.Unmarshal(rec.objData, &obj); err != nil {
+			return nil, err
+		}
+		matched := true
+		for _, pred := range query.Where {
+			matched = matched && matchPredicate(obj, pred)
+		}
+		if !matched {
+			continue
+		}
+		values := make([]any, len(sortFields))
+		for i, field := range sortFields {
+			if field.Path == "storeId" {
+				values[i] = storeID
+			} else {
+				values[i], _ = util.JSONPathValue(obj, field.Path)
+			}
+		}
+		rev := types.Revision{Key: key, StoreID: storeID, PrevStoreID: rec.prevID, JobID: rec.jobID, SchemaKey: rec.schemaKey, Object: obj}
+		matches = append(matches, match{values: values, rev: rev})
+	}
+
+	compare := func(a []any, b []any) int {
+		for i, field := range sortFields {
+			c := compareJSON(a[i], b[i])
+			if field.Desc {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return compare(matches[i].values, matches[j].values) < 0
+	})
+	revisions := []types.Revision{}
+	for _, match := range matches {
+		if after != nil && compare(match.values, after) <= 0 {
+			continue
+		}
+		if query.Limit > 0 && len(revisions) == query.Limit {
+			break
+		}
+		revisions = append(revisions, match.rev)
+	}
+	return revisions, nil
+}
+
+// validateQuery checks the paths, operators and values of a query like the SQLite backend does
+func validateQuery(query types.Query) error {
+	invalid := func(message string, info map[string]string) error {
+		return &types.ExtError{Name: "ValidationError", Message: message, Info: info}
+	}
+	for _, pred := range query.Where {
+		if !util.IsJSONPath(pred.Path) {
+			return invalid(fmt.Sprintf("invalid JSON path %q, expected member names and array indexes like $.address.city", pred.Path),
+				map[string]string{"path": pred.Path})
+		}
+		switch pred.Op {
+		case types.OpEqual, types.OpNotEqual, types.OpLess, types.OpLessEqual, types.OpGreater, types.OpGreaterEqual, types.OpContains:
+			if !types.IsScalar(pred.Value) {
+				return invalid(fmt.Sprintf("%s %s needs a string, number or boolean value", pred.Path, pred.Op),
+					map[string]string{"path": pred.Path, "op": pred.Op})
+			}
+		case types.OpIn:
+			list, ok := pred.Value.([]any)
+			for _, value := range list {
+				ok = ok && types.IsScalar(value)
+			}
+			if !ok {
+				return invalid(fmt.Sprintf("%s in needs a list of strings, numbers or booleans", pred.Path),
+					map[string]string{"path": pred.Path, "op": pred.Op})
+			}
+		case types.OpExists:
+			if _, ok := pred.Value.(bool); pred.Value != nil && !ok {
+				return invalid(fmt.Sprintf("%s exists needs a boolean value or none", pred.Path),
+					map[string]string{"path": pred.Path, "op": pred.Op})
+			}
+		default:
+			return invalid(fmt.Sprintf("unknown operator %q, expected one of =, !=, <, <=, >, >=, in, exists, contains", pred.Op),
+				map[string]string{"path": pred.Path, "op": pred.Op})
+		}
+	}
+	for _, field := range query.Sort {
+		if field.Path != "storeId" && !util.IsJSONPath(field.Path) {
+			return invalid(fmt.Sprintf("invalid sort path %q, expected storeId or a JSON path", field.Path),
+				map[string]string{"path": field.Path})
+		}
+	}
+	return nil
+}
+
+// matchPredicate evaluates a predicate, checked by validateQuery, the way the SQL of
+// DBService.Query does
+func matchPredicate(obj any, pred types.Predicate) bool {
+	value, found := util.JSONPathValue(obj, pred.Path)
+	switch pred.Op {
+	case types.OpExists:
+		return found == (pred.Value != false)
+	case types.OpIn:
+		for _, item := range pred.Value.([]any) {
+			if value != nil && compareJSON(value, item) == 0 {
+				return true
+			}
+		}
+		return false
+	case types.OpContains:
+		switch v := value.(type) {
+		case []any:
+			for _, item := range v {
+				if compareJSON(item, pred.Value) == 0 {
+					return true
+				}
+			}
+		case string:
+			needle, ok := pred.Value.(string)
+			return ok && strings.Contains(v, needle)
+		}
+		return false
+	}
+	if value == nil {
+		// Like SQL, a missing or null value compares to nothing
+		return false
+	}
+	c := compareJSON(value, pred.Value)
+	switch pred.Op {
+	case types.OpEqual:
+		return c == 0
+	case types.OpNotEqual:
+		return c != 0
+	case types.OpLess:
+		return c < 0
+	case types.OpLessEqual:
+		return c <= 0
+	case types.OpGreater:
+		return c > 0
+	case types.OpGreaterEqual:
+		return c >= 0
+	}
+	return false
+}
+
+// globPattern translates a SQLite GLOB pattern into a regular expression
+func globPattern(glob string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^(?s:")
+	chars := []rune(glob)
+	for i := 0; i < len(chars); i++ {
+		switch chars[i] {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '[':
+			// a ] right after the [ or [^ is part of the set
+			start := i + 1
+			if start < len(chars) && chars[start] == '^' {
+				start++
+			}
+			end := start + 1
+			for end < len(chars) && chars[end] != ']' {
+				end++
+			}
+			if end >= len(chars) {
+				b.WriteString(regexp.QuoteMeta(string(chars[i:])))
+				i = len(chars)
+				continue
+			}
+			b.WriteString("[")
+			if start > i+1 {
+				b.WriteString("^")
+			}
+			b.WriteString(strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`).Replace(string(chars[start:end])))
+			b.WriteString("]")
+			i = end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(chars[i])))
+		}
+	}
+	b.WriteString(")$")
+	return regexp.Compile(b.String())
+}
+
+// compareJSON orders JSON scalars the way SQLite orders the results of json_extract
+func compareJSON(a any, b any) int {
+	rankA, numA, textA := jsonSortKey(a)
+	rankB, numB, textB := jsonSortKey(b)
+	switch {
+	case rankA != rankB:
+		return rankA - rankB
+	case rankA == 1 && numA < numB, rankA == 2 && textA < textB:
+		return -1
+	case rankA == 1 && numA > numB, rankA == 2 && textA > textB:
+		return 1
+	}
+	return 0
+}
+
+// jsonSortKey returns the SQLite storage class rank of a value with its number or text
+func jsonSortKey(value any) (int, float64, string) {
+	switch v := value.(type) {
+	case nil:
+		return 0, 0, ""
+	case bool:
+		if v {
+			return 1, 1, ""
+		}
+		return 1, 0, ""
+	case float64:
+		return 1, v, ""
+	case float32:
+		return 1, float64(v), ""
+	case int:
+		return 1, float64(v), ""
+	case int64:
+		return 1, float64(v), ""
+	case json.Number:
+		f, _ := v.Float64()
+		return 1, f, ""
+	case string:
+		return 2, 0, v
+	}
+	// Objects and arrays are extracted as JSON text
+	text, _ := json.Marshal(value)
+	return 2, 0, string(text)
+}
+
+// SetSearchIndex declares the full-text index of a schema, searches scan the latest
+// revisions of its live keys
+func (m *MemBackend) SetSearchIndex(index types.SearchIndex) error {
+	if len(index.Paths) == 0 {
+		return errors.New("no paths to index for " + index.SchemaKey)
+	}
+	for _, path := range index.Paths {
+		if !util.IsJSONPath(path) {
+			return errors.New("invalid JSON path " + path)
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	index.Paths = append([]string(nil), index.Paths...)
+	m.search[index.SchemaKey] = index
+	return nil
+}
+
+// GetSearchIndexes gets the full-text indexes, ordered by schemaKey
+func (m *MemBackend) GetSearchIndexes() ([]types.SearchIndex, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	indexes := make([]types.SearchIndex, 0, len(m.search))
+	for _, index := range m.search {
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i].SchemaKey < indexes[j].SchemaKey })
+	return indexes, nil
+}
+
+// DeleteSearchIndex removes the full-text index of a schema
+func (m *MemBackend) DeleteSearchIndex(schemaKey string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, found := m.search[schemaKey]
+	delete(m.search, schemaKey)
+	return found
+}
+
+// Search finds the live keys of query.SchemaKey whose indexed text holds every word of
+// query.Text. Words are runs of letters and digits compared without case, the rank is
+// minus the number of matching words and the snippets are the whole text of a path.
+func (m *MemBackend) Search(query types.SearchQuery) ([]types.SearchHit, error) {
+	type term struct {
+		word   string
+		prefix bool
+	}
+	var terms []term
+	for _, word := range strings.Fields(strings.ToLower(query.Text)) {
+		prefix := strings.HasSuffix(word, "*")
+		for _, w := range searchWords(strings.TrimRight(word, "*")) {
+			terms = append(terms, term{word: w.word})
+		}
+		if prefix && len(terms) > 0 {
+			terms[len(terms)-1].prefix = true
+		}
+	}
+	if len(terms) == 0 {
+		return nil, errors.New("nothing to search for in " + query.Text)
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	index, ok := m.search[query.SchemaKey]
+	if !ok {
+		return nil, fmt.Errorf("%w for full-text search on %s", ErrNotIndexed, query.SchemaKey)
+	}
+
+	hits := []types.SearchHit{}
+	for key, ids := range m.byKey {
+		if len(ids) == 0 || m.meta[key].Oper == "del" {
+			continue
+		}
+		storeID := ids[len(ids)-1]
+		rec := m.data[storeID]
+		if rec.schemaKey != query.SchemaKey {
+			continue
+		}
+		var obj any
+		if err := json.Unmarshal(rec.objData, &obj); err != nil {
+			return nil, err
+		}
+		found := make([]bool, len(terms))
+		matches := 0
+		snippets := make(map[string]string)
+		for _, path := range index.Paths {
+			value, _ := util.JSONPathValue(obj, path)
+			text := strings.Join(jsonStrings(value), " ")
+			var marked []searchWord
+			for _, w := range searchWords(text) {
+				for i, t := range terms {
+					if w.word == t.word || t.prefix && strings.HasPrefix(w.word, t.word) {
+						found[i] = true
+						marked = append(marked, w)
+						matches++
+						break
+					}
+				}
+			}
+			if len(marked) > 0 {
+				snippets[path] = markWords(text, marked)
+			}
+		}
+		all := true
+		for _, f := range found {
+			all = all && f
+		}
+		if all {
+			hits = append(hits, types.SearchHit{Key: key, StoreID: storeID, Rank: -float64(matches), Snippets: snippets})
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Rank != hits[j].Rank {
+			return hits[i].Rank < hits[j].Rank
+		}
+		return hits[i].Key < hits[j].Key
+	})
+	if query.Limit > 0 && len(hits) > query.Limit {
+		hits = hits[:query.Limit]
+	}
+	return hits, nil
+}
+
+// searchWord is a word of a text, lower cased, with its position in the text
+type searchWord struct {
+	word       string
+	start, end int
+}
+
+// searchWords splits text into its runs of letters and digits
+func searchWords(text string) []searchWord {
+	var words []searchWord
+	start := -1
+	for i, r := range text + " " {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if inWord && start < 0 {
+			start = i
+		} else if !inWord && start >= 0 {
+			words = append(words, searchWord{word: strings.ToLower(text[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	return words
+}
+
+// jsonStrings gets a string, or the strings found anywhere inside an array or object
+func jsonStrings(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		var list []string
+		for _, item := range v {
+			list = append(list, jsonStrings(item)...)
+		}
+		return list
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		var list []string
+		for _, key := range keys {
+			list = append(list, jsonStrings(v[key])...)
+		}
+		return list
+	}
+	return nil
+}
+
+// markWords escapes text for HTML and puts the words between <mark> and </mark>
+func markWords(text string, words []searchWord) string {
+	var b strings.Builder
+	last := 0
+	for _, w := range words {
+		b.WriteString(html.EscapeString(text[last:w.start]))
+		b.WriteString("<mark>" + html.EscapeString(text[w.start:w.end]) + "</mark>")
+		last = w.end
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	return b.String()
+}
+
+// AppendEvent adds an event to the change log
+func (m *MemBackend) AppendEvent(event types.Event) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.canAppend(event) {
+		return false
+	}
+	m.log = append(m.log, event)
+	return true
+}
+
+// canAppend reports whether event comes after the last logged event, the caller holds m.mu
+func (m *MemBackend) canAppend(event types.Event) bool {
+	n := len(m.log)
+	return n == 0 || m.log[n-1].ID < event.ID
+}
+
+// GetEvents gets at most limit events logged after the event with ID after, in ID order
+func (m *MemBackend) GetEvents(after string, limit int) ([]types.Event, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	start := sort.Search(len(m.log), func(i int) bool { return m.log[i].ID > after })
+	end := len(m.log)
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+	return append([]types.Event{}, m.log[start:end]...), nil
+}
+
+// LastEventID gets the ID of the latest logged event, or an empty string
+func (m *MemBackend) LastEventID() (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(m.log) == 0 {
+		return "", nil
+	}
+	return m.log[len(m.log)-1].ID, nil
+}
+
+// PruneEvents removes the events logged before the event with ID before and counts them
+func (m *MemBackend) PruneEvents(before string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := sort.Search(len(m.log), func(i int) bool { return m.log[i].ID >= before })
+	m.log = append([]types.Event{}, m.log[n:]...)
+	return int64(n), nil
+}
+
+// Stats counts the keys, revisions and bytes stored
+func (m *MemBackend) Stats() (types.StoreStats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var stats types.StoreStats
+	for _, meta := range m.meta {
+		if meta.Oper == "del" {
+			stats.DeletedKeys++
+		} else {
+			stats.Keys++
+		}
+	}
+	stats.Revisions = int64(len(m.data))
+	for _, rec := range m.data {
+		stats.Bytes += int64(len(rec.objData))
+	}
+	return stats, nil
+}
+
+// Delete permanently removes a key, its metadata and all its revisions
+func (m *MemBackend) Delete(key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, found := m.meta[key]
+	delete(m.meta, key)
+	for _, storeID := range m.byKey[key] {
+		delete(m.data, storeID)
+	}
+	delete(m.byKey, key)
+	return found
+}
+
+// Close is a no-op for the in-process backend
+func (m *MemBackend) Close() error {
+	return nil
+}
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/cfjello/go-store/pkg/metrics"
+	"github.com/cfjello/go-store/pkg/types"
+	"github.com/cfjello/go-store/pkg/util"
+)
+
+// DefaultQueryLimit is the page size of a query without a limit, MaxQueryLimit the largest page size
+const (
+	DefaultQueryLimit = 100
+	MaxQueryLimit     = 1000
+)
+
+// queryCursor is the content of the opaque cursor of a query page: the sort values of
+// the last result, and a fingerprint of the query so a cursor is not used with another
+type queryCursor struct {
+	Query string `json:"q"`
+	After []any  `json:"a"`
+}
+
+// Query filters the latest revisions of the live keys by schemaKey, key prefix or glob
+// and predicates on JSON paths, and sorts them by JSON paths and storeID. A page holds
+// up to query.Limit results, pass its NextCursor as query.Cursor to get the next one.
+// Predicates and sorts on an indexed path of query.SchemaKey can use the index.
+func (s *Store) Query(query types.Query) (types.QueryPage, error) {
+	start := time.Now()
+	page, err := s.query(query)
+	metrics.ObserveStore("Query", start, err)
+	return page, err
+}
+
+// The backend checks the paths, operators and values of the query, an invalid one is a
+// ValidationError
+func (s *Store) query(query types.Query) (types.QueryPage, error) {
+	if query.Limit <= 0 {
+		query.Limit = DefaultQueryLimit
+	}
+	if query.Limit > MaxQueryLimit {
+		query.Limit = MaxQueryLimit
+	}
+	if len(query.Sort) == 0 || query.Sort[len(query.Sort)-1].Path != "storeId" {
+		query.Sort = append(query.Sort[:len(query.Sort):len(query.Sort)], types.SortField{Path: "storeId"})
+	}
+
+	fingerprint := queryFingerprint(query)
+	var after []any
+	if query.Cursor != "" {
+		cursor, err := decodeCursor(query.Cursor)
+		if err != nil || cursor.Query != fingerprint || len(cursor.After) != len(query.Sort) {
+			return types.QueryPage{}, &types.ExtError{
+				Name:    "ValidationError",
+				Message: "invalid cursor, a cursor only continues the query that returned it",
+				Info:    map[string]string{"cursor": query.Cursor},
+			}
+		}
+		after = cursor.After
+	}
+
+	// One more result than asked for tells whether there is a next page
+	limit := query.Limit
+	query.Limit++
+	revisions, err := s.db.Query(query, after)
+	if err != nil {
+		return types.QueryPage{}, fmt.Errorf("failed to query: %w", err)
+	}
+	page := types.QueryPage{Results: revisions}
+	if len(revisions) > limit {
+		page.Results = revisions[:limit]
+		last := page.Results[limit-1]
+		values := make([]any, len(query.Sort))
+		for i, field := range query.Sort {
+			if field.Path == "storeId" {
+				values[i] = last.StoreID
+			} else {
+				values[i], _ = util.JSONPathValue(last.Object, field.Path)
+			}
+		}
+		page.NextCursor = encodeCursor(queryCursor{Query: fingerprint, After: values})
+	}
+	for i := range page.Results {
+		page.Results[i].Timestamp, _ = util.UlidTime(page.Results[i].StoreID)
+	}
+	return page, nil
+}
+
+// queryFingerprint identifies the filters and sort of a query, whatever its page
+func queryFingerprint(query types.Query) string {
+	query.Limit, query.Cursor = 0, ""
+	text, _ := json.Marshal(query)
+	sum := sha256.Sum256(text)
+	return hex.EncodeToString(sum[:8])
+}
+
+func encodeCursor(cursor queryCursor) string {
+	text, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(text)
+}
+
+func decodeCursor(text string) (queryCursor, error) {
+	var cursor queryCursor
+	data, err := base64.RawURLEncoding.DecodeString(text)
+	if err != nil {
+		return cursor, err
+	}
+	err = json.Unmarshal(data, &cursor)
+	return cursor, err
+}
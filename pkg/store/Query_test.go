@@ -0,0 +1,114 @@
+package store
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cfjello/go-store/pkg/types"
+)
+
+func queryKeys(t *testing.T, s *Store, query types.Query) ([]string, string) {
+	t.Helper()
+	page, err := s.Query(query)
+	if err != nil {
+		t.Fatalf("Query() failed: %v", err)
+	}
+	keys := make([]string, len(page.Results))
+	for i, rev := range page.Results {
+		keys[i] = rev.Key
+	}
+	return keys, page.NextCursor
+}
+
+func TestQuery(t *testing.T) {
+	s := newTestStore(t)
+	setPeople(t, s)
+	s.Set(types.SetArgs{Key: "ada", Object: map[string]interface{}{"name": "Ada", "age": 37, "tags": []interface{}{"math", "poetry"}}})
+	s.Set(types.SetArgs{Key: "robot", Object: map[string]interface{}{"name": "Robot", "age": 1}})
+	s.UnRegister("grace")
+
+	tests := []struct {
+		name  string
+		query types.Query
+		want  []string
+	}{
+		{"schemaKey", types.Query{SchemaKey: "person", Sort: []types.SortField{{Path: "$.age"}}}, []string{"ada", "alan", "edsger"}},
+		{"prefix", types.Query{KeyPrefix: "a", Sort: []types.SortField{{Path: "$.name", Desc: true}}}, []string{"alan", "ada"}},
+		{"glob", types.Query{KeyGlob: "[^a]?b*", Sort: []types.SortField{{Path: "$.name"}}}, []string{"robot"}},
+		{"equal", types.Query{Where: []types.Predicate{{Path: "$.address.city", Op: "=", Value: "Nuenen"}}}, []string{"edsger"}},
+		{"not equal skips missing", types.Query{Where: []types.Predicate{{Path: "$.address.city", Op: "!=", Value: "Nuenen"}}}, []string{"alan"}},
+		{"range", types.Query{Where: []types.Predicate{{Path: "$.age", Op: ">", Value: 1}, {Path: "$.age", Op: "<", Value: 72}},
+			Sort: []types.SortField{{Path: "$.age"}}}, []string{"ada", "alan"}},
+		{"in", types.Query{Where: []types.Predicate{{Path: "$.name", Op: "in", Value: []interface{}{"Robot", "Alan", "Grace"}}},
+			Sort: []types.SortField{{Path: "$.name"}}}, []string{"alan", "robot"}},
+		{"exists", types.Query{SchemaKey: "person", Where: []types.Predicate{{Path: "$.address", Op: "exists"}},
+			Sort: []types.SortField{{Path: "$.name"}}}, []string{"alan", "edsger"}},
+		{"not exists", types.Query{SchemaKey: "person", Where: []types.Predicate{{Path: "$.address", Op: "exists", Value: false}}}, []string{"ada"}},
+		{"contains element", types.Query{Where: []types.Predicate{{Path: "$.tags", Op: "contains", Value: "poetry"}}}, []string{"ada"}},
+		{"contains substring", types.Query{Where: []types.Predicate{{Path: "$.name", Op: "contains", Value: "dsg"}}}, []string{"edsger"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, _ := queryKeys(t, s, tt.query)
+			if len(keys) != len(tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, keys)
+			}
+			for i := range keys {
+				if keys[i] != tt.want[i] {
+					t.Fatalf("expected %v, got %v", tt.want, keys)
+				}
+			}
+		})
+	}
+
+	var extErr *types.ExtError
+	for _, query := range []types.Query{
+		{Where: []types.Predicate{{Path: "$.name'; --", Op: "="}}},
+		{Where: []types.Predicate{{Path: "$.name", Op: "like", Value: "A%"}}},
+		{Where: []types.Predicate{{Path: "$.name", Op: "in", Value: "Ada"}}},
+		{Sort: []types.SortField{{Path: "name"}}},
+		{Cursor: "not a cursor"},
+	} {
+		if _, err := s.Query(query); !errors.As(err, &extErr) || extErr.Name != "ValidationError" {
+			t.Errorf("expected a ValidationError for %+v, got %v", query, err)
+		}
+	}
+}
+
+func TestQueryPages(t *testing.T) {
+	s := newTestStore(t)
+	setPeople(t, s)
+	s.Set(types.SetArgs{Key: "nobody", SchemaKey: "person", Object: map[string]interface{}{"name": "Nobody"}})
+
+	// Descending by age, a missing age sorts last
+	query := types.Query{SchemaKey: "person", Sort: []types.SortField{{Path: "$.age", Desc: true}}, Limit: 2}
+	var keys []string
+	for pages := 0; ; pages++ {
+		page, cursor := queryKeys(t, s, query)
+		keys = append(keys, page...)
+		if cursor == "" {
+			if pages != 2 {
+				t.Errorf("expected three pages, got %d", pages+1)
+			}
+			break
+		}
+		query.Cursor = cursor
+	}
+	want := []string{"grace", "edsger", "alan", "ada", "nobody"}
+	if len(keys) != len(want) {
+		t.Fatalf("expected %v, got %v", want, keys)
+	}
+	for i := range keys {
+		if keys[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, keys)
+		}
+	}
+
+	// A cursor only continues the query it came from
+	query.Cursor = ""
+	_, cursor := queryKeys(t, s, query)
+	var extErr *types.ExtError
+	if _, err := s.Query(types.Query{SchemaKey: "person", Cursor: cursor}); !errors.As(err, &extErr) {
+		t.Errorf("expected the cursor of another query to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package store
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/cfjello/go-store/pkg/dynReflect"
+	"github.com/cfjello/go-store/pkg/types"
+)
+
+const personSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"title": "Person",
+	"type": "object",
+	"properties": {
+		"name": {"type": "string"},
+		"age": {"type": "integer"},
+		"email": {"type": ["string", "null"]}
+	},
+	"required": ["name", "age"]
+}`
+
+func TestRegisterWithJSONSchema(t *testing.T) {
+	s := newTestStore(t)
+
+	meta, err := s.Register(types.RegisterArgs{Key: "person", Schema: personSchema, Check: true})
+	if err != nil {
+		t.Fatalf("Register() failed: %v", err)
+	}
+	if meta.Oper != "reg" || meta.TypeInfo.Kind != "struct" || len(meta.TypeInfo.Fields) != 3 {
+		t.Errorf("unexpected meta data: %+v", meta)
+	}
+	if !s.IsRegistered("person") {
+		t.Error("expected the key to be registered")
+	}
+	if _, err := s.Get("", "person"); err == nil {
+		t.Error("expected no object to be stored without init")
+	}
+
+	if _, err := s.Set(types.SetArgs{Key: "person", Object: map[string]interface{}{"name": "Ada", "age": 36}}); err != nil {
+		t.Errorf("expected an object matching the schema to be stored, got %v", err)
+	}
+	if _, err := s.Set(types.SetArgs{Key: "person", Object: map[string]interface{}{"name": "Ada"}}); err == nil {
+		t.Error("expected an object without the required age to be refused")
+	}
+}
+
+func TestRegisterWithInit(t *testing.T) {
+	s := newTestStore(t)
+	type animal struct {
+		Name    string
+		Species string
+	}
+
+	meta, err := s.Register(types.RegisterArgs{Key: "cat", Object: animal{Name: "Tom", Species: "cat"}, Init: true, Check: true})
+	if err != nil {
+		t.Fatalf("Register() failed: %v", err)
+	}
+	if meta.Oper != "reg&set" || meta.TypeInfo.Type != "store.animal" {
+		t.Errorf("unexpected meta data: %+v", meta)
+	}
+	stored, _ := s.GetMetaData("cat", "")
+	if stored.Oper != "reg&set" {
+		t.Errorf("expected the stored meta data to report reg&set, got %q", stored.Oper)
+	}
+	obj, err := s.Get("", "cat")
+	if err != nil || obj.(map[string]interface{})["Name"] != "Tom" {
+		t.Errorf("expected the init object to be stored, got %v (%v)", obj, err)
+	}
+
+	if _, err := s.Register(types.RegisterArgs{Key: "dog", Init: true}); err == nil {
+		t.Error("expected init without an object to fail")
+	}
+	if _, err := s.Register(types.RegisterArgs{Key: "dog", Schema: animal{}, Object: map[string]interface{}{"name": 1}, Init: true, Check: true}); err == nil {
+		t.Error("expected an init object that does not match the schema to be refused")
+	}
+}
+
+func TestRegisterSchemaSources(t *testing.T) {
+	s := newTestStore(t)
+	info := dynReflect.TypeInfo{Type: "string", Kind: "string"}
+
+	meta, err := s.Register(types.RegisterArgs{Key: "label", Schema: info})
+	if err != nil || meta.TypeInfo.Kind != "string" {
+		t.Errorf("expected a TypeInfo schema to be used as is, got %+v (%v)", meta.TypeInfo, err)
+	}
+
+	meta, err = s.Register(types.RegisterArgs{Key: "sample", Schema: map[string]interface{}{"name": "Ada", "type": "person"}})
+	if err != nil || meta.TypeInfo.Kind != "struct" || meta.TypeInfo.Fields["type"].Kind != "string" {
+		t.Errorf("expected a sample object schema, got %+v (%v)", meta.TypeInfo, err)
+	}
+
+	if _, err := s.Register(types.RegisterArgs{Key: "person", Schema: personSchema}); err != nil {
+		t.Fatalf("Register() failed: %v", err)
+	}
+	meta, err = s.Register(types.RegisterArgs{Key: "employee", SchemaKey: "person"})
+	if err != nil || meta.TypeInfo.Type != "Person" {
+		t.Errorf("expected the schema of the schema key to be shared, got %+v (%v)", meta.TypeInfo, err)
+	}
+
+	if _, err := s.Register(types.RegisterArgs{Key: "broken", Schema: `{"type": "object", "properties": {"name": "string"}}`}); err == nil {
+		t.Error("expected an invalid JSON Schema to be refused")
+	}
+	if _, err := s.Register(types.RegisterArgs{Key: ""}); err == nil {
+		t.Error("expected an empty key to be refused")
+	}
+}
+
+func TestSetAfterRegisterWithoutSchema(t *testing.T) {
+	s := newTestStore(t)
+	if _, err := s.Register(types.RegisterArgs{Key: "person"}); err != nil {
+		t.Fatalf("Register() failed: %v", err)
+	}
+	if _, err := s.Set(types.SetArgs{Key: "person", Object: map[string]interface{}{"name": "Ada"}}); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	meta, _ := s.GetMetaData("person", "")
+	if meta.Oper != "reg" || meta.TypeInfo.Kind != "struct" || meta.TypeInfo.Fields["name"].Kind != "string" {
+		t.Errorf("expected the first object to define the schema, got %+v", meta)
+	}
+}
+
+func TestRegisterFromJSONAcceptsAnyNumber(t *testing.T) {
+	s := newTestStore(t)
+	var sample map[string]interface{}
+	if err := json.Unmarshal([]byte(`{"price": 10}`), &sample); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Register(types.RegisterArgs{Key: "product", Object: sample, Init: true, Check: true}); err != nil {
+		t.Fatalf("Register() failed: %v", err)
+	}
+	if _, err := s.Set(types.SetArgs{Key: "product", Object: map[string]interface{}{"price": 10.5}}); err != nil {
+		t.Errorf("expected a JSON number with a fraction to match, got %v", err)
+	}
+	if _, err := s.Set(types.SetArgs{Key: "product", Object: map[string]interface{}{"price": "10"}}); err == nil {
+		t.Error("expected a string price to be refused")
+	}
+}
+
+func TestGetJSONSchema(t *testing.T) {
+	s := newTestStore(t)
+	if _, err := s.Register(types.RegisterArgs{Key: "person", Schema: personSchema}); err != nil {
+		t.Fatalf("Register() failed: %v", err)
+	}
+	schema, err := s.GetJSONSchema("person")
+	if err != nil {
+		t.Fatalf("GetJSONSchema() failed: %v", err)
+	}
+	if schema["$id"] != "person" || schema["title"] != "Person" || len(schema["properties"].(map[string]interface{})) != 3 {
+		t.Errorf("unexpected schema: %v", schema)
+	}
+	if _, err := s.GetJSONSchema("nobody"); err == nil {
+		t.Error("expected GetJSONSchema() of an unknown key to fail")
+	}
+}
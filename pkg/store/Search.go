@@ -0,0 +1,96 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cfjello/go-store/pkg/metrics"
+	"github.com/cfjello/go-store/pkg/types"
+	"github.com/cfjello/go-store/pkg/util"
+)
+
+// ErrSearchUnavailable is returned by the search methods when the backend has no
+// full-text search, the SQLite backend needs to be built with -tags sqlite_fts5
+var ErrSearchUnavailable = errors.New("full-text search is not available, build with -tags sqlite_fts5")
+
+// DefaultSearchLimit is the number of hits of a search without a limit
+const DefaultSearchLimit = 20
+
+// CreateSearchIndex declares the full-text index of the objects stored under schemaKey,
+// it indexes the text at each of paths, such as $."rdfs:label" and $."rdfs:comment".
+// The text of a path that holds an array or object is all the strings inside it.
+// Declaring the index again with other paths rebuilds it.
+func (s *Store) CreateSearchIndex(schemaKey string, paths ...string) (types.SearchIndex, error) {
+	if schemaKey == "" {
+		return types.SearchIndex{}, errors.New("no \"schemaKey\" provided for CreateSearchIndex()")
+	}
+	if len(paths) == 0 {
+		return types.SearchIndex{}, &types.ExtError{
+			Name:    "ValidationError",
+			Message: "a search index needs at least one JSON path",
+			Info:    map[string]string{"schemaKey": schemaKey},
+		}
+	}
+	index := types.SearchIndex{SchemaKey: schemaKey}
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		if !util.IsJSONPath(path) {
+			return types.SearchIndex{}, &types.ExtError{
+				Name:    "ValidationError",
+				Message: fmt.Sprintf("invalid JSON path %q, expected member names and array indexes like $.address.city", path),
+				Info:    map[string]string{"path": path},
+			}
+		}
+		if !seen[path] {
+			seen[path] = true
+			index.Paths = append(index.Paths, path)
+		}
+	}
+	if err := s.db.SetSearchIndex(index); err != nil {
+		return types.SearchIndex{}, fmt.Errorf("failed to create search index on %s: %w", schemaKey, err)
+	}
+	return index, nil
+}
+
+// DropSearchIndex removes the full-text index of schemaKey
+func (s *Store) DropSearchIndex(schemaKey string) bool {
+	return s.db.DeleteSearchIndex(schemaKey)
+}
+
+// ListSearchIndexes lists the full-text indexes, ordered by schemaKey
+func (s *Store) ListSearchIndexes() ([]types.SearchIndex, error) {
+	return s.db.GetSearchIndexes()
+}
+
+// Search finds the live keys of query.SchemaKey whose indexed text holds every word of
+// query.Text, a word ending in "*" matches as a prefix. The hits are ranked best first
+// and carry a snippet of each matching path, HTML escaped with the matches marked.
+func (s *Store) Search(query types.SearchQuery) ([]types.SearchHit, error) {
+	start := time.Now()
+	hits, err := s.search(query)
+	metrics.ObserveStore("Search", start, err)
+	return hits, err
+}
+
+func (s *Store) search(query types.SearchQuery) ([]types.SearchHit, error) {
+	if strings.Trim(query.Text, " \t\r\n*") == "" {
+		return nil, &types.ExtError{
+			Name:    "ValidationError",
+			Message: "nothing to search for, the text has no words",
+			Info:    map[string]string{"text": query.Text},
+		}
+	}
+	if query.Limit <= 0 {
+		query.Limit = DefaultSearchLimit
+	}
+	if query.Limit > MaxQueryLimit {
+		query.Limit = MaxQueryLimit
+	}
+	hits, err := s.db.Search(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search %s: %w", query.SchemaKey, err)
+	}
+	return hits, nil
+}
@@ -0,0 +1,91 @@
+package store
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cfjello/go-store/pkg/types"
+)
+
+func setTerms(t *testing.T, s *Store) {
+	t.Helper()
+	terms := []struct {
+		key     string
+		label   interface{}
+		comment string
+	}{
+		{"schema:Restaurant", "Restaurant", "A restaurant, a food establishment."},
+		{"schema:FoodEstablishment", map[string]interface{}{"@language": "en", "@value": "FoodEstablishment"}, "A food-related business."},
+		{"schema:Bakery", "Bakery", "A bakery <b>bakes</b> bread."},
+	}
+	for _, term := range terms {
+		obj := map[string]interface{}{"@id": term.key, "rdfs:label": term.label, "rdfs:comment": term.comment}
+		if _, err := s.Set(types.SetArgs{Key: term.key, SchemaKey: "schema.org", Object: obj}); err != nil {
+			t.Fatalf("Set() failed: %v", err)
+		}
+	}
+}
+
+func searchKeys(t *testing.T, s *Store, text string) []string {
+	t.Helper()
+	hits, err := s.Search(types.SearchQuery{SchemaKey: "schema.org", Text: text})
+	if err != nil {
+		t.Fatalf("Search() failed: %v", err)
+	}
+	keys := make([]string, len(hits))
+	for i, hit := range hits {
+		keys[i] = hit.Key
+	}
+	return keys
+}
+
+func TestSearch(t *testing.T) {
+	s := newTestStore(t)
+	setTerms(t, s)
+
+	if _, err := s.Search(types.SearchQuery{SchemaKey: "schema.org", Text: "food"}); !errors.Is(err, ErrNotIndexed) {
+		t.Fatalf("expected ErrNotIndexed, got %v", err)
+	}
+	var extErr *types.ExtError
+	if _, err := s.CreateSearchIndex("schema.org", "$.rdfs:label"); !errors.As(err, &extErr) || extErr.Name != "ValidationError" {
+		t.Errorf("expected an unquoted rdfs:label to be refused, got %v", err)
+	}
+	if _, err := s.CreateSearchIndex("schema.org", `$."rdfs:label"`, `$."rdfs:comment"`); err != nil {
+		t.Fatalf("CreateSearchIndex() failed: %v", err)
+	}
+	if indexes, _ := s.ListSearchIndexes(); len(indexes) != 1 || len(indexes[0].Paths) != 2 {
+		t.Errorf("unexpected search indexes: %+v", indexes)
+	}
+
+	hits, err := s.Search(types.SearchQuery{SchemaKey: "schema.org", Text: "food"})
+	if err != nil || len(hits) != 2 || hits[1].Key != "schema:Restaurant" || hits[1].StoreID == "" {
+		t.Fatalf("expected two hits, got %+v (%v)", hits, err)
+	}
+	if snippet := hits[1].Snippets[`$."rdfs:comment"`]; snippet != "A restaurant, a <mark>food</mark> establishment." || len(hits[1].Snippets) != 1 {
+		t.Errorf("unexpected snippets: %v", hits[1].Snippets)
+	}
+	if keys := searchKeys(t, s, "foodestab*"); len(keys) != 1 || keys[0] != "schema:FoodEstablishment" {
+		t.Errorf("expected a prefix to match the label inside an object, got %v", keys)
+	}
+	hits, _ = s.Search(types.SearchQuery{SchemaKey: "schema.org", Text: "bakes"})
+	if len(hits) != 1 || hits[0].Snippets[`$."rdfs:comment"`] != "A bakery &lt;b&gt;<mark>bakes</mark>&lt;/b&gt; bread." {
+		t.Errorf("expected an escaped snippet, got %+v", hits)
+	}
+	if keys := searchKeys(t, s, "food bread"); len(keys) != 0 {
+		t.Errorf("expected every word to be needed, got %v", keys)
+	}
+	if _, err := s.Search(types.SearchQuery{SchemaKey: "schema.org", Text: " * "}); !errors.As(err, &extErr) {
+		t.Errorf("expected a ValidationError for a search without words, got %v", err)
+	}
+
+	// The index follows new revisions and soft deletes
+	s.Set(types.SetArgs{Key: "schema:Bakery", Object: map[string]interface{}{"rdfs:label": "Bakery", "rdfs:comment": "Sells food."}})
+	s.UnRegister("schema:Restaurant")
+	if keys := searchKeys(t, s, "food"); len(keys) != 2 || keys[0] != "schema:Bakery" && keys[1] != "schema:Bakery" {
+		t.Errorf("expected the bakery and no restaurant, got %v", keys)
+	}
+
+	if !s.DropSearchIndex("schema.org") || s.DropSearchIndex("schema.org") {
+		t.Error("expected DropSearchIndex() to remove the index once")
+	}
+}
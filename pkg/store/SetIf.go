@@ -0,0 +1,56 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cfjello/go-store/pkg/metrics"
+	"github.com/cfjello/go-store/pkg/types"
+)
+
+// AnyRevision can be passed to SetIf to require that the key has at least one revision
+const AnyRevision = "*"
+
+// ErrConflict is the cause of the error returned by SetIf when the latest revision
+// of a key is not the expected one
+var ErrConflict = errors.New("revision conflict")
+
+// SetIf stores obj as a new revision of key, but only if the latest revision of the key
+// is expectedStoreID. An empty expectedStoreID requires that the key has no revisions,
+// or was soft deleted, and AnyRevision requires that it has one. Otherwise SetIf fails
+// with an ExtError named "Conflict" that wraps ErrConflict.
+func (s *Store) SetIf(key string, expectedStoreID string, obj interface{}) (types.MetaData, error) {
+	return s.SetArgsIf(types.SetArgs{Key: key, Object: obj}, expectedStoreID)
+}
+
+// SetArgsIf is SetIf for the full set of Set arguments.
+// Both are counted as the SetIf operation of the store metrics, conflicts as errors.
+func (s *Store) SetArgsIf(args types.SetArgs, expectedStoreID string) (meta types.MetaData, err error) {
+	defer func(start time.Time) { metrics.ObserveStore("SetIf", start, err) }(time.Now())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := s.currentStoreID(args.Key)
+	if current != expectedStoreID && (expectedStoreID != AnyRevision || current == "") {
+		return types.MetaData{}, &types.ExtError{
+			Name:    "Conflict",
+			Message: fmt.Sprintf("latest revision of %s is %q, expected %q", args.Key, current, expectedStoreID),
+			Cause:   ErrConflict,
+			Info:    map[string]string{"key": args.Key, "current": current, "expected": expectedStoreID},
+		}
+	}
+	return s.set(args)
+}
+
+// currentStoreID returns the storeID of the latest revision of a live key, or an empty string
+func (s *Store) currentStoreID(key string) string {
+	if !s.IsRegistered(key) {
+		return ""
+	}
+	storeID, err := s.db.GetCurrStoreID(key)
+	if err != nil {
+		return ""
+	}
+	return storeID
+}
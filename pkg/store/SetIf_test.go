@@ -0,0 +1,69 @@
+package store
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/cfjello/go-store/pkg/types"
+)
+
+func TestSetIf(t *testing.T) {
+	s := newTestStore(t)
+
+	if _, err := s.SetIf("counter", AnyRevision, map[string]interface{}{"n": 0}); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected a conflict for a key without revisions, got %v", err)
+	}
+	if _, err := s.SetIf("counter", "", map[string]interface{}{"n": 1}); err != nil {
+		t.Fatalf("SetIf() failed to create the key: %v", err)
+	}
+	first, _ := s.GetStoreID("counter")
+
+	if _, err := s.SetIf("counter", "", map[string]interface{}{"n": 2}); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected a conflict for an existing key, got %v", err)
+	}
+	if _, err := s.SetIf("counter", first, map[string]interface{}{"n": 2}); err != nil {
+		t.Fatalf("SetIf() failed with the current storeID: %v", err)
+	}
+
+	_, err := s.SetIf("counter", first, map[string]interface{}{"n": 3})
+	var extErr *types.ExtError
+	if !errors.As(err, &extErr) || extErr.Name != "Conflict" || extErr.Info["expected"] != first {
+		t.Fatalf("expected a Conflict error for a stale storeID, got %v", err)
+	}
+	if _, err := s.SetIf("counter", AnyRevision, map[string]interface{}{"n": 3}); err != nil {
+		t.Errorf("SetIf() failed with AnyRevision: %v", err)
+	}
+
+	s.UnRegister("counter")
+	if _, err := s.SetIf("counter", "", map[string]interface{}{"n": 4}); err != nil {
+		t.Errorf("expected a soft deleted key to count as having no revisions, got %v", err)
+	}
+}
+
+func TestSetIfConcurrent(t *testing.T) {
+	s := newTestStore(t)
+	if _, err := s.Set(types.SetArgs{Key: "counter", Object: map[string]interface{}{"n": 0}}); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	expected, _ := s.GetStoreID("counter")
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			if _, err := s.SetIf("counter", expected, map[string]interface{}{"n": n}); err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	if succeeded != 1 {
+		t.Errorf("expected exactly one writer to succeed, got %d", succeeded)
+	}
+}
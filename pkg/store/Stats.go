@@ -0,0 +1,65 @@
+package store
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cfjello/go-store/pkg/types"
+)
+
+// rateWindow is the number of seconds the write rate is averaged over
+const rateWindow = 60
+
+// writeRate counts writes in one bucket per second over the last rateWindow seconds
+type writeRate struct {
+	mu      sync.Mutex
+	started time.Time
+	total   int64
+	buckets [rateWindow]int64
+	seconds [rateWindow]int64 // the Unix second each bucket counts
+}
+
+func (r *writeRate) add(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sec := now.Unix()
+	i := sec % rateWindow
+	if r.seconds[i] != sec {
+		r.seconds[i] = sec
+		r.buckets[i] = 0
+	}
+	r.buckets[i]++
+	r.total++
+}
+
+// perSecond averages the writes of the last rateWindow seconds, or since started if that is later
+func (r *writeRate) perSecond(now time.Time) (int64, float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sec := now.Unix()
+	var sum int64
+	for i := range r.buckets {
+		if sec-r.seconds[i] < rateWindow {
+			sum += r.buckets[i]
+		}
+	}
+	window := now.Sub(r.started).Seconds()
+	if window > rateWindow {
+		window = rateWindow
+	}
+	if window < 1 {
+		window = 1
+	}
+	return r.total, float64(sum) / window
+}
+
+// Stats reports the number of keys, revisions and bytes in the store
+// and the revisions written since the store was created
+func (s *Store) Stats() (types.StoreStats, error) {
+	stats, err := s.db.Stats()
+	if err != nil {
+		return types.StoreStats{}, err
+	}
+	stats.Writes, stats.WritesPerSec = s.writes.perSecond(time.Now())
+	return stats, nil
+}
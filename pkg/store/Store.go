@@ -1,12 +1,16 @@
 package store
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/cfjello/go-store/internal/database"
 	"github.com/cfjello/go-store/pkg/dynReflect"
+	"github.com/cfjello/go-store/pkg/metrics"
 	"github.com/cfjello/go-store/pkg/types"
 	"github.com/cfjello/go-store/pkg/util"
 )
@@ -33,21 +37,38 @@ type StoreIntf[T any] interface {
 type Store struct {
 	InitStoreID string
 	SoftDel     string
-	db          *database.DBService
+	// EventRetention is how long events stay in the change log, zero keeps them forever
+	EventRetention time.Duration
+	db             Backend
+	mu             sync.Mutex // serializes writes of revisions, see SetIf
+	pruned         time.Time  // when the change log was last pruned, guarded by mu
+	watchMu        sync.Mutex
+	watchers       map[*watcher]struct{}
+	closed         bool
+	writes         writeRate
 }
 
-// NewStore creates a new store
-func New(dbServ *database.DBService) *Store {
+// New creates a new store on top of a storage backend
+func New(backend Backend) *Store {
 	return &Store{
-		InitStoreID: "0000",
-		SoftDel:     util.Ulid(),
-		db:          dbServ,
+		InitStoreID:    "0000",
+		SoftDel:        util.Ulid(),
+		EventRetention: DefaultEventRetention,
+		db:             backend,
+		watchers:       make(map[*watcher]struct{}),
+		writes:         writeRate{started: time.Now()},
 	}
 }
 
-/*
-// Register registers an object in the store
+// Register registers a key in the store ahead of its first Set.
+// The schema of the key is taken from args.Schema, which may be a dynReflect.TypeInfo,
+// a JSON Schema document or a sample object, from the schema of args.SchemaKey, or
+// from args.Object. With args.Init the object is stored as the first revision and
+// the Oper of the metadata is "reg&set" instead of "reg". Registering an existing
+// key replaces its metadata.
 func (s *Store) Register(args types.RegisterArgs) (types.MetaData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
 	if args.Key == "" {
 		return types.MetaData{}, errors.New("the key cannot be empty")
@@ -55,58 +76,153 @@ func (s *Store) Register(args types.RegisterArgs) (types.MetaData, error) {
 	if args.Init && args.Object == nil {
 		return types.MetaData{}, fmt.Errorf("the combination of init=true and an undefined object is not valid")
 	}
+	if args.Init && !isObject(args.Object) {
+		return types.MetaData{}, errors.New("an object must be passed to the store")
+	}
+	if args.SchemaKey == "" {
+		args.SchemaKey = args.Key
+	}
 
 	meta := types.MetaData{
 		Key:       args.Key,
 		Oper:      "reg",
 		Init:      args.Init,
-		SchemaKey: args.Key,
+		SchemaKey: args.SchemaKey,
 		Check:     args.Check,
 		SoftDel:   s.SoftDel,
 	}
-	if args.Init {
-		typeInfo := dynReflect.BuildTypeInfo(reflect.ValueOf(args.Object))
-		meta.TypeInfo = typeInfo
+	typeInfo, err := s.resolveSchema(args)
+	if err != nil {
+		return types.MetaData{}, err
+	}
+	meta.TypeInfo = typeInfo
+
+	if meta.Init && meta.Check {
+		if err := validate(meta, args.Object); err != nil {
+			return types.MetaData{}, err
+		}
 	}
 	// Register the key in the store
 	if !s.SetMetaData(meta.Key, meta) {
 		return types.MetaData{}, fmt.Errorf("cannot register object named: %s", args.Key)
 	}
 	if meta.Init {
+		storeID := util.Ulid()
+		event := newEvent(types.Event{ID: storeID, Oper: "reg&set", Key: meta.Key, SchemaKey: meta.SchemaKey, StoreID: storeID, JobID: storeID})
 		res := s.db.SetData(args.Key, types.SetArgs{
 			Key:       args.Key,
 			Object:    args.Object,
-			JobID:     "",
+			JobID:     storeID,
 			Check:     args.Check,
 			SchemaKey: meta.SchemaKey,
-		})
+			StoreID:   storeID,
+		}, &event)
 		if !res {
 			meta.Init = false
+			s.SetMetaData(meta.Key, meta)
 			return meta, fmt.Errorf("unable to store object for key %s", args.Key)
 		}
 		meta.Oper = "reg&set"
+		meta.StoreID = storeID
+		meta.JobID = storeID
 		s.SetMetaData(meta.Key, meta)
+		s.writes.add(time.Now())
+		s.notify(event)
 		return meta, nil
 	}
+	s.publish(types.Event{Oper: meta.Oper, Key: meta.Key, SchemaKey: meta.SchemaKey})
 	return meta, nil
 }
-*/
+
+// resolveSchema finds the type information a key is registered with
+func (s *Store) resolveSchema(args types.RegisterArgs) (dynReflect.TypeInfo, error) {
+	switch schema := args.Schema.(type) {
+	case nil:
+	case dynReflect.TypeInfo:
+		return schema, nil
+	case *dynReflect.TypeInfo:
+		return *schema, nil
+	case json.RawMessage, []byte, string:
+		var doc map[string]interface{}
+		if err := json.Unmarshal(toBytes(schema), &doc); err != nil {
+			return dynReflect.TypeInfo{}, fmt.Errorf("invalid schema for %s: %w", args.Key, err)
+		}
+		return schemaFromMap(args.Key, doc)
+	case map[string]interface{}:
+		return schemaFromMap(args.Key, schema)
+	default:
+		if !isObject(schema) {
+			return dynReflect.TypeInfo{}, fmt.Errorf("invalid schema for %s: expected a TypeInfo, a JSON Schema or a sample object", args.Key)
+		}
+		return typeInfoOf(schema), nil
+	}
+
+	// Share the schema of another registered key
+	if args.SchemaKey != args.Key {
+		if schemaMeta, err := s.db.GetMeta(args.SchemaKey, args.SchemaKey); err == nil {
+			return schemaMeta.TypeInfo, nil
+		}
+	}
+	if args.Object != nil {
+		return typeInfoOf(args.Object), nil
+	}
+	return dynReflect.TypeInfo{}, nil
+}
+
+// schemaFromMap treats a decoded JSON object as a JSON Schema document or as a sample object
+func schemaFromMap(key string, doc map[string]interface{}) (dynReflect.TypeInfo, error) {
+	if !dynReflect.IsJSONSchema(doc) {
+		return dynReflect.InferTypeInfo(doc), nil
+	}
+	typeInfo, err := dynReflect.FromJSONSchema(doc)
+	if err != nil {
+		return dynReflect.TypeInfo{}, fmt.Errorf("invalid JSON Schema for %s: %w", key, err)
+	}
+	return typeInfo, nil
+}
+
+func toBytes(v interface{}) []byte {
+	switch b := v.(type) {
+	case json.RawMessage:
+		return b
+	case []byte:
+		return b
+	case string:
+		return []byte(b)
+	}
+	return nil
+}
+
 // IsRegistered checks if a key is registered
 func (s *Store) IsRegistered(key string) bool {
-	_, err := s.GetMetaData(key, key)
-	return err == nil
+	meta, err := s.GetMetaData(key, key)
+	return err == nil && !IsDeleted(meta)
+}
+
+// IsDeleted reports whether the metadata belongs to a key that was removed with UnRegister
+func IsDeleted(meta types.MetaData) bool {
+	return meta.Oper == "del"
 }
 
 // Set stores an object in the store
-func (s *Store) Set(args types.SetArgs) (types.MetaData, error) {
+func (s *Store) Set(args types.SetArgs) (meta types.MetaData, err error) {
+	defer func(start time.Time) { metrics.ObserveStore("Set", start, err) }(time.Now())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.set(args)
+}
+
+func (s *Store) set(args types.SetArgs) (types.MetaData, error) {
 
-	if args.Object == nil || reflect.ValueOf(args.Object).Kind() != reflect.Map {
+	if !isObject(args.Object) {
 		return types.MetaData{}, errors.New("an object must be passed to the store")
 	}
 
 	// Generate new storeId, jobId
 	storeID := util.Ulid()
-	if args.JobID == "" {
+	args.StoreID = storeID
+	inJob := args.JobID != ""
+	if !inJob {
 		args.JobID = storeID
 	}
 	if args.SchemaKey == "" {
@@ -115,38 +231,134 @@ func (s *Store) Set(args types.SetArgs) (types.MetaData, error) {
 
 	var meta types.MetaData
 
-	meta, err := s.GetMetaData(args.Key, args.SchemaKey)
+	meta, err := s.GetMetaData(args.Key, args.Key)
+	if err != nil && args.SchemaKey != args.Key {
+		// A new key set under the schema key of other keys shares their schema
+		if schemaMeta, schemaErr := s.db.GetMeta(args.SchemaKey, args.SchemaKey); schemaErr == nil {
+			meta = types.MetaData{
+				Key:       args.Key,
+				Init:      true,
+				Oper:      "set",
+				Check:     schemaMeta.Check,
+				SchemaKey: args.SchemaKey,
+				SoftDel:   s.SoftDel,
+				TypeInfo:  schemaMeta.TypeInfo,
+			}
+			err = nil
+		}
+	}
 	if err != nil {
 		// If the key is not registered, we create a new metadata object
 		meta = types.MetaData{
-			Key:  args.Key,
-			Init: true,
-			Oper: "set",
-			// StoreID:   storeID,
-			// JobID:     args.JobID,
+			Key:       args.Key,
+			Init:      true,
+			Oper:      "set",
 			Check:     args.Check,
 			SchemaKey: args.SchemaKey,
 			SoftDel:   s.SoftDel,
-			TypeInfo:  dynReflect.BuildTypeInfo(reflect.ValueOf(args.Object)),
-		}
-		// First, set the metadata
-		if !s.SetMetaData(meta.Key, meta) {
-			return types.MetaData{}, fmt.Errorf("failed to store metadata for %s", meta.Key)
+			TypeInfo:  typeInfoOf(args.Object),
 		}
 	} else {
-		// Validate the object if check is true
-		if meta.Check || args.Check {
-			// Validation would go here, but we'll skip  validation for now
+		if IsDeleted(meta) {
+			// Setting a soft deleted key brings it back
+			meta.Oper = "set"
+			meta.SoftDel = s.SoftDel
 		}
+		if meta.TypeInfo.Kind == "" {
+			// Registered without a schema, the first object defines it
+			meta.TypeInfo = typeInfoOf(args.Object)
+		} else if meta.Check || args.Check {
+			// Refuse objects that do not match the registered type information
+			if err := validate(meta, args.Object); err != nil {
+				return meta, err
+			}
+		}
+		// The revision belongs to the schema the key is registered with
+		args.SchemaKey = meta.SchemaKey
 	}
-	// store the object data
-	if !s.db.SetData(args.Key, args) {
+	// store the object data, the backend links it to the previous revision of the key
+	// and logs the event with it
+	event := newEvent(types.Event{ID: storeID, Oper: "set", Key: args.Key, SchemaKey: args.SchemaKey, StoreID: storeID, JobID: args.JobID})
+	if !s.db.SetData(args.Key, args, &event) {
 		return types.MetaData{}, fmt.Errorf("failed to store data for %s", meta.Key)
 	}
+	// then point the metadata at the new revision
+	meta.StoreID = storeID
+	meta.JobID = args.JobID
+	if !s.SetMetaData(meta.Key, meta) {
+		return types.MetaData{}, fmt.Errorf("failed to store metadata for %s", meta.Key)
+	}
+	s.writes.add(time.Now())
+	if inJob {
+		s.touchJob(args.JobID)
+	}
+	s.notify(event)
 
 	return meta, nil
 }
 
+// validate checks an object against the TypeInfo of a key and lists every violation in the error
+func validate(meta types.MetaData, obj interface{}) error {
+	violations := meta.TypeInfo.Validate(obj)
+	if len(violations) == 0 {
+		return nil
+	}
+	info := make(map[string]string, len(violations))
+	messages := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		info[violation.Path] = violation.Message
+		messages = append(messages, violation.Path+": "+violation.Message)
+	}
+	return &types.ExtError{
+		Name:    "ValidationError",
+		Message: fmt.Sprintf("object for %s does not match its type information: %s", meta.Key, strings.Join(messages, "; ")),
+		Info:    info,
+	}
+}
+
+// typeInfoOf describes an object, Go structs by their type and anything else by its JSON shape.
+// Either way the fields carry their JSON names, so both describe the same stored objects alike.
+func typeInfoOf(obj interface{}) dynReflect.TypeInfo {
+	v := reflect.Indirect(reflect.ValueOf(obj))
+	if v.Kind() == reflect.Struct {
+		return dynReflect.BuildTypeInfo(v)
+	}
+	return dynReflect.InferTypeInfo(obj)
+}
+
+// InferSchema infers the schema of a key from the JSON shape of all its stored revisions,
+// fields that are missing from some revisions are optional
+func (s *Store) InferSchema(key string) (dynReflect.TypeInfo, error) {
+	var infos []dynReflect.TypeInfo
+	opts := types.HistoryOpts{Limit: 100}
+	for {
+		page, err := s.History(key, opts)
+		if err != nil {
+			return dynReflect.TypeInfo{}, err
+		}
+		for _, rev := range page.Revisions {
+			infos = append(infos, dynReflect.InferTypeInfo(rev.Object))
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+	if len(infos) == 0 {
+		return dynReflect.TypeInfo{}, fmt.Errorf("no revisions found for %s", key)
+	}
+	return dynReflect.MergeTypeInfo(infos...), nil
+}
+
+// isObject reports whether obj is a map, a struct or a pointer to one of them
+func isObject(obj interface{}) bool {
+	if obj == nil {
+		return false
+	}
+	kind := reflect.Indirect(reflect.ValueOf(obj)).Kind()
+	return kind == reflect.Map || kind == reflect.Struct
+}
+
 // Has is an alias for IsRegistered
 func (s *Store) Has(key string) bool {
 	return s.IsRegistered(key)
@@ -157,14 +369,33 @@ func (s *Store) Has(key string) bool {
 // 	return s.db.HasData(storeID)
 // }
 
-// UnRegister removes a key from the store
+// UnRegister soft deletes a key, its metadata and revisions are kept until the key is set again
 func (s *Store) UnRegister(key string) bool {
-	meta, err := s.GetMetaData(key, key)
-	if err != nil {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	meta, err := s.db.GetMeta(key, key)
+	if err != nil || meta.Key != key {
+		// Not the metadata of key itself, but of a key registered under a schema key named like it
 		return false
 	}
 	meta.SoftDel = util.Ulid()
-	return s.SetMetaData(key, meta)
+	meta.Oper = "del"
+	if !s.SetMetaData(key, meta) {
+		return false
+	}
+	s.publish(types.Event{Oper: meta.Oper, Key: key, SchemaKey: meta.SchemaKey})
+	return true
+}
+
+// Delete permanently removes a key and all of its revisions, see UnRegister for a soft delete
+func (s *Store) Delete(key string) bool {
+	return s.db.Delete(key)
+}
+
+// Close ends all watches and closes the storage backend
+func (s *Store) Close() error {
+	s.CloseWatches()
+	return s.db.Close()
 }
 
 // SetMetaData sets metadata for a key
@@ -172,29 +403,56 @@ func (s *Store) SetMetaData(key string, meta types.MetaData) bool {
 	return s.db.SetMeta(key, meta)
 }
 
-// GetMetaData gets metadata for a key
+// GetMetaData gets the metadata of a key. Without metadata of its own and with a schemaKey
+// other than key, it gets the metadata of the first key registered under schemaKey.
 func (s *Store) GetMetaData(key string, schemaKey string) (types.MetaData, error) {
-	// var meta types.MetaData
+	start := time.Now()
 	if schemaKey == "" {
 		schemaKey = key
 	}
 	meta, err := s.db.GetMeta(key, schemaKey)
+	if err == nil && meta.Key != "" && meta.Key != key && schemaKey == key {
+		// Only another key registered under a schema key named like key was found
+		err = fmt.Errorf("no metadata for %s: %w", key, ErrNotFound)
+	}
+	metrics.ObserveStore("GetMetaData", start, err)
 	if err != nil {
 		return types.MetaData{}, err
 	}
 	return meta, nil
 }
 
+// GetJSONSchema gets the schema of a key as a JSON Schema document
+func (s *Store) GetJSONSchema(key string) (map[string]interface{}, error) {
+	meta, err := s.GetMetaData(key, key)
+	if err != nil {
+		return nil, err
+	}
+	if meta.TypeInfo.Kind == "" {
+		return nil, fmt.Errorf("no schema registered for %s", key)
+	}
+	schema := dynReflect.ToJSONSchema(meta.TypeInfo)
+	schema["$id"] = meta.SchemaKey
+	return schema, nil
+}
+
 // Get gets an object from the store
 func (s *Store) Get(storeID string, key string) (interface{}, error) {
-	if key == "" && storeID == "" {
+	start := time.Now()
+	obj, err := s.get(storeID, key)
+	metrics.ObserveStore("Get", start, err)
+	return obj, err
+}
+
+func (s *Store) get(storeID string, key string) (interface{}, error) {
+	if key == "" {
 		return *new(interface{}), errors.New("no \"key\" provided for Get()")
 	}
 	// Here we lookup the latest storeID from metadata if not provided
 	if storeID == "" {
 		SID, err := s.db.GetCurrStoreID(key)
 		if err != nil {
-			return *new(interface{}), errors.New("no \"default storeId\" provided for Get()")
+			return *new(interface{}), fmt.Errorf("no \"default storeId\" provided for Get() of %s: %w", key, err)
 		}
 		storeID = SID
 	}
@@ -202,52 +460,15 @@ func (s *Store) Get(storeID string, key string) (interface{}, error) {
 		return *new(interface{}), errors.New("no \"storeId\" provided for getData()")
 	}
 
-	objData, err := s.db.GetData(storeID)
+	// The revision must belong to key, a storeID of another key is not found
+	objData, err := s.db.GetData(key, storeID)
 	if err != nil {
-		return *new(interface{}), fmt.Errorf("failed to fetch data for %s with storeId: %s", key, storeID)
+		return *new(interface{}), fmt.Errorf("failed to fetch data for %s with storeId %s: %w", key, storeID, err)
 	}
 	return objData, nil
 }
 
-/*
-
-// GetData gets data for a key
-func (s *Store) GetTypedCall(key string, storeID string) (any, error) {
-	result := s.GetTyped(s, storeID, key)
-	if err != nil {
-		return nil, err
-	}
-	return result.Data, nil
-}
-*/
-
-/*
-
-// GetStoreIDs gets store IDs for a type
-func (s *Store) GetStoreIDs(objType string, jobID string) []string {
-	if jobID == "" {
-		jobID = "%"
-	}
-	return s.db.GetStoreIDsByType(objType, jobID)
-}
-
-
-// GetStoreID gets the store ID for a key
+// GetStoreID gets the store ID of the latest revision of a key
 func (s *Store) GetStoreID(key string) (string, error) {
-	meta, err := s.GetMetaData(key)
-	if err != nil {
-		return "", err
-	}
-	return meta.StoreID, nil
+	return s.db.GetCurrStoreID(key)
 }
-
-// SetJobIdx sets a job index
-func (s *Store) SetJobIdx(adt ADT_Job) bool {
-	adtJSON, err := json.Marshal(adt)
-	if err != nil {
-		return false
-	}
-	res := s.db.InsertJob(adt.JobID, adt.StoreID, string(adtJSON))
-	return res == 1
-}
-*/
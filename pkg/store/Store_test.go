@@ -0,0 +1,145 @@
+package store
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cfjello/go-store/pkg/types"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	s := New(NewMemBackend())
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSetAndGet(t *testing.T) {
+	s := newTestStore(t)
+
+	meta, err := s.Set(types.SetArgs{Key: "person", Object: map[string]interface{}{"name": "Ada", "age": 36}})
+	if err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if meta.Key != "person" || meta.SchemaKey != "person" || meta.Oper != "set" {
+		t.Errorf("unexpected meta data: %+v", meta)
+	}
+	if !s.Has("person") {
+		t.Error("expected the key to be registered after Set()")
+	}
+
+	obj, err := s.Get("", "person")
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	person := obj.(map[string]interface{})
+	if person["name"] != "Ada" || person["age"] != float64(36) {
+		t.Errorf("unexpected object: %v", person)
+	}
+}
+
+func TestSetRejectsNonObjects(t *testing.T) {
+	s := newTestStore(t)
+	if _, err := s.Set(types.SetArgs{Key: "person"}); err == nil {
+		t.Error("expected Set() without an object to fail")
+	}
+	if _, err := s.Set(types.SetArgs{Key: "person", Object: "Ada"}); err == nil {
+		t.Error("expected Set() with a string to fail")
+	}
+}
+
+func TestUnRegisterAndDelete(t *testing.T) {
+	s := newTestStore(t)
+	if _, err := s.Set(types.SetArgs{Key: "person", Object: map[string]interface{}{"name": "Ada"}}); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+
+	if !s.UnRegister("person") {
+		t.Fatal("UnRegister() failed")
+	}
+	meta, err := s.GetMetaData("person", "")
+	if err != nil || meta.SoftDel == s.SoftDel {
+		t.Errorf("expected a new soft delete marker, got %+v (%v)", meta, err)
+	}
+
+	if !s.Delete("person") {
+		t.Fatal("Delete() failed")
+	}
+	if s.Has("person") {
+		t.Error("expected the key to be gone after Delete()")
+	}
+	if _, err := s.Get("", "person"); err == nil {
+		t.Error("expected Get() to fail after Delete()")
+	}
+}
+
+func TestSetWithCheck(t *testing.T) {
+	s := newTestStore(t)
+	type person struct {
+		Name string
+		Age  int
+	}
+	if _, err := s.Set(types.SetArgs{Key: "person", Object: person{Name: "Ada", Age: 36}, Check: true}); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+
+	if _, err := s.Set(types.SetArgs{Key: "person", Object: map[string]interface{}{"name": "Grace", "age": 85}}); err != nil {
+		t.Errorf("expected a matching object to be stored, got %v", err)
+	}
+
+	_, err := s.Set(types.SetArgs{Key: "person", Object: map[string]interface{}{"name": 1, "email": "x"}})
+	var extErr *types.ExtError
+	if !errors.As(err, &extErr) || extErr.Name != "ValidationError" {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+	for _, path := range []string{"$.name", "$.email", "$.Age"} {
+		if _, ok := extErr.Info[path]; !ok {
+			t.Errorf("expected a violation at %s, got %v", path, extErr.Info)
+		}
+	}
+	page, _ := s.History("person", types.HistoryOpts{})
+	if len(page.Revisions) != 2 {
+		t.Errorf("expected the invalid object to be refused, got %d revisions", len(page.Revisions))
+	}
+}
+
+func TestSetWithCheckTaggedStruct(t *testing.T) {
+	s := newTestStore(t)
+	type person struct {
+		FirstName string `json:"first_name"`
+	}
+	if _, err := s.Register(types.RegisterArgs{Key: "person", Schema: person{}, Check: true}); err != nil {
+		t.Fatalf("Register() failed: %v", err)
+	}
+	if _, err := s.Set(types.SetArgs{Key: "person", Object: map[string]interface{}{"first_name": "Ada"}}); err != nil {
+		t.Errorf("expected an object with the JSON names to be stored, got %v", err)
+	}
+	if _, err := s.Set(types.SetArgs{Key: "person", Object: person{FirstName: "Grace"}}); err != nil {
+		t.Errorf("expected the struct itself to be stored, got %v", err)
+	}
+	if _, err := s.Set(types.SetArgs{Key: "person", Object: map[string]interface{}{"FirstName": "Barbara"}}); err == nil {
+		t.Error("expected an object with the Go field names to be refused")
+	}
+}
+
+func TestSetAfterUnRegister(t *testing.T) {
+	s := newTestStore(t)
+	if _, err := s.Set(types.SetArgs{Key: "person", Object: map[string]interface{}{"name": "Ada"}}); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if !s.UnRegister("person") || s.Has("person") {
+		t.Fatal("expected the key to be soft deleted")
+	}
+
+	meta, err := s.Set(types.SetArgs{Key: "person", Object: map[string]interface{}{"name": "Grace"}})
+	if err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if IsDeleted(meta) || meta.SoftDel != s.SoftDel || !s.Has("person") {
+		t.Errorf("expected the key to be set again, got %+v", meta)
+	}
+	page, _ := s.History("person", types.HistoryOpts{})
+	if len(page.Revisions) != 2 {
+		t.Errorf("expected the revisions to survive the soft delete, got %d", len(page.Revisions))
+	}
+}
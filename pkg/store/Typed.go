@@ -0,0 +1,143 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/cfjello/go-store/pkg/dynReflect"
+	"github.com/cfjello/go-store/pkg/types"
+)
+
+// GetAs gets the latest object stored under a key decoded into a T.
+// T must be compatible with the TypeInfo recorded when the key was first set.
+func GetAs[T any](s *Store, key string) (T, error) {
+	return GetVersionAs[T](s, key, "")
+}
+
+// GetVersionAs gets the object stored under storeID decoded into a T,
+// or the latest object if storeID is empty.
+func GetVersionAs[T any](s *Store, key string, storeID string) (T, error) {
+	var result T
+	meta, err := s.GetMetaData(key, key)
+	if err != nil {
+		return result, fmt.Errorf("no meta data found for %s: %w", key, err)
+	}
+	if err := checkTypeOf[T](meta); err != nil {
+		return result, err
+	}
+
+	obj, err := s.Get(storeID, key)
+	if err != nil {
+		return result, err
+	}
+	objJSON, err := json.Marshal(obj)
+	if err != nil {
+		return result, err
+	}
+	if err := json.Unmarshal(objJSON, &result); err != nil {
+		return result, fmt.Errorf("cannot decode %s into %T: %w", key, result, err)
+	}
+	return result, nil
+}
+
+// SetFrom stores a struct or map as a new revision of a key.
+// If the key is already known, T must be compatible with its recorded TypeInfo.
+func SetFrom[T any](s *Store, key string, v T) (types.MetaData, error) {
+	if meta, err := s.GetMetaData(key, key); err == nil {
+		if err := checkTypeOf[T](meta); err != nil {
+			return types.MetaData{}, err
+		}
+	}
+	return s.Set(types.SetArgs{Key: key, Object: v})
+}
+
+// checkTypeOf checks that T can hold the objects described by meta.TypeInfo
+func checkTypeOf[T any](meta types.MetaData) error {
+	want := dynReflect.BuildTypeInfo(reflect.New(reflect.TypeOf((*T)(nil)).Elem()).Elem())
+	if err := compatible(meta.TypeInfo, want, meta.Key); err != nil {
+		return &types.ExtError{
+			Name:    "TypeMismatch",
+			Message: err.Error(),
+			Cause:   err,
+			Info:    map[string]string{"key": meta.Key, "stored": meta.TypeInfo.Type, "requested": want.Type},
+		}
+	}
+	return nil
+}
+
+// compatible reports whether values described by stored decode into values described by want.
+// Untyped JSON objects (maps) are compatible with any struct or map.
+func compatible(stored dynReflect.TypeInfo, want dynReflect.TypeInfo, path string) error {
+	stored, want = deref(stored), deref(want)
+	if stored.Type == "" || want.Kind == "interface" || stored.Kind == "interface" || stored.Type == want.Type {
+		return nil
+	}
+	switch want.Kind {
+	case "struct":
+		if stored.Kind == "map" {
+			return nil
+		}
+		if stored.Kind != "struct" {
+			return fmt.Errorf("%s: cannot decode %s into %s", path, stored.Type, want.Type)
+		}
+		for name, field := range want.Fields {
+			storedField, ok := lookupField(stored.Fields, name)
+			if !ok && stored.Type == "object" {
+				// Inferred from JSON, the field is simply absent from the stored objects
+				continue
+			}
+			if !ok {
+				return fmt.Errorf("%s.%s: field not found in %s", path, name, stored.Type)
+			}
+			if err := compatible(storedField, field, path+"."+name); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "map":
+		if stored.Kind == "struct" {
+			return nil
+		}
+		if stored.Kind == "map" && stored.ValueType != nil && want.ValueType != nil {
+			return compatible(*stored.ValueType, *want.ValueType, path+"[]")
+		}
+	case "slice", "array":
+		if stored.Kind == "slice" || stored.Kind == "array" {
+			if stored.Elem != nil && want.Elem != nil {
+				return compatible(*stored.Elem, *want.Elem, path+"[]")
+			}
+			return nil
+		}
+	default:
+		if dynReflect.IsNumberKind(want.Kind) && dynReflect.IsNumberKind(stored.Kind) || want.Kind == stored.Kind {
+			return nil
+		}
+	}
+	if stored.Kind == want.Kind {
+		return nil
+	}
+	return fmt.Errorf("%s: cannot decode %s into %s", path, stored.Type, want.Type)
+}
+
+// lookupField finds a field by name, falling back to a case-insensitive match like encoding/json
+func lookupField(fields map[string]dynReflect.TypeInfo, name string) (dynReflect.TypeInfo, bool) {
+	if field, ok := fields[name]; ok {
+		return field, true
+	}
+	for key, field := range fields {
+		if strings.EqualFold(key, name) {
+			return field, true
+		}
+	}
+	return dynReflect.TypeInfo{}, false
+}
+
+// deref unwraps pointer type information
+func deref(info dynReflect.TypeInfo) dynReflect.TypeInfo {
+	for info.Kind == "ptr" && info.Elem != nil {
+		info = *info.Elem
+	}
+	return info
+}
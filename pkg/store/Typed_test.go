@@ -0,0 +1,84 @@
+package store
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cfjello/go-store/pkg/types"
+)
+
+type testAddress struct {
+	City string `json:"city"`
+}
+
+type testPerson struct {
+	Name    string       `json:"name"`
+	Age     int          `json:"age"`
+	Tags    []string     `json:"tags"`
+	Address *testAddress `json:"address"`
+}
+
+type testAnimal struct {
+	Name    string `json:"name"`
+	Species string `json:"species"`
+}
+
+func TestSetFromAndGetAs(t *testing.T) {
+	s := newTestStore(t)
+	ada := testPerson{Name: "Ada", Age: 36, Tags: []string{"math"}, Address: &testAddress{City: "London"}}
+
+	meta, err := SetFrom(s, "ada", ada)
+	if err != nil {
+		t.Fatalf("SetFrom() failed: %v", err)
+	}
+	if meta.TypeInfo.Kind != "struct" || meta.TypeInfo.Type != "store.testPerson" {
+		t.Errorf("expected the struct type to be recorded, got %+v", meta.TypeInfo)
+	}
+
+	got, err := GetAs[testPerson](s, "ada")
+	if err != nil {
+		t.Fatalf("GetAs() failed: %v", err)
+	}
+	if got.Name != "Ada" || got.Age != 36 || len(got.Tags) != 1 || got.Address == nil || got.Address.City != "London" {
+		t.Errorf("unexpected object: %+v", got)
+	}
+
+	ptr, err := GetAs[*testPerson](s, "ada")
+	if err != nil || ptr.Name != "Ada" {
+		t.Errorf("expected GetAs() with a pointer type to work, got %+v (%v)", ptr, err)
+	}
+	asMap, err := GetAs[map[string]interface{}](s, "ada")
+	if err != nil || asMap["name"] != "Ada" {
+		t.Errorf("expected GetAs() with a map type to work, got %v (%v)", asMap, err)
+	}
+}
+
+func TestGetAsTypeMismatch(t *testing.T) {
+	s := newTestStore(t)
+	if _, err := SetFrom(s, "ada", testPerson{Name: "Ada"}); err != nil {
+		t.Fatalf("SetFrom() failed: %v", err)
+	}
+
+	_, err := GetAs[testAnimal](s, "ada")
+	var extErr *types.ExtError
+	if !errors.As(err, &extErr) || extErr.Name != "TypeMismatch" {
+		t.Errorf("expected a TypeMismatch error, got %v", err)
+	}
+	if _, err := SetFrom(s, "ada", testAnimal{Name: "Cat"}); err == nil {
+		t.Error("expected SetFrom() with an incompatible type to fail")
+	}
+	if _, err := GetAs[string](s, "ada"); err == nil {
+		t.Error("expected GetAs() into a string to fail")
+	}
+}
+
+func TestGetAsFromMap(t *testing.T) {
+	s := newTestStore(t)
+	if _, err := s.Set(types.SetArgs{Key: "cat", Object: map[string]interface{}{"name": "Tom", "species": "cat"}}); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	cat, err := GetAs[testAnimal](s, "cat")
+	if err != nil || cat.Name != "Tom" || cat.Species != "cat" {
+		t.Errorf("expected a map to decode into a struct, got %+v (%v)", cat, err)
+	}
+}
@@ -0,0 +1,213 @@
+package store
+
+import (
+	"context"
+	"log"
+	"path"
+	"time"
+
+	"github.com/cfjello/go-store/pkg/types"
+	"github.com/cfjello/go-store/pkg/util"
+)
+
+// watchBuffer is the number of events a watcher may fall behind before it
+// has to catch up from the change log
+const watchBuffer = 64
+
+// replayPage is the number of events read from the change log at a time
+const replayPage = 100
+
+// DefaultEventRetention is how long a new store keeps events in the change log
+const DefaultEventRetention = 7 * 24 * time.Hour
+
+// pruneInterval is how often at most the change log is pruned
+const pruneInterval = time.Minute
+
+// watcher is a live subscription, its channel is closed when it falls behind
+type watcher struct {
+	pattern string
+	events  chan types.Event
+}
+
+// Watch streams the changes made to keys matching keyPattern from now on.
+// keyPattern is a path.Match pattern, an empty pattern matches every key.
+// The channel is closed when ctx is done or the store is closed.
+func (s *Store) Watch(ctx context.Context, keyPattern string) (<-chan types.Event, error) {
+	lastID, err := s.db.LastEventID()
+	if err != nil {
+		return nil, err
+	}
+	return s.WatchFrom(ctx, keyPattern, lastID)
+}
+
+// WatchFrom streams the changes made to keys matching keyPattern after the event
+// with ID afterID, typically the last storeID a watcher saw before reconnecting.
+// Logged events are replayed first, an empty afterID replays the whole change log.
+// Events older than s.EventRetention are pruned from the log and not replayed.
+func (s *Store) WatchFrom(ctx context.Context, keyPattern string, afterID string) (<-chan types.Event, error) {
+	if _, err := path.Match(keyPattern, ""); err != nil {
+		return nil, err
+	}
+	out := make(chan types.Event)
+	go s.watch(ctx, keyPattern, afterID, out)
+	return out, nil
+}
+
+// watch feeds out from the change log and then from a live subscription.
+// A watcher that falls behind loses its subscription and catches up from the log again.
+func (s *Store) watch(ctx context.Context, pattern string, lastID string, out chan<- types.Event) {
+	defer close(out)
+	for {
+		w := s.subscribe(pattern)
+		if w == nil {
+			return
+		}
+		var ok bool
+		if lastID, ok = s.replay(ctx, pattern, lastID, out); !ok {
+			s.unsubscribe(w)
+			return
+		}
+	live:
+		for {
+			select {
+			case <-ctx.Done():
+				s.unsubscribe(w)
+				return
+			case event, open := <-w.events:
+				if !open {
+					break live
+				}
+				if event.ID <= lastID {
+					// Already replayed from the log
+					continue
+				}
+				select {
+				case out <- event:
+					lastID = event.ID
+				case <-ctx.Done():
+					s.unsubscribe(w)
+					return
+				}
+			}
+		}
+	}
+}
+
+// replay sends the logged events after lastID and returns the ID of the last event sent
+func (s *Store) replay(ctx context.Context, pattern string, lastID string, out chan<- types.Event) (string, bool) {
+	for {
+		events, err := s.db.GetEvents(lastID, replayPage)
+		if err != nil {
+			log.Printf("Failed to read the change log after %s: %v", lastID, err)
+			return lastID, false
+		}
+		for _, event := range events {
+			if matchKey(pattern, event.Key) {
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return lastID, false
+				}
+			}
+			lastID = event.ID
+		}
+		if len(events) < replayPage {
+			return lastID, true
+		}
+	}
+}
+
+func (s *Store) subscribe(pattern string) *watcher {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	if s.closed {
+		return nil
+	}
+	w := &watcher{pattern: pattern, events: make(chan types.Event, watchBuffer)}
+	s.watchers[w] = struct{}{}
+	return w
+}
+
+func (s *Store) unsubscribe(w *watcher) {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	if _, ok := s.watchers[w]; ok {
+		delete(s.watchers, w)
+		close(w.events)
+	}
+}
+
+// newEvent gives an event its ID, unless it has one, and the timestamp of the ID
+func newEvent(event types.Event) types.Event {
+	if event.ID == "" {
+		event.ID = util.Ulid()
+	}
+	if ts, err := util.UlidTime(event.ID); err == nil {
+		event.Timestamp = ts
+	}
+	return event
+}
+
+// publish logs an event that stores no object and hands it to the live watchers,
+// the caller holds s.mu so events are published in ID order
+func (s *Store) publish(event types.Event) {
+	event = newEvent(event)
+	if !s.db.AppendEvent(event) {
+		log.Printf("Failed to log %s event for key: %s", event.Oper, event.Key)
+	}
+	s.notify(event)
+}
+
+// notify hands a logged event to the live watchers, the caller holds s.mu
+func (s *Store) notify(event types.Event) {
+	s.pruneEvents(event.Timestamp)
+
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	for w := range s.watchers {
+		if !matchKey(w.pattern, event.Key) {
+			continue
+		}
+		select {
+		case w.events <- event:
+		default:
+			// Too far behind, the watcher catches up from the change log
+			delete(s.watchers, w)
+			close(w.events)
+		}
+	}
+}
+
+// pruneEvents removes the events older than s.EventRetention from the change log,
+// at most once per pruneInterval, the caller holds s.mu
+func (s *Store) pruneEvents(now time.Time) {
+	if s.EventRetention <= 0 || now.Sub(s.pruned) < pruneInterval {
+		return
+	}
+	s.pruned = now
+	if _, err := s.db.PruneEvents(util.UlidLowerBound(now.Add(-s.EventRetention))); err != nil {
+		log.Printf("Failed to prune the change log: %v", err)
+	}
+}
+
+// CloseWatches ends all watches and refuses new ones, Close calls it before closing
+// the backend. A server calls it first on shutdown, so the watch streams end before
+// it waits for the open requests.
+func (s *Store) CloseWatches() {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	s.closed = true
+	for w := range s.watchers {
+		delete(s.watchers, w)
+		close(w.events)
+	}
+}
+
+// matchKey reports whether a key matches a watch pattern
+func matchKey(pattern string, key string) bool {
+	if pattern == "" {
+		return true
+	}
+	matched, _ := path.Match(pattern, key)
+	return matched
+}
@@ -0,0 +1,188 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cfjello/go-store/pkg/types"
+)
+
+func nextEvent(t *testing.T, events <-chan types.Event) types.Event {
+	t.Helper()
+	select {
+	case event, ok := <-events:
+		if !ok {
+			t.Fatal("the watch ended unexpectedly")
+		}
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for an event")
+	}
+	return types.Event{}
+}
+
+func TestWatch(t *testing.T) {
+	s := newTestStore(t)
+	if _, err := s.Set(types.SetArgs{Key: "person/ada", Object: map[string]interface{}{"name": "Ada"}}); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := s.Watch(ctx, "person/*")
+	if err != nil {
+		t.Fatalf("Watch() failed: %v", err)
+	}
+
+	if _, err := s.Register(types.RegisterArgs{Key: "person/grace", Object: map[string]interface{}{"name": "Grace"}}); err != nil {
+		t.Fatalf("Register() failed: %v", err)
+	}
+	if _, err := s.Set(types.SetArgs{Key: "city/paris", Object: map[string]interface{}{"name": "Paris"}}); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if _, err := s.Set(types.SetArgs{Key: "person/grace", Object: map[string]interface{}{"name": "Grace"}, JobID: "job-1"}); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	s.UnRegister("person/grace")
+
+	reg := nextEvent(t, events)
+	if reg.Oper != "reg" || reg.Key != "person/grace" || reg.StoreID != "" {
+		t.Errorf("unexpected register event: %+v", reg)
+	}
+	set := nextEvent(t, events)
+	storeID, _ := s.GetStoreID("person/grace")
+	if set.Oper != "set" || set.StoreID != storeID || set.ID != storeID || set.JobID != "job-1" || set.SchemaKey != "person/grace" {
+		t.Errorf("unexpected set event: %+v", set)
+	}
+	if set.Timestamp.IsZero() || set.ID <= reg.ID {
+		t.Errorf("expected ordered, timestamped events, got %+v after %+v", set, reg)
+	}
+	del := nextEvent(t, events)
+	if del.Oper != "del" || del.Key != "person/grace" {
+		t.Errorf("unexpected delete event: %+v", del)
+	}
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestWatchFromResumes(t *testing.T) {
+	s := newTestStore(t)
+	var storeIDs []string
+	for i := 0; i < 3; i++ {
+		if _, err := s.Set(types.SetArgs{Key: "counter", Object: map[string]interface{}{"n": i}}); err != nil {
+			t.Fatalf("Set() failed: %v", err)
+		}
+		storeID, _ := s.GetStoreID("counter")
+		storeIDs = append(storeIDs, storeID)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := s.WatchFrom(ctx, "", storeIDs[0])
+	if err != nil {
+		t.Fatalf("WatchFrom() failed: %v", err)
+	}
+	for _, want := range storeIDs[1:] {
+		if event := nextEvent(t, events); event.StoreID != want {
+			t.Errorf("expected the replayed revision %s, got %+v", want, event)
+		}
+	}
+	if _, err := s.Set(types.SetArgs{Key: "counter", Object: map[string]interface{}{"n": 3}}); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	latest, _ := s.GetStoreID("counter")
+	if event := nextEvent(t, events); event.StoreID != latest {
+		t.Errorf("expected the live revision %s, got %+v", latest, event)
+	}
+}
+
+func TestWatchSlowWatcherCatchesUp(t *testing.T) {
+	s := newTestStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := s.Watch(ctx, "counter")
+	if err != nil {
+		t.Fatalf("Watch() failed: %v", err)
+	}
+
+	total := 3 * watchBuffer
+	for i := 0; i < total; i++ {
+		if _, err := s.Set(types.SetArgs{Key: "counter", Object: map[string]interface{}{"n": i}}); err != nil {
+			t.Fatalf("Set() failed: %v", err)
+		}
+	}
+	lastID := ""
+	for i := 0; i < total; i++ {
+		event := nextEvent(t, events)
+		if event.ID <= lastID {
+			t.Fatalf("event %d out of order: %s after %s", i, event.ID, lastID)
+		}
+		lastID = event.ID
+	}
+	if latest, _ := s.GetStoreID("counter"); lastID != latest {
+		t.Errorf("expected to catch up to %s, got %s", latest, lastID)
+	}
+}
+
+func TestCloseEndsWatches(t *testing.T) {
+	s := New(NewMemBackend())
+	events, err := s.Watch(context.Background(), "")
+	if err != nil {
+		t.Fatalf("Watch() failed: %v", err)
+	}
+	s.Close()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("expected no events after Close()")
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("expected Close() to end the watch")
+	}
+}
+
+func TestPruneEvents(t *testing.T) {
+	s := newTestStore(t)
+	for i := 0; i < 3; i++ {
+		if _, err := s.Set(types.SetArgs{Key: "counter", Object: map[string]interface{}{"n": i}}); err != nil {
+			t.Fatalf("Set() failed: %v", err)
+		}
+	}
+	if events, _ := s.db.GetEvents("", 0); len(events) != 3 {
+		t.Fatalf("expected every event in the change log, got %+v", events)
+	}
+
+	// The next event prunes the change log again and keeps nothing older than itself
+	s.EventRetention = time.Nanosecond
+	s.pruned = time.Time{}
+	time.Sleep(2 * time.Millisecond)
+	if _, err := s.Set(types.SetArgs{Key: "counter", Object: map[string]interface{}{"n": 3}}); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	latest, _ := s.GetStoreID("counter")
+	if events, _ := s.db.GetEvents("", 0); len(events) != 1 || events[0].ID != latest {
+		t.Errorf("expected only the latest event %s after pruning, got %+v", latest, events)
+	}
+}
+
+func TestCloseWatchesKeepsTheStoreOpen(t *testing.T) {
+	s := newTestStore(t)
+	events, err := s.Watch(context.Background(), "")
+	if err != nil {
+		t.Fatalf("Watch() failed: %v", err)
+	}
+	s.CloseWatches()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("expected no events after CloseWatches()")
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("expected CloseWatches() to end the watch")
+	}
+	if _, err := s.Set(types.SetArgs{Key: "counter", Object: map[string]interface{}{"n": 1}}); err != nil {
+		t.Errorf("expected the store to stay open, Set() failed: %v", err)
+	}
+}
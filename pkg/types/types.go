@@ -2,17 +2,18 @@ package types
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/cfjello/go-store/pkg/dynReflect"
 )
 
 // MetaData represents metadata about stored objects
 type MetaData struct {
-	Key  string `json:"key"`
-	Init bool   `json:"init"`
-	Oper string `json:"oper"`
-	// StoreID  string              `json:"storeId"`
-	// JobID    string              `json:"jobId"`
+	Key       string              `json:"key"`
+	Init      bool                `json:"init"`
+	Oper      string              `json:"oper"`
+	StoreID   string              `json:"storeId,omitempty"` // storeID of the latest revision
+	JobID     string              `json:"jobId,omitempty"`   // jobID of the latest revision
 	Check     bool                `json:"check"`
 	SoftDel   string              `json:"deleted,omitempty"`
 	SchemaKey string              `json:"schemaKey"`
@@ -26,6 +27,7 @@ type SetArgs struct {
 	JobID     string      `json:"jobId,omitempty"`
 	Check     bool        `json:"check,omitempty"`
 	SchemaKey string      `json:"schemaKey,omitempty"`
+	StoreID   string      `json:"storeId,omitempty"` // assigned by the store
 }
 
 // RegisterArgs represents arguments for the register operation
@@ -38,6 +40,44 @@ type RegisterArgs struct {
 	SchemaKey string      `json:"schemaKey,omitempty"`
 }
 
+// HistoryOpts represents paging options for listing the revisions of a key
+type HistoryOpts struct {
+	Limit  int    `json:"limit,omitempty"`  // maximum number of revisions, 0 for all
+	Cursor string `json:"cursor,omitempty"` // NextCursor of the previous page
+	Desc   bool   `json:"desc,omitempty"`   // newest revision first
+}
+
+// Revision represents a single stored version of a key
+type Revision struct {
+	Key         string      `json:"key,omitempty"` // set when revisions of several keys are listed
+	StoreID     string      `json:"storeId"`
+	PrevStoreID string      `json:"prevStoreId,omitempty"` // previous revision of the key, empty for the first
+	JobID       string      `json:"jobId"`
+	SchemaKey   string      `json:"schemaKey,omitempty"`
+	Timestamp   time.Time   `json:"timestamp"`
+	Object      interface{} `json:"object"`
+}
+
+// HistoryPage represents one page of revisions
+type HistoryPage struct {
+	Revisions  []Revision `json:"revisions"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+}
+
+// Event represents a change to a key as seen by watchers.
+// Events are ordered by ID, which is the storeID for events that store an object.
+// An event only identifies the change, watchers read the metadata and the object
+// of the key when they need them.
+type Event struct {
+	ID        string    `json:"id"`
+	Oper      string    `json:"oper"` // "set", "reg", "reg&set" or "del"
+	Key       string    `json:"key"`
+	SchemaKey string    `json:"schemaKey,omitempty"`
+	StoreID   string    `json:"storeId,omitempty"`
+	JobID     string    `json:"jobId,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
 // PublishArgs represents arguments for the publish operation
 type PublishArgs struct {
 	Key    string      `json:"key"`
@@ -51,6 +91,145 @@ type ADT_Job struct {
 	StoreID string `json:"storeId"`
 }
 
+// Job represents the status of a job, the objects stored under its JobID belong to it
+type Job struct {
+	JobID    string            `json:"jobId"`
+	Status   string            `json:"status"`
+	Started  time.Time         `json:"started"`
+	Updated  time.Time         `json:"updated"`
+	Finished *time.Time        `json:"finished,omitempty"`
+	Info     map[string]string `json:"info,omitempty"`
+}
+
+// Index represents a secondary index on a JSON path of the objects stored under a schemaKey
+type Index struct {
+	Name      string `json:"name"` // derived from SchemaKey and Path
+	SchemaKey string `json:"schemaKey"`
+	Path      string `json:"path"` // e.g. "$.name" or "$.address.city"
+}
+
+// IndexLookup represents an equality or range lookup on an indexed JSON path.
+// Equal, From and To are JSON scalars, a nil bound is not applied.
+type IndexLookup struct {
+	SchemaKey string      `json:"schemaKey"`
+	Path      string      `json:"path"`
+	Equal     interface{} `json:"eq,omitempty"`
+	From      interface{} `json:"from,omitempty"`   // inclusive lower bound
+	To        interface{} `json:"to,omitempty"`     // exclusive upper bound
+	Latest    bool        `json:"latest,omitempty"` // only the latest revision of each live key
+	Limit     int         `json:"limit,omitempty"`
+}
+
+// Query operators of a Predicate
+const (
+	OpEqual        = "="
+	OpNotEqual     = "!="
+	OpLess         = "<"
+	OpLessEqual    = "<="
+	OpGreater      = ">"
+	OpGreaterEqual = ">="
+	OpIn           = "in"       // Value is a list of scalars
+	OpExists       = "exists"   // Value false asks for a missing path
+	OpContains     = "contains" // an element of an array, or a substring of a string
+)
+
+// Predicate represents a condition on the value at a JSON path of an object.
+// Comparisons never match a missing value, use OpExists to test for one.
+type Predicate struct {
+	Path  string      `json:"path"`
+	Op    string      `json:"op"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// IsScalar reports whether a predicate value is a string, number or boolean, the values
+// the comparisons, OpIn and OpContains take
+func IsScalar(value interface{}) bool {
+	switch value.(type) {
+	case string, float64, int, int64, bool:
+		return true
+	}
+	return false
+}
+
+// SortField represents a sort order on a JSON path, or on "storeId"
+type SortField struct {
+	Path string `json:"path"`
+	Desc bool   `json:"desc,omitempty"`
+}
+
+// Query represents a search over the latest revisions of the live keys.
+// Results are ordered by Sort and then by storeID, pages are at most Limit long.
+type Query struct {
+	SchemaKey string      `json:"schemaKey,omitempty"`
+	KeyPrefix string      `json:"keyPrefix,omitempty"`
+	KeyGlob   string      `json:"keyGlob,omitempty"` // "*" matches any run of characters, "?" one
+	Where     []Predicate `json:"where,omitempty"`
+	Sort      []SortField `json:"sort,omitempty"`
+	Limit     int         `json:"limit,omitempty"`
+	Cursor    string      `json:"cursor,omitempty"` // NextCursor of the previous page
+}
+
+// QueryPage represents one page of query results
+type QueryPage struct {
+	Results    []Revision `json:"results"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+}
+
+// SearchIndex represents the full-text index of the text at Paths of the objects of a schema
+type SearchIndex struct {
+	SchemaKey string   `json:"schemaKey"`
+	Paths     []string `json:"paths"`
+}
+
+// SearchQuery represents a full-text search of the live keys of a schema.
+// Every word of Text must match, a word ending in "*" matches as a prefix.
+type SearchQuery struct {
+	SchemaKey string `json:"schemaKey"`
+	Text      string `json:"text"`
+	Limit     int    `json:"limit,omitempty"`
+}
+
+// SearchHit represents a key found by a search, hits are ordered by Rank, best first.
+// Snippets holds, by path, the matching text with the matches between <mark> and </mark>.
+type SearchHit struct {
+	Key      string            `json:"key"`
+	StoreID  string            `json:"storeId"`
+	Rank     float64           `json:"rank"` // the bm25 rank, lower is better
+	Snippets map[string]string `json:"snippets"`
+}
+
+// StoreStats represents the size and write activity of a store
+type StoreStats struct {
+	Keys         int64   `json:"keys"`         // live keys
+	DeletedKeys  int64   `json:"deletedKeys"`  // soft deleted keys
+	Revisions    int64   `json:"revisions"`    // stored revisions of all keys
+	Bytes        int64   `json:"bytes"`        // size of the stored objects as JSON
+	Writes       int64   `json:"writes"`       // revisions written since the store was opened
+	WritesPerSec float64 `json:"writesPerSec"` // over the last minute
+}
+
+// Health check statuses, a check that cannot run on the platform is unsupported
+const (
+	HealthUp          = "up"
+	HealthDown        = "down"
+	HealthUnsupported = "unsupported"
+)
+
+// HealthCheck represents the result of a single health check
+type HealthCheck struct {
+	Name    string            `json:"name"`
+	Status  string            `json:"status"` // HealthUp, HealthDown or HealthUnsupported
+	Message string            `json:"message,omitempty"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// HealthReport represents the combined result of a set of health checks,
+// it is down if any of its checks is down, unsupported checks are not counted
+type HealthReport struct {
+	Status string        `json:"status"`
+	Checks []HealthCheck `json:"checks"`
+}
+
 // ExtError represents an extended error with additional info
 type ExtError struct {
 	Message string
@@ -62,3 +241,8 @@ type ExtError struct {
 func (e *ExtError) Error() string {
 	return fmt.Sprintf("%s: %s", e.Name, e.Message)
 }
+
+// Unwrap returns the cause of the error
+func (e *ExtError) Unwrap() error {
+	return e.Cause
+}
@@ -0,0 +1,60 @@
+package util
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// jsonPathPattern accepts the subset of SQLite JSON paths made of member names, quoted
+// member names and array indexes
+var jsonPathPattern = regexp.MustCompile(`^\$(\.[A-Za-z_][A-Za-z0-9_]*|\."[^"'\\]+"|\[[0-9]+\])+$`)
+
+// IsJSONPath reports whether path is a JSON path such as "$.name", "$.address.city",
+// "$.tags[0]" or $."rdfs:label". Only member names, quoted member names without
+// quotes or backslashes, and array indexes are accepted, so a valid path can safely be
+// written into SQL.
+func IsJSONPath(path string) bool {
+	return jsonPathPattern.MatchString(path)
+}
+
+// JSONPathValue gets the value at path in a decoded JSON object,
+// it reports false if path is invalid or leads nowhere.
+func JSONPathValue(obj interface{}, path string) (interface{}, bool) {
+	if !IsJSONPath(path) {
+		return nil, false
+	}
+	value := obj
+	rest := path[1:]
+	for rest != "" {
+		if rest[0] == '[' {
+			end := strings.IndexByte(rest, ']')
+			index, _ := strconv.Atoi(rest[1:end])
+			list, ok := value.([]interface{})
+			if !ok || index >= len(list) {
+				return nil, false
+			}
+			value, rest = list[index], rest[end+1:]
+			continue
+		}
+		var name string
+		if rest[1] == '"' {
+			end := strings.IndexByte(rest[2:], '"') + 2
+			name, rest = rest[2:end], rest[end+1:]
+		} else {
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			name, rest = rest[1:end+1], rest[end+1:]
+		}
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[name]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
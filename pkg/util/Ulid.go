@@ -2,6 +2,7 @@ package util
 
 import (
 	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/oklog/ulid/v2"
@@ -13,13 +14,36 @@ func NewULID() string {
 	return ulid.MustNew(ulid.Timestamp(time.Now()), entropy).String()
 }
 
-// ULIDGenerator returns a function that generates ULIDs
+// ULIDGenerator returns a function that generates ULIDs.
+// The function is safe for concurrent use and its ULIDs are strictly increasing.
 func ULIDGenerator() func() string {
 	entropy := ulid.Monotonic(rand.New(rand.NewSource(time.Now().UnixNano())), 0)
+	var mu sync.Mutex
 
 	return func() string {
+		mu.Lock()
+		defer mu.Unlock()
 		return ulid.MustNew(ulid.Timestamp(time.Now()), entropy).String()
 	}
 }
 
 var Ulid = ULIDGenerator()
+
+// UlidTime returns the millisecond timestamp embedded in a ULID
+func UlidTime(id string) (time.Time, error) {
+	parsed, err := ulid.Parse(id)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return parsed.Timestamp(), nil
+}
+
+// UlidLowerBound returns the smallest ULID carrying the timestamp of t,
+// every ULID generated at or after t sorts at or after it
+func UlidLowerBound(t time.Time) string {
+	var id ulid.ULID
+	if err := id.SetTime(ulid.Timestamp(t)); err != nil {
+		return ""
+	}
+	return id.String()
+}